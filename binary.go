@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"image/color"
 	"io"
@@ -24,23 +25,61 @@ const (
 	ptNullMarker = 8
 )
 
+// Extensions used by Steam, enabled by DecodeOptions.ExtendedTypes.
+const (
+	ptInt64        = 10
+	ptAlternateEnd = 11
+)
+
+// Types from KeyValues::ReadAsBinary in Source SDK 2013 that differ from
+// EPackType:
+// https://github.com/ValveSoftware/source-sdk-2013/blob/master/mp/src/public/tier1/KeyValues.h
+const (
+	kvCompiledIntByte = 8
+	kvCompiledInt0    = 9
+	kvCompiledInt1    = 10
+	kvEnd             = 11
+)
+
+// BinaryDialect selects one of the binary formats used by the Source engine.
+type BinaryDialect int
+
+const (
+	// BinaryKVPacker is the format written by KVPacker, which is used by
+	// Steam.
+	BinaryKVPacker BinaryDialect = iota
+	// BinaryKeyValues is the format written by KeyValues::WriteAsBinary,
+	// which is used by some compiled game files. It has compact integer
+	// types and a different end marker, and it cannot store wide strings.
+	BinaryKeyValues
+)
+
 func (n *Node) MarshalBinary() ([]byte, error) {
+	return EncodeBinary(n, nil)
+}
+
+// EncodeBinary is like MarshalBinary, but with configurable behavior.
+func EncodeBinary(n *Node, opts *EncodeOptions) ([]byte, error) {
 	if n == nil {
 		return nil, nil
 	}
 
 	var buf bytes.Buffer
 
-	if err := n.writeAsBinary(&buf); err != nil {
+	if err := n.writeAsBinary(&buf, opts.orDefault()); err != nil {
 		return nil, err
 	}
 
 	return buf.Bytes(), nil
 }
 
-func (n *Node) writeAsBinary(w io.Writer) error {
+func (n *Node) writeAsBinary(w io.Writer, o *EncodeOptions) error {
 	for c := n; c != nil; c = c.NextChild() {
-		if _, err := w.Write([]byte{packType(c.value)}); err != nil {
+		pt, err := o.packType(c.value)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte{pt}); err != nil {
 			return err
 		}
 
@@ -55,10 +94,9 @@ func (n *Node) writeAsBinary(w io.Writer) error {
 			return err
 		}
 
-		var err error
 		switch v := c.value.(type) {
 		case nil:
-			err = c.child.writeAsBinary(w)
+			err = c.child.writeAsBinary(w, o)
 		case string:
 			if i := strings.IndexByte(v, 0); i != -1 {
 				v = v[:i]
@@ -68,7 +106,13 @@ func (n *Node) writeAsBinary(w io.Writer) error {
 			}
 			_, err = w.Write([]byte{0})
 		case int32:
-			err = binary.Write(w, binary.LittleEndian, &v)
+			switch pt {
+			case kvCompiledInt0, kvCompiledInt1:
+			case kvCompiledIntByte:
+				_, err = w.Write([]byte{byte(v)})
+			default:
+				err = binary.Write(w, binary.LittleEndian, &v)
+			}
 		case float32:
 			err = binary.Write(w, binary.LittleEndian, &v)
 		case uint32:
@@ -85,6 +129,8 @@ func (n *Node) writeAsBinary(w io.Writer) error {
 			err = binary.Write(w, binary.LittleEndian, &v)
 		case uint64:
 			err = binary.Write(w, binary.LittleEndian, &v)
+		case int64:
+			err = binary.Write(w, binary.LittleEndian, &v)
 		default:
 			panic("invalid vdf.Node")
 		}
@@ -92,121 +138,500 @@ func (n *Node) writeAsBinary(w io.Writer) error {
 			return err
 		}
 	}
-	if _, err := w.Write([]byte{ptNullMarker}); err != nil {
+	if _, err := w.Write([]byte{o.endMarker()}); err != nil {
 		return err
 	}
 	return nil
 }
 
-// packType returns the EPackType for the value inside the interface.
-func packType(v interface{}) byte {
+// packType returns the type byte for the value inside the interface.
+func (o *EncodeOptions) packType(v interface{}) (byte, error) {
+	if o.Dialect == BinaryKeyValues {
+		switch i := v.(type) {
+		case int32:
+			if !o.CompactInts {
+				return ptInt, nil
+			}
+			switch {
+			case i == 0:
+				return kvCompiledInt0, nil
+			case i == 1:
+				return kvCompiledInt1, nil
+			case int32(int8(i)) == i:
+				return kvCompiledIntByte, nil
+			}
+			return ptInt, nil
+		case []uint16:
+			return 0, fmt.Errorf("vdf: wide strings cannot be written in the KeyValues binary dialect")
+		case int64:
+			return 0, fmt.Errorf("vdf: 64-bit signed integers cannot be written in the KeyValues binary dialect")
+		}
+	}
+
 	switch v.(type) {
 	case nil:
-		return ptNone
+		return ptNone, nil
 	case string:
-		return ptString
+		return ptString, nil
 	case int32:
-		return ptInt
+		return ptInt, nil
 	case float32:
-		return ptFloat
+		return ptFloat, nil
 	case uint32:
-		return ptPtr
+		return ptPtr, nil
 	case []uint16:
-		return ptWString
+		return ptWString, nil
 	case color.NRGBA:
-		return ptColor
+		return ptColor, nil
 	case uint64:
-		return ptUint64
+		return ptUint64, nil
+	case int64:
+		if !o.ExtendedTypes {
+			return 0, fmt.Errorf("vdf: 64-bit signed integers require EncodeOptions.ExtendedTypes")
+		}
+		return ptInt64, nil
 	default:
 		panic("invalid vdf.Node")
 	}
 }
 
+func (o *EncodeOptions) endMarker() byte {
+	if o.Dialect == BinaryKeyValues {
+		return kvEnd
+	}
+	return ptNullMarker
+}
+
 func (n *Node) UnmarshalBinary(b []byte) error {
 	*n = Node{}
-	return n.readAsBinary(bufio.NewReader(bytes.NewReader(b)), nil)
+	return newBinaryReader(bytes.NewReader(b), newDecodeState(nil)).decode(n)
+}
+
+// DecodeBinary is like UnmarshalBinary, but with configurable behavior.
+func DecodeBinary(b []byte, opts *DecodeOptions) (*Node, error) {
+	d := newDecodeState(opts)
+	r := newBinaryReader(bytes.NewReader(b), d)
+	n := new(Node)
+	if err := r.decode(n); err != nil {
+		return nil, err
+	}
+	if d.opts.Strict && r.off != int64(len(b)) {
+		return nil, &BinaryError{Offset: r.off, PackType: d.endMarker(), Err: ErrTrailingData}
+	}
+	return n, nil
+}
+
+// ErrTrailingData is wrapped by the *BinaryError returned by strict decoding
+// when there is data after the end of the binary KeyValues.
+var ErrTrailingData = errors.New("vdf: trailing data after end of input")
+
+// BinaryError is returned when binary KeyValues cannot be decoded.
+//
+// If the input ends in the middle of a node, Err is io.ErrUnexpectedEOF.
+type BinaryError struct {
+	// Offset is the position in the input of the start of the node that
+	// could not be decoded, or of the trailing data.
+	Offset int64
+	// PackType is the type byte of the node that could not be decoded.
+	// It is 0 (a subtree) if the type byte itself could not be read, and
+	// the end marker for trailing data.
+	PackType byte
+	Err      error
+}
+
+func (err *BinaryError) Error() string {
+	return fmt.Sprintf("vdf: at offset %d (pack type %d): %s", err.Offset, err.PackType, strings.TrimPrefix(err.Err.Error(), "vdf: "))
+}
+
+func (err *BinaryError) Unwrap() error { return err.Err }
+
+// Decoder reads a sequence of concatenated binary KeyValues from a stream.
+type Decoder struct {
+	r *binaryReader
 }
 
-func (n *Node) readAsBinary(r *bufio.Reader, parent *Node) error {
+// NewDecoder returns a Decoder that reads binary KeyValues from r. The
+// limits in opts apply to each call to Decode separately. The Decoder may
+// read beyond the end of the last KeyValues it decodes.
+func NewDecoder(r io.Reader, opts *DecodeOptions) *Decoder {
+	return &Decoder{r: newBinaryReader(r, newDecodeState(opts))}
+}
+
+// More reports whether there is more input to decode.
+func (dec *Decoder) More() bool {
+	_, err := dec.r.r.Peek(1)
+	return err == nil
+}
+
+// InputOffset returns the number of bytes of input consumed so far.
+func (dec *Decoder) InputOffset() int64 {
+	return dec.r.off
+}
+
+// Decode reads the next binary KeyValues from the input into n. At the end of
+// the input, Decode returns io.EOF.
+func (dec *Decoder) Decode(n *Node) error {
+	if _, err := dec.r.r.Peek(1); err != nil {
+		return err
+	}
+
+	dec.r.d = newDecodeState(dec.r.d.opts)
+	dec.r.start = dec.r.off
+	*n = Node{}
+	return dec.r.decode(n)
+}
+
+// binaryReader keeps track of the position in the input for error messages
+// and for MaxBytes.
+type binaryReader struct {
+	r     *bufio.Reader
+	d     *decodeState
+	off   int64
+	start int64
+
+	// for error messages
+	nodeOffset int64
+	packType   byte
+}
+
+func newBinaryReader(r io.Reader, d *decodeState) *binaryReader {
+	return &binaryReader{r: bufio.NewReader(r), d: d}
+}
+
+func (r *binaryReader) consumed(n int) error {
+	r.off += int64(n)
+	if max := r.d.opts.MaxBytes; max > 0 && r.off-r.start > max {
+		return &LimitError{Limit: LimitBytes, Max: max}
+	}
+	return nil
+}
+
+func (r *binaryReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		err = r.consumed(1)
+	}
+	return b, err
+}
+
+func (r *binaryReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if cerr := r.consumed(n); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+func (r *binaryReader) ReadSlice(delim byte) ([]byte, error) {
+	b, err := r.r.ReadSlice(delim)
+	if cerr := r.consumed(len(b)); err == nil || err == bufio.ErrBufferFull {
+		if cerr != nil {
+			err = cerr
+		}
+	}
+	return b, err
+}
+
+// decode reads one binary KeyValues into n, wrapping any error in a
+// *BinaryError.
+func (r *binaryReader) decode(n *Node) error {
+	if err := n.readAsBinary(r, nil); err != nil {
+		return &BinaryError{Offset: r.nodeOffset, PackType: r.packType, Err: unexpectedEOF(err)}
+	}
+	return nil
+}
+
+// readPackType reads the type byte of the next node.
+func (r *binaryReader) readPackType() (byte, error) {
+	r.nodeOffset, r.packType = r.off, ptNone
 	pt, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	r.packType = pt
+	return pt, nil
+}
+
+func (n *Node) readAsBinary(r *binaryReader, parent *Node) error {
+	d := r.d
+	pt, err := r.readPackType()
 	if err != nil {
 		return err
 	}
 
-	for c := n; pt != ptNullMarker; {
+	var seen map[string]*Node
+	var last *Node
+	for c := n; !d.isEnd(pt); {
+		if c == nil {
+			c = new(Node)
+			c.prev = last
+			last.next = c
+		}
+		if err = d.addNode(); err != nil {
+			return err
+		}
 		c.parent = parent
-		name, err := r.ReadString(0)
+		name, sym, err := r.readName()
 		if err != nil {
 			return err
 		}
-		c.SetName(strings.TrimSuffix(name, "\x00"))
+		c.SetName(name)
+		c.sym = sym
 
-		switch pt {
-		case ptNone:
-			var sub Node
-			c.Append(&sub)
-			sub.parent = nil
-			err = sub.readAsBinary(r, c)
-			if sub.parent == nil {
-				c.child = nil
-			}
-		case ptString:
-			var v string
-			v, err = r.ReadString(0)
-			c.value = strings.TrimSuffix(v, "\x00")
-		case ptWString:
-			var length uint16
-			if err = binary.Read(r, binary.LittleEndian, &length); err != nil {
-				return err
-			}
-			v := make([]uint16, length)
-			for i := range v {
-				if err = binary.Read(r, binary.LittleEndian, &v[i]); err != nil {
-					return err
-				}
-			}
-			c.value = v
-		case ptInt:
-			var v int32
-			err = binary.Read(r, binary.LittleEndian, &v)
-			c.value = v
-		case ptUint64:
-			var v uint64
-			err = binary.Read(r, binary.LittleEndian, &v)
-			c.value = v
-		case ptFloat:
-			var v float32
-			err = binary.Read(r, binary.LittleEndian, &v)
-			c.value = v
-		case ptColor:
-			var v color.NRGBA
-			err = binary.Read(r, binary.LittleEndian, &v)
-			c.value = v
-		case ptPtr:
-			var v uint32
-			err = binary.Read(r, binary.LittleEndian, &v)
-			c.value = v
-		default:
-			err = fmt.Errorf("vdf: unknown pack type %d", pt)
+		if d.opts.Dialect == BinaryKeyValues && (pt == ptWString || pt >= kvCompiledIntByte) {
+			err = c.readCompiledInt(r, pt)
+		} else {
+			err = c.readValue(r, pt)
 		}
 		if err != nil {
 			return err
 		}
 
-		pt, err = r.ReadByte()
+		keep, err := d.keep(c, &seen)
 		if err != nil {
 			return err
 		}
+		if keep {
+			last = c
+			c = nil
+		} else {
+			c = discard(c)
+		}
 
-		if pt == ptNullMarker {
-			break
+		pt, err = r.readPackType()
+		if err != nil {
+			return err
 		}
+	}
+
+	if last == nil {
+		// every node was discarded
+		n.parent = nil
+	}
+
+	return nil
+}
 
-		var peer Node
-		c.next = &peer
-		peer.prev = c
-		c = &peer
+// readValue reads the value of n, which has the type pt.
+func (n *Node) readValue(r *binaryReader, pt byte) error {
+	d := r.d
+	var err error
+	switch pt {
+	case ptNone:
+		if err = d.push(); err != nil {
+			return err
+		}
+		var sub Node
+		n.Append(&sub)
+		sub.parent = nil
+		err = sub.readAsBinary(r, n)
+		if sub.parent == nil {
+			n.child = nil
+		}
+		d.pop()
+	case ptString:
+		var v string
+		v, err = r.readCString()
+		n.value = v
+	case ptWString:
+		var v []uint16
+		v, err = r.readWString()
+		n.value = v
+	case ptInt:
+		var v int32
+		err = binary.Read(r, binary.LittleEndian, &v)
+		n.value = v
+	case ptUint64:
+		var v uint64
+		err = binary.Read(r, binary.LittleEndian, &v)
+		n.value = v
+	case ptFloat:
+		var v float32
+		err = binary.Read(r, binary.LittleEndian, &v)
+		n.value = v
+	case ptColor:
+		var v color.NRGBA
+		err = binary.Read(r, binary.LittleEndian, &v)
+		n.value = v
+	case ptPtr:
+		var v uint32
+		err = binary.Read(r, binary.LittleEndian, &v)
+		n.value = v
+	case ptInt64:
+		if !d.opts.ExtendedTypes {
+			err = fmt.Errorf("vdf: unknown pack type %d", pt)
+			break
+		}
+		var v int64
+		err = binary.Read(r, binary.LittleEndian, &v)
+		n.value = v
+	default:
+		err = fmt.Errorf("vdf: unknown pack type %d", pt)
 	}
+	return err
+}
 
+// readCompiledInt reads the value of n in the KeyValues binary dialect for
+// the types that differ from KVPacker.
+func (n *Node) readCompiledInt(r *binaryReader, pt byte) error {
+	switch pt {
+	case kvCompiledIntByte:
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		n.value = int32(int8(b))
+	case kvCompiledInt0:
+		n.value = int32(0)
+	case kvCompiledInt1:
+		n.value = int32(1)
+	default:
+		return fmt.Errorf("vdf: unknown pack type %d", pt)
+	}
 	return nil
 }
+
+// Values returned by valueSize for types that do not have a fixed size.
+const (
+	sizeSubtree = -1 - iota
+	sizeString
+	sizeWString
+)
+
+// valueSize returns the number of bytes in a value of type pt, or one of
+// sizeSubtree, sizeString, or sizeWString.
+func (d *decodeState) valueSize(pt byte) (int, error) {
+	if d.opts.Dialect == BinaryKeyValues && (pt == ptWString || pt >= kvCompiledIntByte) {
+		switch pt {
+		case kvCompiledIntByte:
+			return 1, nil
+		case kvCompiledInt0, kvCompiledInt1:
+			return 0, nil
+		}
+		return 0, fmt.Errorf("vdf: unknown pack type %d", pt)
+	}
+
+	switch pt {
+	case ptNone:
+		return sizeSubtree, nil
+	case ptString:
+		return sizeString, nil
+	case ptWString:
+		return sizeWString, nil
+	case ptInt, ptFloat, ptPtr, ptColor:
+		return 4, nil
+	case ptUint64:
+		return 8, nil
+	case ptInt64:
+		if d.opts.ExtendedTypes {
+			return 8, nil
+		}
+	}
+	return 0, fmt.Errorf("vdf: unknown pack type %d", pt)
+}
+
+// isEnd reports whether pt marks the end of a subtree.
+func (d *decodeState) isEnd(pt byte) bool {
+	if d.opts.Dialect == BinaryKeyValues {
+		return pt == kvEnd
+	}
+	return pt == ptNullMarker || (pt == ptAlternateEnd && d.opts.ExtendedTypes)
+}
+
+func (d *decodeState) endMarker() byte {
+	if d.opts.Dialect == BinaryKeyValues {
+		return kvEnd
+	}
+	return ptNullMarker
+}
+
+// readName reads the name of a key, interning it if the decoder has a
+// SymbolTable.
+func (r *binaryReader) readName() (string, *symbol, error) {
+	d := r.d
+	if d.opts.Symbols == nil {
+		name, err := r.readCString()
+		return name, nil, err
+	}
+
+	b, err := r.ReadSlice(0)
+	if err == nil {
+		if err = d.checkString(len(b) - 1); err != nil {
+			return "", nil, err
+		}
+		name, sym := d.opts.Symbols.internBytes(b[:len(b)-1])
+		return name, sym, nil
+	}
+
+	buf := append([]byte(nil), b...)
+	for err == bufio.ErrBufferFull {
+		if err = d.checkString(len(buf)); err != nil {
+			return "", nil, err
+		}
+		b, err = r.ReadSlice(0)
+		buf = append(buf, b...)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if err = d.checkString(len(buf) - 1); err != nil {
+		return "", nil, err
+	}
+	name, sym := d.opts.Symbols.intern(string(buf[:len(buf)-1]))
+	return name, sym, nil
+}
+
+// readCString reads a null-terminated string, not including the terminator.
+func (r *binaryReader) readCString() (string, error) {
+	d := r.d
+	b, err := r.ReadSlice(0)
+	if err == nil {
+		if err = d.checkString(len(b) - 1); err != nil {
+			return "", err
+		}
+		return string(b[:len(b)-1]), nil
+	}
+
+	buf := append([]byte(nil), b...)
+	for err == bufio.ErrBufferFull {
+		if err = d.checkString(len(buf)); err != nil {
+			return "", err
+		}
+		b, err = r.ReadSlice(0)
+		buf = append(buf, b...)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err = d.checkString(len(buf) - 1); err != nil {
+		return "", err
+	}
+	return string(buf[:len(buf)-1]), nil
+}
+
+// readWString reads a length-prefixed UTF-16 string. The slice grows as the
+// string is read, so a large length cannot allocate more memory than the
+// input can fill.
+func (r *binaryReader) readWString() ([]uint16, error) {
+	var length uint16
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+	if err := r.d.checkString(int(length)); err != nil {
+		return nil, err
+	}
+
+	const chunk = 256
+	v := make([]uint16, 0)
+	for len(v) < int(length) {
+		n := int(length) - len(v)
+		if n > chunk {
+			n = chunk
+		}
+		v = append(v, make([]uint16, n)...)
+		if err := binary.Read(r, binary.LittleEndian, v[len(v)-n:]); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
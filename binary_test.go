@@ -2,6 +2,8 @@ package vdf_test
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"io/ioutil"
 	"testing"
 
@@ -31,3 +33,159 @@ func TestBinaryReEncode(t *testing.T) {
 		t.Logf("out: % x", out)
 	}
 }
+
+func TestBinaryTruncated(t *testing.T) {
+	in := []byte("\x00a\x00\x01b\x00xyz")
+
+	var n vdf.Node
+	err := n.UnmarshalBinary(in)
+
+	var be *vdf.BinaryError
+	if !errors.As(err, &be) {
+		t.Fatalf("expected *vdf.BinaryError, got %T: %v", err, err)
+	}
+	if be.Err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", be.Err)
+	}
+	if be.Offset != 3 || be.PackType != 1 {
+		t.Errorf("expected offset 3 and pack type 1, got offset %d and pack type %d", be.Offset, be.PackType)
+	}
+}
+
+func TestBinaryTrailing(t *testing.T) {
+	in := []byte("\x01a\x00b\x00\x08garbage")
+
+	if _, err := vdf.DecodeBinary(in, nil); err != nil {
+		t.Error("non-strict: ", err)
+	}
+
+	_, err := vdf.DecodeBinary(in, &vdf.DecodeOptions{Strict: true})
+	var be *vdf.BinaryError
+	if !errors.As(err, &be) || be.Err != vdf.ErrTrailingData {
+		t.Fatalf("expected ErrTrailingData, got %v", err)
+	}
+	if be.Offset != 6 {
+		t.Errorf("expected offset 6, got %d", be.Offset)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	in, err := ioutil.ReadFile("testdata/UserGameStatsSchema_630.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := vdf.NewDecoder(bytes.NewReader(bytes.Repeat(in, 3)), nil)
+	count := 0
+	for dec.More() {
+		var n vdf.Node
+		if err = dec.Decode(&n); err != nil {
+			t.Fatal(err)
+		}
+		count++
+
+		out, err := n.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(in, out) {
+			t.Errorf("record %d differs", count)
+		}
+	}
+	if count != 3 {
+		t.Errorf("expected 3 records, got %d", count)
+	}
+	if off := dec.InputOffset(); off != int64(3*len(in)) {
+		t.Errorf("expected offset %d, got %d", 3*len(in), off)
+	}
+
+	var n vdf.Node
+	if err = dec.Decode(&n); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestBinaryExtendedTypes(t *testing.T) {
+	in := []byte("\x00a\x00\x0ab\x00\xff\xff\xff\xff\xff\xff\xff\xff\x0b\x08")
+
+	if _, err := vdf.DecodeBinary(in, nil); err == nil {
+		t.Error("expected error without ExtendedTypes")
+	}
+
+	n, err := vdf.DecodeBinary(in, &vdf.DecodeOptions{ExtendedTypes: true, Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := n.FirstByName("b")
+	if b.Int64() != -1 || b.String() != "-1" {
+		t.Errorf("expected -1, got %d %q", b.Int64(), b.String())
+	}
+
+	if _, err = n.MarshalBinary(); err == nil {
+		t.Error("expected error encoding int64 without ExtendedTypes")
+	}
+
+	out, err := vdf.EncodeBinary(n, &vdf.EncodeOptions{ExtendedTypes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte("\x00a\x00\x0ab\x00\xff\xff\xff\xff\xff\xff\xff\xff\x08\x08"); !bytes.Equal(out, expected) {
+		t.Errorf("expected % x, got % x", expected, out)
+	}
+
+	n, err = vdf.DecodeBinary(out, &vdf.DecodeOptions{ExtendedTypes: true, Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b = n.FirstByName("b"); b.Kind() != vdf.KindInt64 || b.Int64() != -1 {
+		t.Errorf("expected int64 -1 after round trip, got %v %d", b.Kind(), b.Int64())
+	}
+}
+
+func TestBinaryKeyValuesDialect(t *testing.T) {
+	var root, a, b, c, d vdf.Node
+	root.SetName("root")
+	a.SetName("a")
+	a.SetInt(0)
+	b.SetName("b")
+	b.SetInt(1)
+	c.SetName("c")
+	c.SetInt(-5)
+	d.SetName("d")
+	d.SetInt(1000)
+	root.Append(&a)
+	root.Append(&b)
+	root.Append(&c)
+	root.Append(&d)
+
+	out, err := vdf.EncodeBinary(&root, &vdf.EncodeOptions{Dialect: vdf.BinaryKeyValues, CompactInts: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte("\x00root\x00\x09a\x00\x0ab\x00\x08c\x00\xfb\x02d\x00\xe8\x03\x00\x00\x0b\x0b"); !bytes.Equal(out, expected) {
+		t.Errorf("expected % x, got % x", expected, out)
+	}
+
+	n, err := vdf.DecodeBinary(out, &vdf.DecodeOptions{Dialect: vdf.BinaryKeyValues, Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]int32{"a": 0, "b": 1, "c": -5, "d": 1000} {
+		if actual := n.FirstByName(name).Int(); actual != expected {
+			t.Errorf("%s: expected %d, got %d", name, expected, actual)
+		}
+	}
+
+	out, err = vdf.EncodeBinary(n, &vdf.EncodeOptions{Dialect: vdf.BinaryKeyValues})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte("\x00root\x00\x02a\x00\x00\x00\x00\x00\x02b\x00\x01\x00\x00\x00\x02c\x00\xfb\xff\xff\xff\x02d\x00\xe8\x03\x00\x00\x0b\x0b"); !bytes.Equal(out, expected) {
+		t.Errorf("expected % x, got % x", expected, out)
+	}
+
+	a.SetWString([]uint16{'x'})
+	if _, err = vdf.EncodeBinary(&root, &vdf.EncodeOptions{Dialect: vdf.BinaryKeyValues}); err == nil {
+		t.Error("expected error for wide string")
+	}
+}
@@ -0,0 +1,316 @@
+package vdf
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ErrNoValue is wrapped by the *ValueError returned by the checked
+// accessors, such as IntE, for a nil Node or a subtree.
+var ErrNoValue = errors.New("vdf: no value")
+
+// ValueError is returned by the checked accessors, such as IntE, when the
+// value of a Node cannot be converted to the requested type.
+//
+// Err is ErrNoValue if the Node has no value, strconv.ErrRange if the value
+// does not fit in the type, and strconv.ErrSyntax otherwise. A floating-point
+// number with a fractional part does not fit in an integer type.
+type ValueError struct {
+	Name  string
+	Value string
+	Type  string
+	Err   error
+}
+
+func (err *ValueError) Error() string {
+	if err.Err == ErrNoValue {
+		return fmt.Sprintf("vdf: key %q has no value to convert to %s", err.Name, err.Type)
+	}
+	return fmt.Sprintf("vdf: cannot convert %q in key %q to %s: %v", err.Value, err.Name, err.Type, err.Err)
+}
+
+func (err *ValueError) Unwrap() error { return err.Err }
+
+func (n *Node) valueError(typ string, err error) error {
+	if ne, ok := err.(*strconv.NumError); ok {
+		err = ne.Err
+	}
+	return &ValueError{Name: n.Name(), Value: n.String(), Type: typ, Err: err}
+}
+
+// checkedString returns the value of n if it is a string, and whether n has
+// a value.
+func (n *Node) checkedString() (s string, isString, ok bool) {
+	if n == nil || n.child != nil {
+		return "", false, false
+	}
+	switch v := n.value.(type) {
+	case nil:
+		return "", false, false
+	case string:
+		return v, true, true
+	case []uint16:
+		return string(utf16.Decode(v)), true, true
+	}
+	return "", false, true
+}
+
+// checkedInt converts the value of n to a signed integer with the given
+// number of bits.
+func (n *Node) checkedInt(bits uint, typ string) (int64, error) {
+	s, isString, ok := n.checkedString()
+	if !ok {
+		return 0, n.valueError(typ, ErrNoValue)
+	}
+	if isString {
+		i, err := strconv.ParseInt(s, 10, int(bits))
+		if err != nil {
+			return 0, n.valueError(typ, err)
+		}
+		return i, nil
+	}
+
+	min, max := int64(-1)<<(bits-1), int64(1)<<(bits-1)-1
+	var i int64
+	switch v := n.value.(type) {
+	case int32:
+		i = int64(v)
+	case int64:
+		i = v
+	case uint32:
+		i = int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, n.valueError(typ, strconv.ErrRange)
+		}
+		i = int64(v)
+	case float32:
+		if v != float32(math.Trunc(float64(v))) || float64(v) < float64(min) || float64(v) >= -float64(min) {
+			return 0, n.valueError(typ, strconv.ErrRange)
+		}
+		i = int64(v)
+	default:
+		return 0, n.valueError(typ, strconv.ErrSyntax)
+	}
+	if i < min || i > max {
+		return 0, n.valueError(typ, strconv.ErrRange)
+	}
+	return i, nil
+}
+
+// checkedUint converts the value of n to an unsigned integer with the given
+// number of bits.
+func (n *Node) checkedUint(bits uint, typ string) (uint64, error) {
+	s, isString, ok := n.checkedString()
+	if !ok {
+		return 0, n.valueError(typ, ErrNoValue)
+	}
+	if isString {
+		i, err := strconv.ParseUint(s, 10, int(bits))
+		if err != nil {
+			return 0, n.valueError(typ, err)
+		}
+		return i, nil
+	}
+
+	max := uint64(1)<<bits - 1
+	var i uint64
+	switch v := n.value.(type) {
+	case int32:
+		if v < 0 {
+			return 0, n.valueError(typ, strconv.ErrRange)
+		}
+		i = uint64(v)
+	case int64:
+		if v < 0 {
+			return 0, n.valueError(typ, strconv.ErrRange)
+		}
+		i = uint64(v)
+	case uint32:
+		i = uint64(v)
+	case uint64:
+		i = v
+	case float32:
+		if v != float32(math.Trunc(float64(v))) || v < 0 || float64(v) >= float64(max)+1 {
+			return 0, n.valueError(typ, strconv.ErrRange)
+		}
+		i = uint64(v)
+	default:
+		return 0, n.valueError(typ, strconv.ErrSyntax)
+	}
+	if i > max {
+		return 0, n.valueError(typ, strconv.ErrRange)
+	}
+	return i, nil
+}
+
+// IntE is like Int, but returns a *ValueError if the value is not an
+// integer or does not fit in an int32.
+//
+// IntE is an accessor.
+func (n *Node) IntE() (int32, error) {
+	i, err := n.checkedInt(32, "int32")
+	return int32(i), err
+}
+
+// Int64E is like Int64, but returns a *ValueError if the value is not an
+// integer or does not fit in an int64.
+//
+// Int64E is an accessor.
+func (n *Node) Int64E() (int64, error) {
+	return n.checkedInt(64, "int64")
+}
+
+// PtrE is like Ptr, but returns a *ValueError if the value is not an
+// integer or does not fit in a uint32.
+//
+// PtrE is an accessor.
+func (n *Node) PtrE() (uint32, error) {
+	i, err := n.checkedUint(32, "uint32")
+	return uint32(i), err
+}
+
+// Uint64E is like Uint64, but returns a *ValueError if the value is not an
+// integer or does not fit in a uint64.
+//
+// Uint64E is an accessor.
+func (n *Node) Uint64E() (uint64, error) {
+	return n.checkedUint(64, "uint64")
+}
+
+// FloatE is like Float, but returns a *ValueError if the value is not a
+// number or is too large for a float32. Integers are rounded to the nearest
+// float32.
+//
+// FloatE is an accessor.
+func (n *Node) FloatE() (float32, error) {
+	s, isString, ok := n.checkedString()
+	if !ok {
+		return 0, n.valueError("float32", ErrNoValue)
+	}
+	if isString {
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return 0, n.valueError("float32", err)
+		}
+		return float32(f), nil
+	}
+	if _, isColor := n.value.(color.NRGBA); isColor {
+		return 0, n.valueError("float32", strconv.ErrSyntax)
+	}
+	return n.Float(), nil
+}
+
+// ColorE is like Color, but returns a *ValueError if the value is not a
+// color. A string can hold four integers from 0 to 255 separated by
+// spaces, three integers for an opaque color, or a hexadecimal color such
+// as "#ff8000" or "#ff800080". Color accepts the same forms.
+//
+// ColorE is an accessor.
+func (n *Node) ColorE() (color.NRGBA, error) {
+	s, isString, ok := n.checkedString()
+	if !ok {
+		return color.NRGBA{}, n.valueError("color.NRGBA", ErrNoValue)
+	}
+	if !isString {
+		if c, isColor := n.value.(color.NRGBA); isColor {
+			return c, nil
+		}
+		return color.NRGBA{}, n.valueError("color.NRGBA", strconv.ErrSyntax)
+	}
+
+	c, _, err := parseColor(s)
+	if err != nil {
+		return color.NRGBA{}, n.valueError("color.NRGBA", err)
+	}
+	return c, nil
+}
+
+// Bool returns the value of this Node as a bool, or false if it cannot be
+// converted. See BoolE.
+//
+// Bool is an accessor.
+func (n *Node) Bool() bool {
+	b, _ := n.BoolE()
+	return b
+}
+
+// BoolE returns the value of this Node as a bool. Like KeyValues::GetBool,
+// any number other than zero is true. The strings "true" and "false" are
+// also accepted, ignoring case. Any other value returns a *ValueError.
+//
+// BoolE is an accessor.
+func (n *Node) BoolE() (bool, error) {
+	s, isString, ok := n.checkedString()
+	if !ok {
+		return false, n.valueError("bool", ErrNoValue)
+	}
+	if isString {
+		switch {
+		case strings.EqualFold(s, "true"):
+			return true, nil
+		case strings.EqualFold(s, "false"):
+			return false, nil
+		}
+	}
+
+	f, err := n.FloatE()
+	if err != nil {
+		return false, n.valueError("bool", err.(*ValueError).Err)
+	}
+	return f != 0, nil
+}
+
+// Duration returns the value of this Node as a time.Duration, or 0 if it
+// cannot be converted. See DurationE.
+//
+// Duration is an accessor.
+func (n *Node) Duration() time.Duration {
+	d, _ := n.DurationE()
+	return d
+}
+
+// DurationE returns the value of this Node as a time.Duration. A number is
+// a number of seconds, which is how Source stores times, and a string with
+// a unit, such as "1m30s", is parsed by time.ParseDuration. Any other value
+// returns a *ValueError.
+//
+// DurationE is an accessor.
+func (n *Node) DurationE() (time.Duration, error) {
+	s, isString, ok := n.checkedString()
+	if !ok {
+		return 0, n.valueError("time.Duration", ErrNoValue)
+	}
+	if isString {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d, nil
+		}
+	}
+
+	var seconds float64
+	if isString {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, n.valueError("time.Duration", err)
+		}
+		seconds = f
+	} else if i, err := n.checkedInt(64, "time.Duration"); err == nil {
+		seconds = float64(i)
+	} else if f, err := n.FloatE(); err == nil {
+		seconds = float64(f)
+	} else {
+		return 0, n.valueError("time.Duration", strconv.ErrSyntax)
+	}
+
+	d := seconds * float64(time.Second)
+	if math.IsNaN(d) || d >= math.MaxInt64 || d < math.MinInt64 {
+		return 0, n.valueError("time.Duration", strconv.ErrRange)
+	}
+	return time.Duration(d), nil
+}
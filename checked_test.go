@@ -0,0 +1,110 @@
+package vdf_test
+
+import (
+	"errors"
+	"image/color"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/BenLubar/vdf"
+)
+
+func checkedNode(value interface{}) *vdf.Node {
+	n := vdf.FromMap("root", map[string]interface{}{"key": value})
+	return n.FirstChild()
+}
+
+func TestCheckedAccessors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value interface{}
+		f     func(n *vdf.Node) (interface{}, error)
+		want  interface{}
+		err   error
+	}{
+		{"IntString", "-12", func(n *vdf.Node) (interface{}, error) { return n.IntE() }, int32(-12), nil},
+		{"IntZero", "0", func(n *vdf.Node) (interface{}, error) { return n.IntE() }, int32(0), nil},
+		{"IntInvalid", "abc", func(n *vdf.Node) (interface{}, error) { return n.IntE() }, int32(0), strconv.ErrSyntax},
+		{"IntEmpty", "", func(n *vdf.Node) (interface{}, error) { return n.IntE() }, int32(0), strconv.ErrSyntax},
+		{"IntOverflowString", "3000000000", func(n *vdf.Node) (interface{}, error) { return n.IntE() }, int32(0), strconv.ErrRange},
+		{"IntOverflowUint64", uint64(1 << 40), func(n *vdf.Node) (interface{}, error) { return n.IntE() }, int32(0), strconv.ErrRange},
+		{"IntFloat", float32(3), func(n *vdf.Node) (interface{}, error) { return n.IntE() }, int32(3), nil},
+		{"IntFraction", float32(3.5), func(n *vdf.Node) (interface{}, error) { return n.IntE() }, int32(0), strconv.ErrRange},
+		{"IntColor", color.NRGBA{}, func(n *vdf.Node) (interface{}, error) { return n.IntE() }, int32(0), strconv.ErrSyntax},
+		{"Int64", int64(-1 << 40), func(n *vdf.Node) (interface{}, error) { return n.Int64E() }, int64(-1 << 40), nil},
+		{"Int64Overflow", uint64(1 << 63), func(n *vdf.Node) (interface{}, error) { return n.Int64E() }, int64(0), strconv.ErrRange},
+		{"PtrNegative", int32(-1), func(n *vdf.Node) (interface{}, error) { return n.PtrE() }, uint32(0), strconv.ErrRange},
+		{"Ptr", "4000000000", func(n *vdf.Node) (interface{}, error) { return n.PtrE() }, uint32(4000000000), nil},
+		{"Uint64", "18446744073709551615", func(n *vdf.Node) (interface{}, error) { return n.Uint64E() }, uint64(1<<64 - 1), nil},
+		{"Uint64Negative", "-1", func(n *vdf.Node) (interface{}, error) { return n.Uint64E() }, uint64(0), strconv.ErrSyntax},
+		{"Float", "1.5", func(n *vdf.Node) (interface{}, error) { return n.FloatE() }, float32(1.5), nil},
+		{"FloatInt", int32(2), func(n *vdf.Node) (interface{}, error) { return n.FloatE() }, float32(2), nil},
+		{"FloatOverflow", "1e40", func(n *vdf.Node) (interface{}, error) { return n.FloatE() }, float32(0), strconv.ErrRange},
+		{"Color", "1 2 3 4", func(n *vdf.Node) (interface{}, error) { return n.ColorE() }, color.NRGBA{1, 2, 3, 4}, nil},
+		{"ColorShort", "1 2", func(n *vdf.Node) (interface{}, error) { return n.ColorE() }, color.NRGBA{}, strconv.ErrSyntax},
+		{"ColorOverflow", "1 2 3 256", func(n *vdf.Node) (interface{}, error) { return n.ColorE() }, color.NRGBA{}, strconv.ErrRange},
+		{"BoolOne", "1", func(n *vdf.Node) (interface{}, error) { return n.BoolE() }, true, nil},
+		{"BoolZero", "0", func(n *vdf.Node) (interface{}, error) { return n.BoolE() }, false, nil},
+		{"BoolTrue", "TRUE", func(n *vdf.Node) (interface{}, error) { return n.BoolE() }, true, nil},
+		{"BoolFalse", "false", func(n *vdf.Node) (interface{}, error) { return n.BoolE() }, false, nil},
+		{"BoolInt", int32(2), func(n *vdf.Node) (interface{}, error) { return n.BoolE() }, true, nil},
+		{"BoolInvalid", "yes please", func(n *vdf.Node) (interface{}, error) { return n.BoolE() }, false, strconv.ErrSyntax},
+		{"DurationSeconds", "1.5", func(n *vdf.Node) (interface{}, error) { return n.DurationE() }, 1500 * time.Millisecond, nil},
+		{"DurationInt", int32(90), func(n *vdf.Node) (interface{}, error) { return n.DurationE() }, 90 * time.Second, nil},
+		{"DurationUnits", "1m30s", func(n *vdf.Node) (interface{}, error) { return n.DurationE() }, 90 * time.Second, nil},
+		{"DurationInvalid", "soon", func(n *vdf.Node) (interface{}, error) { return n.DurationE() }, time.Duration(0), strconv.ErrSyntax},
+		{"DurationOverflow", "1e20", func(n *vdf.Node) (interface{}, error) { return n.DurationE() }, time.Duration(0), strconv.ErrRange},
+	}
+
+	for _, tt := range tests {
+		tt := tt // shadow
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.f(checkedNode(tt.value))
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+			if tt.err == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var ve *vdf.ValueError
+			if !errors.As(err, &ve) || ve.Name != "key" || !errors.Is(err, tt.err) {
+				t.Errorf("expected *ValueError wrapping %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckedAccessorsNoValue(t *testing.T) {
+	t.Parallel()
+
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte(`"root" { "sub" { "a" "1" } }`)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []*vdf.Node{n.FirstByName("sub"), n.FirstByName("missing")} {
+		if _, err := c.IntE(); !errors.Is(err, vdf.ErrNoValue) {
+			t.Errorf("expected ErrNoValue, got %v", err)
+		}
+		if c.Bool() || c.Duration() != 0 {
+			t.Error("expected zero values")
+		}
+	}
+
+	_, err := n.FirstByName("sub").FloatE()
+	if expected := `vdf: key "sub" has no value to convert to float32`; err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+	_, err = checkedNode("x").Uint64E()
+	if expected := `vdf: cannot convert "x" in key "key" to uint64: invalid syntax`; err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
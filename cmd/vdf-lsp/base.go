@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BenLubar/vdf"
+	"github.com/BenLubar/vdf/lint"
+)
+
+// maxBaseDepth limits how deeply #base files are followed.
+const maxBaseDepth = 16
+
+// isDirective reports whether name is #base or #include. The files they
+// name are merged into the file that contains them, with the keys in the
+// including file taking precedence.
+func isDirective(name string) bool {
+	return strings.EqualFold(name, "#base") || strings.EqualFold(name, "#include")
+}
+
+// resolve returns the path of the file named by a directive in the file at
+// from. Paths are relative to the directory containing the including file.
+func resolve(from, name string) string {
+	name = filepath.FromSlash(strings.Replace(name, `\`, "/", -1))
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(from), name)
+}
+
+// baseFile is a file included by a document, directly or indirectly.
+type baseFile struct {
+	path string
+	root *vdf.Node
+}
+
+// readFile returns the contents of the file at path, preferring the text of
+// an open document.
+func (s *server) readFile(path string) ([]byte, error) {
+	if d, ok := s.docs[pathToURI(path)]; ok {
+		return d.text, nil
+	}
+	return ioutil.ReadFile(path)
+}
+
+// bases returns the files included by the document at path, in the order
+// they are merged. Files that cannot be read or parsed are skipped.
+func (s *server) bases(path string, names []string) []baseFile {
+	var files []baseFile
+	seen := map[string]bool{path: true}
+
+	var visit func(from string, names []string, depth int)
+	visit = func(from string, names []string, depth int) {
+		if depth > maxBaseDepth {
+			return
+		}
+		for _, name := range names {
+			p := resolve(from, name)
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+
+			b, err := s.readFile(p)
+			if err != nil {
+				continue
+			}
+			first := new(vdf.Node)
+			if err = first.UnmarshalText(b); err != nil {
+				continue
+			}
+
+			var nested []string
+			var root *vdf.Node
+			for n := first; n != nil; n = n.NextChild() {
+				if isDirective(n.Name()) {
+					nested = append(nested, n.String())
+				} else if root == nil {
+					root = n
+				}
+			}
+			if root != nil {
+				files = append(files, baseFile{path: p, root: root})
+			}
+			visit(p, nested, depth+1)
+		}
+	}
+	visit(path, names, 0)
+
+	return files
+}
+
+// directives returns the names of the files included by d.
+func (d *document) directives() []string {
+	var names []string
+	for _, n := range d.file.Nodes {
+		if n.Value != nil && isDirective(n.Key.Text) {
+			names = append(names, n.Value.Text)
+		}
+	}
+	return names
+}
+
+// hover describes the file named by a directive, or the values of a key
+// that are overridden by the document's #base files.
+func (s *server) hover(d *document, offset int) *hover {
+	tok, path := d.find(offset)
+	if tok == nil {
+		return nil
+	}
+	docPath := uriToPath(d.uri)
+	if docPath == "" {
+		return nil
+	}
+
+	var b strings.Builder
+	n := path[len(path)-1]
+	if len(path) == 1 && n.Value != nil && isDirective(n.Key.Text) {
+		p := resolve(docPath, n.Value.Text)
+		fmt.Fprintf(&b, "`%s`", p)
+		if _, err := s.readFile(p); err != nil {
+			b.WriteString("\n\nfile not found")
+		}
+	} else {
+		var found []string
+		for _, f := range s.bases(docPath, d.directives()) {
+			c := lookup(f.root, path[1:])
+			if c == nil {
+				continue
+			}
+			rel, err := filepath.Rel(filepath.Dir(docPath), f.path)
+			if err != nil {
+				rel = f.path
+			}
+			v := "{ ... }"
+			if c.Kind() != vdf.KindSubtree {
+				v = fmt.Sprintf("%q", c.String())
+			}
+			found = append(found, fmt.Sprintf("- `%s` in `%s`", v, filepath.ToSlash(rel)))
+		}
+		if len(found) == 0 {
+			return nil
+		}
+
+		fmt.Fprintf(&b, "**%s**", n.Key.Text)
+		if n.Value != nil {
+			fmt.Fprintf(&b, " `%q`", n.Value.Text)
+		}
+		b.WriteString("\n\nOverrides #base values:\n\n")
+		b.WriteString(strings.Join(found, "\n"))
+	}
+
+	r := d.tokenRange(*tok)
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: b.String()},
+		Range:    &r,
+	}
+}
+
+// lookup follows the keys in path from root, returning the node found.
+func lookup(root *vdf.Node, path []*lint.Node) *vdf.Node {
+	n := root
+	for _, pn := range path {
+		if n = n.FirstByName(pn.Key.Text); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// definition returns the location of the file named by the directive at
+// offset, if it exists.
+func (s *server) definition(d *document, offset int) []location {
+	tok, path := d.find(offset)
+	if tok == nil || len(path) != 1 {
+		return nil
+	}
+	n := path[0]
+	docPath := uriToPath(d.uri)
+	if n.Value == nil || !isDirective(n.Key.Text) || docPath == "" {
+		return nil
+	}
+
+	p := resolve(docPath, n.Value.Text)
+	if _, ok := s.docs[pathToURI(p)]; !ok {
+		if _, err := os.Stat(p); err != nil {
+			return nil
+		}
+	}
+	return []location{{URI: pathToURI(p)}}
+}
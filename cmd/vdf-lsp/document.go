@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/BenLubar/vdf/lint"
+)
+
+// document is an open text document.
+type document struct {
+	uri     string
+	version int
+	text    []byte
+	lines   []int // offset of the start of each line
+	file    *lint.File
+}
+
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version, text: []byte(text)}
+	d.lines = append(d.lines, 0)
+	for i, c := range d.text {
+		if c == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	// On a syntax error, file holds the part before it. The error itself
+	// is reported by diagnostics.
+	d.file, _ = lint.Parse(d.text)
+	return d
+}
+
+// position converts an offset into d.text to a protocol position.
+func (d *document) position(offset int) position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.SearchInts(d.lines, offset+1) - 1
+	start := d.lines[line]
+	return position{Line: line, Character: utf16Len(d.text[start:offset])}
+}
+
+func (d *document) tokenRange(t lint.Token) lspRange {
+	return lspRange{Start: d.position(t.Pos.Offset), End: d.position(t.End.Offset)}
+}
+
+// offset converts a protocol position to an offset into d.text. Positions
+// past the end of a line refer to the end of the line.
+func (d *document) offset(p position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	i := d.lines[p.Line]
+	for n := 0; n < p.Character && i < len(d.text) && d.text[i] != '\n'; {
+		r, size := utf8.DecodeRune(d.text[i:])
+		n += runeLen16(r)
+		i += size
+	}
+	return i
+}
+
+// fullRange is the range of the whole document.
+func (d *document) fullRange() lspRange {
+	return lspRange{End: d.position(len(d.text))}
+}
+
+func utf16Len(b []byte) int {
+	n := 0
+	for len(b) != 0 {
+		r, size := utf8.DecodeRune(b)
+		n += runeLen16(r)
+		b = b[size:]
+	}
+	return n
+}
+
+// runeLen16 returns the number of UTF-16 code units needed for r. Invalid
+// UTF-8 is decoded as U+FFFD, which needs one.
+func runeLen16(r rune) int {
+	if l := utf16.RuneLen(r); l > 0 {
+		return l
+	}
+	return 1
+}
+
+// find returns the token at offset and the keys leading to it, starting at
+// the root. The token is the key or value of the last node in path.
+func (d *document) find(offset int) (tok *lint.Token, path []*lint.Node) {
+	var search func(nodes []*lint.Node) bool
+	search = func(nodes []*lint.Node) bool {
+		for _, n := range nodes {
+			path = append(path, n)
+			if contains(n.Key, offset) {
+				tok = &n.Key
+				return true
+			}
+			if n.Value != nil && contains(*n.Value, offset) {
+				tok = n.Value
+				return true
+			}
+			if search(n.Children) {
+				return true
+			}
+			path = path[:len(path)-1]
+		}
+		return false
+	}
+	if search(d.file.Nodes) {
+		return tok, path
+	}
+	return nil, nil
+}
+
+// contains reports whether offset is within t, including the position just
+// after it.
+func contains(t lint.Token, offset int) bool {
+	return t.Pos.Offset <= offset && offset <= t.End.Offset
+}
+
+// uriToPath converts a file URI to a path. It returns "" for other URIs.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC error codes used by the server.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// message is a JSON-RPC request, notification, or response. Requests have
+// an ID and a method, notifications have only a method, and responses have
+// only an ID.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *rpcError) Error() string {
+	return fmt.Sprintf("jsonrpc: %s (code %d)", err.Message, err.Code)
+}
+
+// conn reads and writes messages framed with Content-Length headers, as
+// described in the Language Server Protocol specification.
+type conn struct {
+	r  *textproto.Reader
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: textproto.NewReader(bufio.NewReader(r)), w: w}
+}
+
+func (c *conn) read() (*message, error) {
+	header, err := c.r.ReadMIMEHeader()
+	var perr textproto.ProtocolError
+	if errors.As(err, &perr) {
+		// A malformed line is skipped with the rest of the header, and
+		// the body is skipped as if it had no length.
+		if err = c.skipHeader(); err == nil {
+			err = c.skipToHeader()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, &rpcError{Code: codeParseError, Message: string(perr)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		// Without a length, the body can't be found, so skip to the
+		// header of the next message.
+		if err = c.skipToHeader(); err != nil {
+			return nil, err
+		}
+		return nil, &rpcError{Code: codeParseError, Message: fmt.Sprintf("invalid Content-Length %q", header.Get("Content-Length"))}
+	}
+
+	// The length is not trusted for the size of the buffer, which only
+	// grows as the body is read.
+	body, err := ioutil.ReadAll(io.LimitReader(c.r.R, int64(length)))
+	if err == nil && len(body) != length {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m message
+	if err = json.Unmarshal(body, &m); err != nil {
+		return nil, &rpcError{Code: codeParseError, Message: err.Error()}
+	}
+	return &m, nil
+}
+
+// headerPrefix starts both of the headers defined for a message,
+// Content-Length and Content-Type.
+const headerPrefix = "Content-"
+
+// skipHeader discards the lines of a header up to and including the blank
+// line that ends it.
+func (c *conn) skipHeader() error {
+	for {
+		line, err := c.r.ReadLine()
+		if err != nil || line == "" {
+			return err
+		}
+	}
+}
+
+// skipToHeader discards input up to the next message header or the end of
+// the input.
+func (c *conn) skipToHeader() error {
+	for {
+		b, err := c.r.R.Peek(len(headerPrefix))
+		if err == io.EOF {
+			_, err = c.r.R.Discard(len(b))
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(string(b), headerPrefix) {
+			return nil
+		}
+		if _, err = c.r.R.Discard(1); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *conn) write(v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+// reply sends the response to the request with the given ID. If err is not
+// nil, it is sent instead of result.
+func (c *conn) reply(id *json.RawMessage, result interface{}, err error) error {
+	if err != nil {
+		rerr, ok := err.(*rpcError)
+		if !ok {
+			rerr = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		return c.write(struct {
+			JSONRPC string           `json:"jsonrpc"`
+			ID      *json.RawMessage `json:"id"`
+			Error   *rpcError        `json:"error"`
+		}{"2.0", id, rerr})
+	}
+
+	return c.write(struct {
+		JSONRPC string           `json:"jsonrpc"`
+		ID      *json.RawMessage `json:"id"`
+		Result  interface{}      `json:"result"`
+	}{"2.0", id, result})
+}
+
+// notify sends a notification.
+func (c *conn) notify(method string, params interface{}) error {
+	return c.write(struct {
+		JSONRPC string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params"`
+	}{"2.0", method, params})
+}
@@ -0,0 +1,33 @@
+// Command vdf-lsp is a language server for text KeyValues files.
+//
+// Usage:
+//
+//	vdf-lsp
+//
+// The server speaks the Language Server Protocol over standard input and
+// standard output. It provides:
+//
+//   - Diagnostics from the parser and from the rules in package lint.
+//   - Document symbols for subtrees.
+//   - Folding ranges for {} blocks.
+//   - Formatting, with the same result as vdf fmt.
+//   - Hover for #base and #include directives, showing the file they name,
+//     and for keys, showing the values they override in #base files.
+//   - Go to definition for the files named by #base and #include.
+//
+// Documents are synchronized in full on every change. Files named by #base
+// and #include are resolved relative to the directory of the including
+// file, and are read from disk unless they are open in the editor.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if err := newServer(os.Stdin, os.Stdout).run(); err != nil {
+		fmt.Fprintln(os.Stderr, "vdf-lsp:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/BenLubar/vdf"
+	"github.com/BenLubar/vdf/lint"
+)
+
+// server handles the messages from one client.
+type server struct {
+	conn     *conn
+	docs     map[string]*document
+	shutdown bool
+}
+
+func newServer(r io.Reader, w io.Writer) *server {
+	return &server{conn: newConn(r, w), docs: make(map[string]*document)}
+}
+
+// errExit is returned by run when the client sends an exit notification
+// without first sending a shutdown request.
+var errExit = &rpcError{Code: codeInvalidRequest, Message: "exit before shutdown"}
+
+// run handles messages until the client sends an exit notification or
+// closes the connection.
+func (s *server) run() error {
+	for {
+		m, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if rerr, ok := err.(*rpcError); ok {
+			if err = s.conn.reply(nil, nil, rerr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if m.Method == "exit" {
+			if !s.shutdown {
+				return errExit
+			}
+			return nil
+		}
+
+		result, err := s.handle(m)
+		if m.ID == nil {
+			// Notifications have no response, even on failure.
+			continue
+		}
+		if err = s.conn.reply(m.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) handle(m *message) (interface{}, error) {
+	if s.shutdown && m.Method != "shutdown" {
+		return nil, &rpcError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch m.Method {
+	case "initialize":
+		var result initializeResult
+		result.Capabilities = serverCapabilities{
+			TextDocumentSync:           syncFull,
+			DocumentSymbolProvider:     true,
+			FoldingRangeProvider:       true,
+			DocumentFormattingProvider: true,
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+		}
+		result.ServerInfo.Name = "vdf-lsp"
+		return result, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := unmarshalParams(m, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.open(newDocument(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text))
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := unmarshalParams(m, &p); err != nil {
+			return nil, err
+		}
+		if len(p.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// With full synchronization, the last change is the whole text.
+		text := p.ContentChanges[len(p.ContentChanges)-1].Text
+		return nil, s.open(newDocument(p.TextDocument.URI, p.TextDocument.Version, text))
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := unmarshalParams(m, &p); err != nil {
+			return nil, err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         p.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+
+	case "textDocument/documentSymbol":
+		d, err := s.document(m)
+		if err != nil {
+			return nil, err
+		}
+		return d.symbols(d.file.Nodes), nil
+	case "textDocument/foldingRange":
+		d, err := s.document(m)
+		if err != nil {
+			return nil, err
+		}
+		return d.foldingRanges(), nil
+	case "textDocument/formatting":
+		d, err := s.document(m)
+		if err != nil {
+			return nil, err
+		}
+		return d.format(), nil
+	case "textDocument/hover":
+		d, p, err := s.documentPosition(m)
+		if err != nil {
+			return nil, err
+		}
+		return s.hover(d, p), nil
+	case "textDocument/definition":
+		d, p, err := s.documentPosition(m)
+		if err != nil {
+			return nil, err
+		}
+		return s.definition(d, p), nil
+	}
+
+	if m.ID == nil || strings.HasPrefix(m.Method, "$/") {
+		return nil, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + m.Method}
+}
+
+func unmarshalParams(m *message, v interface{}) error {
+	if err := json.Unmarshal(m.Params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// open stores d and publishes its diagnostics.
+func (s *server) open(d *document) error {
+	s.docs[d.uri] = d
+	return s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         d.uri,
+		Version:     d.version,
+		Diagnostics: d.diagnostics(),
+	})
+}
+
+func (s *server) document(m *message) (*document, error) {
+	var p documentParams
+	if err := unmarshalParams(m, &p); err != nil {
+		return nil, err
+	}
+	return s.lookup(p.TextDocument.URI)
+}
+
+func (s *server) documentPosition(m *message) (*document, int, error) {
+	var p textDocumentPositionParams
+	if err := unmarshalParams(m, &p); err != nil {
+		return nil, 0, err
+	}
+	d, err := s.lookup(p.TextDocument.URI)
+	if err != nil {
+		return nil, 0, err
+	}
+	return d, d.offset(p.Position), nil
+}
+
+func (s *server) lookup(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "document is not open: " + uri}
+	}
+	return d, nil
+}
+
+// diagnostics returns the problems found by the linter. If the linter's
+// parser accepts the document but the vdf package does not, the error from
+// the vdf package is reported at the start of the document.
+func (d *document) diagnostics() []diagnostic {
+	diags := []diagnostic{}
+	syntax := false
+	for _, ld := range lint.Lint(d.text, lint.DefaultRules) {
+		severity := severityWarning
+		if ld.Severity == lint.SeverityError {
+			severity = severityError
+		}
+		if ld.Rule == lint.SyntaxRule {
+			syntax = true
+		}
+		diags = append(diags, diagnostic{
+			Range:    lspRange{Start: d.position(ld.Pos.Offset), End: d.position(ld.End.Offset)},
+			Severity: severity,
+			Code:     ld.Rule,
+			Source:   "vdf-lint",
+			Message:  ld.Message,
+		})
+	}
+
+	if !syntax {
+		var n vdf.Node
+		if err := n.UnmarshalText(d.text); err != nil {
+			diags = append(diags, diagnostic{
+				Severity: severityError,
+				Source:   "vdf",
+				Message:  err.Error(),
+			})
+		}
+	}
+	return diags
+}
+
+// symbols returns a symbol for each subtree in nodes.
+func (d *document) symbols(nodes []*lint.Node) []documentSymbol {
+	symbols := []documentSymbol{}
+	for _, n := range nodes {
+		if n.Value != nil {
+			continue
+		}
+		sym := documentSymbol{
+			Name:           n.Key.Text,
+			Kind:           symbolKindObject,
+			Range:          lspRange{Start: d.position(n.Key.Pos.Offset), End: d.position(nodeEnd(n).Offset)},
+			SelectionRange: d.tokenRange(n.Key),
+			Children:       d.symbols(n.Children),
+		}
+		if sym.Name == "" {
+			// Clients reject symbols with empty names.
+			sym.Name = `""`
+		}
+		if n.Condition != nil {
+			sym.Detail = n.Condition.Text
+		}
+		symbols = append(symbols, sym)
+	}
+	return symbols
+}
+
+// nodeEnd returns the position just past the end of n.
+func nodeEnd(n *lint.Node) lint.Pos {
+	switch {
+	case n.Close != nil:
+		return n.Close.End
+	case len(n.Children) != 0:
+		return nodeEnd(n.Children[len(n.Children)-1])
+	case n.Open != nil:
+		return n.Open.End
+	case n.Condition != nil:
+		return n.Condition.End
+	case n.Value != nil:
+		return n.Value.End
+	}
+	return n.Key.End
+}
+
+// foldingRanges returns a range for each {} block that spans more than one
+// line.
+func (d *document) foldingRanges() []foldingRange {
+	ranges := []foldingRange{}
+	d.file.Walk(func(n *lint.Node) {
+		if n.Open == nil || n.Close == nil {
+			return
+		}
+		start, end := d.position(n.Open.Pos.Offset).Line, d.position(n.Close.Pos.Offset).Line
+		if start < end {
+			ranges = append(ranges, foldingRange{StartLine: start, EndLine: end})
+		}
+	})
+	return ranges
+}
+
+// format returns an edit replacing the document with its canonical form, as
+// produced by vdf fmt. It returns nil if the document cannot be parsed.
+func (d *document) format() []textEdit {
+	var n vdf.Node
+	if err := n.UnmarshalText(d.text); err != nil {
+		return nil
+	}
+	n.ClearFormatting()
+	b, err := n.MarshalText()
+	if err != nil {
+		return nil
+	}
+	if bytes.Equal(b, d.text) {
+		return []textEdit{}
+	}
+	return []textEdit{{Range: d.fullRange(), NewText: string(b)}}
+}
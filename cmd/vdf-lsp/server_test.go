@@ -0,0 +1,445 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// client is a scripted language client connected to a server running in
+// the same process.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	// notifications holds the notifications received while waiting for
+	// responses, in order.
+	notifications []*message
+	done          chan error
+}
+
+func newClient(t *testing.T) *client {
+	sr, cw := io.Pipe()
+	cr, sw := io.Pipe()
+
+	c := &client{t: t, conn: newConn(cr, cw), done: make(chan error, 1)}
+	go func() {
+		err := newServer(sr, sw).run()
+		sw.Close()
+		c.done <- err
+	}()
+	t.Cleanup(func() {
+		cw.Close()
+		cr.Close()
+	})
+	return c
+}
+
+// call sends a request and stores the result in result. It returns the
+// error sent by the server, if any.
+func (c *client) call(method string, params, result interface{}) *rpcError {
+	c.t.Helper()
+
+	c.nextID++
+	id := json.RawMessage(jsonString(c.t, c.nextID))
+	if err := c.conn.write(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      &id,
+		"method":  method,
+		"params":  params,
+	}); err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		m, err := c.conn.read()
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if m.ID == nil {
+			c.notifications = append(c.notifications, m)
+			continue
+		}
+		if string(*m.ID) != string(id) {
+			c.t.Fatalf("response to %s, expected %s", *m.ID, id)
+		}
+		if m.Error != nil {
+			return m.Error
+		}
+		if result != nil {
+			if err = json.Unmarshal(m.Result, result); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// diagnostics waits for the next diagnostics published for uri.
+func (c *client) diagnostics(uri string) []diagnostic {
+	c.t.Helper()
+
+	for {
+		var m *message
+		if len(c.notifications) != 0 {
+			m, c.notifications = c.notifications[0], c.notifications[1:]
+		} else {
+			var err error
+			if m, err = c.conn.read(); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		if m.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var p publishDiagnosticsParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			c.t.Fatal(err)
+		}
+		if p.URI == uri {
+			return p.Diagnostics
+		}
+	}
+}
+
+func jsonString(t *testing.T, v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+const baseText = `"Resource/base.res"
+{
+	"Panel"
+	{
+		"xpos"	"20"
+		"ypos"	"5"
+	}
+}
+`
+
+const mainText = `#base "base.res"
+
+"Resource/main.res"
+{
+	"Panel"
+	{
+		"xpos"	"10"
+	}
+}
+`
+
+func setup(t *testing.T) (*client, string) {
+	dir, err := ioutil.TempDir("", "vdf-lsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err = ioutil.WriteFile(filepath.Join(dir, "base.res"), []byte(baseText), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t)
+	var init initializeResult
+	if err := c.call("initialize", map[string]interface{}{}, &init); err != nil {
+		t.Fatal(err)
+	}
+	if !init.Capabilities.HoverProvider || init.Capabilities.TextDocumentSync != syncFull {
+		t.Errorf("unexpected capabilities: %+v", init.Capabilities)
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	uri := pathToURI(filepath.Join(dir, "main.res"))
+	c.notify("textDocument/didOpen", didOpenParams{TextDocument: textDocumentItem{
+		URI:        uri,
+		LanguageID: "vdf",
+		Version:    1,
+		Text:       mainText,
+	}})
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics: %+v", diags)
+	}
+	return c, uri
+}
+
+func textDocument(uri string) map[string]interface{} {
+	return map[string]interface{}{"textDocument": textDocumentIdentifier{URI: uri}}
+}
+
+func at(uri string, line, character int) textDocumentPositionParams {
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: uri},
+		Position:     position{Line: line, Character: character},
+	}
+}
+
+func TestServer(t *testing.T) {
+	c, uri := setup(t)
+
+	t.Run("Symbols", func(t *testing.T) {
+		var symbols []documentSymbol
+		if err := c.call("textDocument/documentSymbol", textDocument(uri), &symbols); err != nil {
+			t.Fatal(err)
+		}
+		if len(symbols) != 1 || symbols[0].Name != "Resource/main.res" {
+			t.Fatalf("unexpected symbols: %+v", symbols)
+		}
+		if expected := (lspRange{Start: position{2, 0}, End: position{8, 1}}); symbols[0].Range != expected {
+			t.Errorf("root range is %+v, expected %+v", symbols[0].Range, expected)
+		}
+		if children := symbols[0].Children; len(children) != 1 || children[0].Name != "Panel" || len(children[0].Children) != 0 {
+			t.Errorf("unexpected children: %+v", children)
+		}
+	})
+
+	t.Run("Folding", func(t *testing.T) {
+		var ranges []foldingRange
+		if err := c.call("textDocument/foldingRange", textDocument(uri), &ranges); err != nil {
+			t.Fatal(err)
+		}
+		if expected := []foldingRange{{3, 8}, {5, 7}}; !reflect.DeepEqual(ranges, expected) {
+			t.Errorf("folding ranges are %+v, expected %+v", ranges, expected)
+		}
+	})
+
+	t.Run("Hover", func(t *testing.T) {
+		var h hover
+		if err := c.call("textDocument/hover", at(uri, 6, 3), &h); err != nil {
+			t.Fatal(err)
+		}
+		if expected := "**xpos** `\"10\"`\n\nOverrides #base values:\n\n- `\"20\"` in `base.res`"; h.Contents.Value != expected {
+			t.Errorf("hover is %q, expected %q", h.Contents.Value, expected)
+		}
+
+		if err := c.call("textDocument/hover", at(uri, 0, 8), &h); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(h.Contents.Value, "base.res`") {
+			t.Errorf("unexpected hover for #base: %q", h.Contents.Value)
+		}
+
+		var none *hover
+		if err := c.call("textDocument/hover", at(uri, 1, 0), &none); err != nil {
+			t.Fatal(err)
+		}
+		if none != nil {
+			t.Errorf("unexpected hover for blank line: %+v", none)
+		}
+	})
+
+	t.Run("Definition", func(t *testing.T) {
+		var locs []location
+		if err := c.call("textDocument/definition", at(uri, 0, 8), &locs); err != nil {
+			t.Fatal(err)
+		}
+		expected := strings.TrimSuffix(uri, "main.res") + "base.res"
+		if len(locs) != 1 || locs[0].URI != expected {
+			t.Errorf("definition is %+v, expected %s", locs, expected)
+		}
+	})
+
+	t.Run("Formatting", func(t *testing.T) {
+		c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []map[string]string{{"text": "a{b c}"}},
+		})
+		if diags := c.diagnostics(uri); len(diags) != 0 {
+			t.Errorf("unexpected diagnostics: %+v", diags)
+		}
+
+		var edits []textEdit
+		if err := c.call("textDocument/formatting", textDocument(uri), &edits); err != nil {
+			t.Fatal(err)
+		}
+		expected := []textEdit{{
+			Range:   lspRange{End: position{0, 6}},
+			NewText: "\"a\" {\n\t\"b\" \"c\"\n}\n",
+		}}
+		if !reflect.DeepEqual(edits, expected) {
+			t.Errorf("edits are %+v, expected %+v", edits, expected)
+		}
+	})
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 3},
+			"contentChanges": []map[string]string{{"text": "\"a\"\n{\n\t\"b\" \"1\"\n\t\"b\" \"2\"\n"}},
+		})
+		diags := c.diagnostics(uri)
+		var codes []string
+		for _, d := range diags {
+			codes = append(codes, d.Code)
+		}
+		if expected := []string{"duplicate-key", "syntax"}; !reflect.DeepEqual(codes, expected) {
+			t.Errorf("diagnostics are %+v, expected codes %q", diags, expected)
+		}
+		if len(diags) == 2 && diags[0].Range != (lspRange{Start: position{3, 1}, End: position{3, 4}}) {
+			t.Errorf("duplicate key range is %+v", diags[0].Range)
+		}
+
+		var edits []textEdit
+		if err := c.call("textDocument/formatting", textDocument(uri), &edits); err != nil {
+			t.Fatal(err)
+		}
+		if edits != nil {
+			t.Errorf("unexpected edits for invalid document: %+v", edits)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if err := c.call("textDocument/unknown", textDocument(uri), nil); err == nil || err.Code != codeMethodNotFound {
+			t.Errorf("unknown method returned %v", err)
+		}
+		if err := c.call("textDocument/documentSymbol", textDocument("file:///missing.res"), nil); err == nil || err.Code != codeInvalidParams {
+			t.Errorf("closed document returned %v", err)
+		}
+	})
+
+	c.notify("textDocument/didClose", textDocument(uri))
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("diagnostics were not cleared: %+v", diags)
+	}
+
+	if err := c.call("shutdown", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("server exited with %v", err)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	t.Parallel()
+
+	c := newClient(t)
+	c.notify("exit", nil)
+	if err := <-c.done; err != errExit {
+		t.Errorf("server exited with %v, expected %v", err, errExit)
+	}
+}
+
+func TestInvalidContentLength(t *testing.T) {
+	t.Parallel()
+
+	c := newClient(t)
+	var in strings.Builder
+	for _, header := range []string{"Content-Length: x", "Content-Length: -1", "Content-Type: text/plain"} {
+		in.WriteString(header + "\r\n\r\n{\"jsonrpc\":\"2.0\",\"method\":\"initialized\"}")
+	}
+	body := `{"jsonrpc":"2.0","id":1,"method":"shutdown"}`
+	fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+
+	// The server replies to each bad message before it reads the next,
+	// so the input has to be written while the replies are read.
+	go io.WriteString(c.conn.w, in.String())
+
+	parseErrors := 0
+	for {
+		m, err := c.conn.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.ID == nil {
+			if m.Error == nil || m.Error.Code != codeParseError {
+				t.Errorf("expected parse error, got %+v", m)
+			}
+			parseErrors++
+			continue
+		}
+		if string(*m.ID) != "1" || m.Error != nil {
+			t.Errorf("unexpected response %+v", m)
+		}
+		break
+	}
+	if parseErrors != 3 {
+		t.Errorf("expected 3 parse errors, got %d", parseErrors)
+	}
+
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("server exited with %v", err)
+	}
+}
+
+func TestMalformedHeader(t *testing.T) {
+	t.Parallel()
+
+	c := newClient(t)
+	var in strings.Builder
+	for _, header := range []string{"Content-Length 40", "Content-Length: 40\r\nbogus", "Content-Type: text/plain\r\nContent-Length"} {
+		in.WriteString(header + "\r\n\r\n{\"jsonrpc\":\"2.0\",\"method\":\"initialized\"}")
+	}
+	body := `{"jsonrpc":"2.0","id":1,"method":"shutdown"}`
+	fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+
+	go io.WriteString(c.conn.w, in.String())
+
+	parseErrors := 0
+	for {
+		m, err := c.conn.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.ID == nil {
+			if m.Error == nil || m.Error.Code != codeParseError {
+				t.Errorf("expected parse error, got %+v", m)
+			}
+			parseErrors++
+			continue
+		}
+		if string(*m.ID) != "1" || m.Error != nil {
+			t.Errorf("unexpected response %+v", m)
+		}
+		break
+	}
+	if parseErrors != 3 {
+		t.Errorf("expected 3 parse errors, got %d", parseErrors)
+	}
+
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("server exited with %v", err)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	t.Parallel()
+
+	d := newDocument("file:///a.res", 0, "\"é\U0001F600\" x\nb")
+	for _, tt := range []struct {
+		offset int
+		pos    position
+	}{
+		{0, position{0, 0}},
+		{1, position{0, 1}},
+		{3, position{0, 2}},
+		{7, position{0, 4}},
+		{10, position{0, 7}},
+		{11, position{1, 0}},
+		{12, position{1, 1}},
+	} {
+		if p := d.position(tt.offset); p != tt.pos {
+			t.Errorf("position(%d) = %+v, expected %+v", tt.offset, p, tt.pos)
+		}
+		if o := d.offset(tt.pos); o != tt.offset {
+			t.Errorf("offset(%+v) = %d, expected %d", tt.pos, o, tt.offset)
+		}
+	}
+}
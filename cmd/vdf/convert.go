@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/BenLubar/vdf"
+)
+
+var convertCommand = &command{
+	name:  "convert",
+	usage: "[-from format] [-to format] [-types] [-infer] [file]",
+	short: "convert between text, binary, and JSON KeyValues",
+	run:   runConvert,
+}
+
+func runConvert(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	from := fs.String("from", "auto", "input `format`: auto, text, binary, or json")
+	to := fs.String("to", "text", "output `format`: text, binary, or json")
+	types := fs.Bool("types", false, "annotate text output with value types and read them from text input")
+	infer := fs.Bool("infer", false, "store integers and decimal numbers from text input as numbers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dopts := &vdf.DecodeOptions{TypeAnnotations: *types}
+	eopts := &vdf.EncodeOptions{TypeAnnotations: *types}
+	if *infer {
+		dopts.InferTypes = &vdf.InferOptions{Strict: true}
+	}
+
+	in, err := readInput(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+
+	if *from == "auto" {
+		*from = detectFormat(in)
+	}
+
+	n, err := decode(in, *from, dopts)
+	if err != nil {
+		return err
+	}
+
+	out, err := encode(n, *to, eopts)
+	if err != nil {
+		return err
+	}
+
+	_, err = stdout.Write(out)
+	return err
+}
+
+// readInput reads the file named by the only argument, or stdin if there are
+// no arguments.
+func readInput(args []string, stdin io.Reader) ([]byte, error) {
+	switch len(args) {
+	case 0:
+		return ioutil.ReadAll(stdin)
+	case 1:
+		return ioutil.ReadFile(args[0])
+	default:
+		return nil, errUsage
+	}
+}
+
+// detectFormat guesses the format of b. Binary KeyValues start with a pack
+// type, which is a control character, and JSON starts with an object. Text
+// KeyValues start with a key or a comment.
+func detectFormat(b []byte) string {
+	if len(b) != 0 && b[0] < '\t' {
+		return "binary"
+	}
+	if b = bytes.TrimSpace(b); len(b) != 0 && b[0] == '{' {
+		return "json"
+	}
+	return "text"
+}
+
+func decode(b []byte, format string, opts *vdf.DecodeOptions) (*vdf.Node, error) {
+	var n *vdf.Node
+	var err error
+	switch format {
+	case "text":
+		n, err = vdf.DecodeText(b, opts)
+	case "binary":
+		n, err = vdf.DecodeBinary(b, opts)
+	case "json":
+		n, err = decodeJSON(b)
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func encode(n *vdf.Node, format string, opts *vdf.EncodeOptions) ([]byte, error) {
+	switch format {
+	case "text":
+		return vdf.EncodeText(n, opts)
+	case "binary":
+		return vdf.EncodeBinary(n, opts)
+	case "json":
+		return encodeJSON(n)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
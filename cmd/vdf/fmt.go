@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/BenLubar/vdf"
+)
+
+var fmtCommand = &command{
+	name:  "fmt",
+	usage: "[-l] [-w] [file ...]",
+	short: "reformat text KeyValues",
+	run:   runFmt,
+}
+
+func runFmt(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	list := fs.Bool("l", false, "list files whose formatting differs")
+	write := fs.Bool("w", false, "write the result to the file instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		if *write {
+			return fmt.Errorf("cannot use -w with standard input")
+		}
+		in, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		out, err := format(in)
+		if err != nil {
+			return fmt.Errorf("<standard input>: %v", err)
+		}
+		if *list {
+			if !bytes.Equal(in, out) {
+				_, err = fmt.Fprintln(stdout, "<standard input>")
+			}
+			return err
+		}
+		_, err = stdout.Write(out)
+		return err
+	}
+
+	for _, name := range fs.Args() {
+		in, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		out, err := format(in)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if bytes.Equal(in, out) && (*list || *write) {
+			continue
+		}
+		if *list {
+			if _, err = fmt.Fprintln(stdout, name); err != nil {
+				return err
+			}
+		}
+		if *write {
+			if err = writeFile(name, out); err != nil {
+				return err
+			}
+		}
+		if !*list && !*write {
+			if _, err = stdout.Write(out); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// format returns the standard formatting of the text KeyValues in b.
+func format(b []byte) ([]byte, error) {
+	var n vdf.Node
+	if err := n.UnmarshalText(b); err != nil {
+		return nil, err
+	}
+	n.ClearFormatting()
+	return n.MarshalText()
+}
+
+// writeFile replaces the contents of an existing file, keeping its mode.
+func writeFile(name string, b []byte) error {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, b, fi.Mode().Perm())
+}
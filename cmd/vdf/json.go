@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/BenLubar/vdf"
+)
+
+// encodeJSON writes n and its siblings as the members of a JSON object.
+// Subtrees become objects and all other values become strings, so the
+// conversion does not depend on how the input stored its numbers. Keys keep
+// their order and duplicates are kept as repeated members. Conditions are
+// not represented.
+func encodeJSON(n *vdf.Node) ([]byte, error) {
+	sub := n.NextSubTree()
+	if n.Kind() == vdf.KindSubtree {
+		sub = n
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSONObject(&buf, n, sub); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "\t"); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// writeJSONObject writes first and its following siblings. sub is the first
+// of them that is a subtree.
+func writeJSONObject(buf *bytes.Buffer, first, sub *vdf.Node) error {
+	buf.WriteByte('{')
+	for c := first; c != nil; c = c.NextChild() {
+		if c != first {
+			buf.WriteByte(',')
+		}
+		if err := writeJSONString(buf, c.Name()); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+
+		var err error
+		if c == sub {
+			sub = c.NextSubTree()
+			err = writeJSONObject(buf, c.FirstChild(), c.FirstSubTree())
+		} else {
+			err = writeJSONString(buf, c.String())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	b, err := json.Marshal(s)
+	buf.Write(b)
+	return err
+}
+
+// decodeJSON reads a JSON object whose members are the root keys. Objects
+// become subtrees and arrays become subtrees whose children are named by
+// index, starting at "0". Numbers are kept as written, booleans become "1"
+// and "0", and null becomes an empty string.
+func decodeJSON(b []byte) (*vdf.Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, errors.New("JSON input must be an object")
+	}
+
+	var root vdf.Node
+	if err = readJSONMembers(dec, &root); err != nil {
+		return nil, err
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON object")
+	}
+	if root.FirstChild() == nil {
+		return nil, errors.New("JSON object has no members")
+	}
+
+	// Root keys can only be siblings of each other if they come from the
+	// same document, so write them out and read them back in together.
+	var text bytes.Buffer
+	for c := root.FirstChild(); c != nil; c = c.NextChild() {
+		t, err := c.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		text.Write(t)
+	}
+
+	n := new(vdf.Node)
+	if err = n.UnmarshalText(text.Bytes()); err != nil {
+		return nil, err
+	}
+	n.ClearFormatting()
+	return n, nil
+}
+
+func readJSONMembers(dec *json.Decoder, parent *vdf.Node) error {
+	// A new Node is an empty subtree until it is given a value, so an empty
+	// object or array needs no special handling.
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		c := new(vdf.Node)
+		c.SetName(tok.(string))
+		if err = readJSONValue(dec, c); err != nil {
+			return err
+		}
+		parent.Append(c)
+	}
+
+	_, err := dec.Token() // }
+	return err
+}
+
+func readJSONValue(dec *json.Decoder, n *vdf.Node) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '{' {
+			return readJSONMembers(dec, n)
+		}
+
+		for i := 0; dec.More(); i++ {
+			c := new(vdf.Node)
+			c.SetName(fmt.Sprint(i))
+			if err = readJSONValue(dec, c); err != nil {
+				return err
+			}
+			n.Append(c)
+		}
+		_, err = dec.Token() // ]
+		return err
+	case string:
+		n.SetString(v)
+	case json.Number:
+		n.SetString(v.String())
+	case bool:
+		if v {
+			n.SetString("1")
+		} else {
+			n.SetString("0")
+		}
+	case nil:
+		n.SetString("")
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/BenLubar/vdf/lint"
+)
+
+var lintCommand = &command{
+	name:  "lint",
+	usage: "[-json] [-rules list] [file ...]",
+	short: "report likely mistakes in text KeyValues",
+	run:   runLint,
+}
+
+// lintResult is the JSON form of a diagnostic.
+type lintResult struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Rule      string `json:"rule"`
+	Message   string `json:"message"`
+}
+
+func runLint(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	asJSON := fs.Bool("json", false, "write diagnostics as a JSON array")
+	names := fs.String("rules", "", "comma-separated `list` of rules to run (default all)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rules := lint.DefaultRules
+	if *names != "" {
+		rules = nil
+		for _, name := range strings.Split(*names, ",") {
+			r := findRule(name)
+			if r == nil {
+				return fmt.Errorf("unknown rule %q", name)
+			}
+			rules = append(rules, r)
+		}
+	}
+
+	results := []lintResult{}
+	count := 0
+	check := func(name string, src []byte) error {
+		for _, d := range lint.Lint(src, rules) {
+			count++
+			if *asJSON {
+				results = append(results, lintResult{
+					File:      name,
+					Line:      d.Pos.Line,
+					Column:    d.Pos.Column,
+					EndLine:   d.End.Line,
+					EndColumn: d.End.Column,
+					Severity:  d.Severity.String(),
+					Rule:      d.Rule,
+					Message:   d.Message,
+				})
+				continue
+			}
+			if _, err := fmt.Fprintf(stdout, "%s:%v\n", name, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if fs.NArg() == 0 {
+		src, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		if err = check("<standard input>", src); err != nil {
+			return err
+		}
+	}
+	for _, name := range fs.Args() {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err = check(name, src); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	}
+
+	if count != 0 {
+		return errFailed
+	}
+	return nil
+}
+
+func findRule(name string) lint.Rule {
+	for _, r := range lint.DefaultRules {
+		if r.Name() == name {
+			return r
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Command vdf reads, converts, and edits KeyValues files.
+//
+// Usage:
+//
+//	vdf <command> [arguments]
+//
+// The commands are:
+//
+//	fmt      reformat text KeyValues
+//	convert  convert between text, binary, and JSON KeyValues
+//	get      print values by path
+//	set      change a value by path, preserving formatting
+//	lint     report likely mistakes in text KeyValues
+//	validate check KeyValues against a schema
+//
+// Every command reads from standard input when no file is named and writes
+// to standard output unless asked to write a file, so they can be combined in
+// shell pipelines.
+//
+// A path is a list of key names separated by slashes, starting with the name
+// of the root key, as in "UserGameStatsSchema/stats/1/type". Names are
+// matched without regard to case, as they are by FirstByName.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	short string
+	run   func(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = []*command{
+	fmtCommand,
+	convertCommand,
+	getCommand,
+	setCommand,
+	lintCommand,
+	validateCommand,
+}
+
+// errUsage is returned by a command when its arguments are invalid.
+var errUsage = errors.New("usage")
+
+// errFailed is returned by a command that has already reported why it
+// failed.
+var errFailed = errors.New("failed")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprintf(stderr, "usage: vdf %s %s\n", cmd.name, cmd.usage)
+			fs.PrintDefaults()
+		}
+
+		err := cmd.run(fs, args[1:], stdin, stdout)
+		if err == flag.ErrHelp {
+			return 2
+		}
+		if err == errUsage {
+			fs.Usage()
+			return 2
+		}
+		if err == errFailed {
+			return 1
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "vdf %s: %v\n", cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	if args[0] != "help" && args[0] != "-h" && args[0] != "-help" {
+		fmt.Fprintf(stderr, "vdf: unknown command %q\n", args[0])
+	}
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: vdf <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The commands are:")
+	fmt.Fprintln(w)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "\t%-8s %s\n", cmd.name, cmd.short)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+const sample = `// comment
+"root"
+{
+	key    value
+	"sub" [$WIN32]
+	{
+		"x" "1"
+	}
+	"sub" { "x" "2" }
+}
+`
+
+func TestRun(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		in   string
+		out  string
+		code int
+	}{
+		{"fmt", []string{"fmt"}, sample, "// comment\n\"root\" {\n\t\"key\" \"value\"\n\t\"sub\" [$WIN32] {\n\t\t\"x\" \"1\"\n\t}\n\t\"sub\" {\n\t\t\"x\" \"2\"\n\t}\n}\n", 0},
+		{"fmt-l", []string{"fmt", "-l"}, sample, "<standard input>\n", 0},
+		{"fmt-l-clean", []string{"fmt", "-l"}, "\"a\" \"b\"\n", "", 0},
+		{"fmt-w-stdin", []string{"fmt", "-w"}, sample, "", 1},
+		{"fmt-error", []string{"fmt"}, "\"a\" {", "", 1},
+		{"get", []string{"get", "root/sub/x"}, sample, "1\n2\n", 0},
+		{"get-case", []string{"get", "ROOT/KEY"}, sample, "value\n", 0},
+		{"get-subtree", []string{"get", "root/sub"}, "\"root\" { \"sub\" { \"x\" \"1\" } }", "\"sub\" { \"x\" \"1\" } ", 0},
+		{"get-missing", []string{"get", "root/missing"}, sample, "", 1},
+		{"get-usage", []string{"get"}, sample, "", 2},
+		{"set", []string{"set", "root/key", "new value"}, sample, strings.Replace(sample, "value", "\"new value\"", 1), 0},
+		{"set-subtree", []string{"set", "root/sub", "x"}, "\"root\"   { \"sub\" { \"x\" \"1\" } }\n\"other\"   { \"y\"   \"2\" }\n", "\"root\"   { \t\"sub\" \"x\"\n}\n\"other\"   { \"y\"   \"2\" }\n", 0},
+		{"set-root", []string{"set", "root", "x"}, "\"root\"   { \"sub\" { \"x\" \"1\" } }\n\"other\"   { \"y\"   \"2\" }\n", "\"root\" \"x\"\n\"other\"   { \"y\"   \"2\" }\n", 0},
+		{"set-missing-root", []string{"set", "other/key", "x"}, sample, "", 1},
+		{"convert-json", []string{"convert", "-to", "json"}, sample, "{\n\t\"root\": {\n\t\t\"key\": \"value\",\n\t\t\"sub\": {\n\t\t\t\"x\": \"1\"\n\t\t},\n\t\t\"sub\": {\n\t\t\t\"x\": \"2\"\n\t\t}\n\t}\n}\n", 0},
+		{"convert-from-json", []string{"convert"}, `{"a": [1, {"b": null}], "c": {}, "d": true}`, "\"a\" {\n\t\"0\" \"1\"\n\t\"1\" {\n\t\t\"b\" \"\"\n\t}\n}\n\"c\" {\n}\n\"d\" \"1\"\n", 0},
+		{"convert-bad-format", []string{"convert", "-to", "yaml"}, sample, "", 1},
+		{"lint", []string{"lint"}, sample, "", 0},
+		{"lint-problems", []string{"lint"}, "\"root\" { \"a\" \"1\" \"A\" \"2\" }", "<standard input>:1:18: warning: key \"A\" differs only in case from \"a\" at 1:10 (key-case)\n", 1},
+		{"lint-rules", []string{"lint", "-rules", "duplicate-key"}, "\"root\" { \"a\" \"1\" \"A\" \"2\" }", "", 0},
+		{"lint-unknown-rule", []string{"lint", "-rules", "nope"}, sample, "", 1},
+		{"lint-json", []string{"lint", "-json"}, "\"root\" { \"a\" }", "[\n\t{\n\t\t\"file\": \"<standard input>\",\n\t\t\"line\": 1,\n\t\t\"column\": 10,\n\t\t\"endLine\": 1,\n\t\t\"endColumn\": 13,\n\t\t\"severity\": \"error\",\n\t\t\"rule\": \"trailing-text\",\n\t\t\"message\": \"key \\\"a\\\" has no value\"\n\t}\n]\n", 1},
+		{"lint-json-clean", []string{"lint", "-json"}, sample, "[]\n", 0},
+		{"validate", []string{"validate", "-schema", "../../schema/testdata/stats.vdf"}, "\"630\" { \"version\" \"1\" \"stats\" {} }", "", 0},
+		{"validate-errors", []string{"validate", "-schema", "../../schema/testdata/stats.vdf"}, "\"630\" { \"version\" \"x\" }", "<standard input>: 630/version: expected int, found \"x\"\n<standard input>: 630: missing required key \"stats\"\n", 1},
+		{"validate-json", []string{"validate", "-json", "-schema", "../../schema/testdata/stats.vdf"}, "\"630\" { \"version\" \"1\" \"stats\" { \"1\" { \"id\" \"1\" \"x\" \"\" } } }", "[\n\t{\n\t\t\"file\": \"<standard input>\",\n\t\t\"path\": \"630/stats/1/x\",\n\t\t\"message\": \"unexpected key\"\n\t}\n]\n", 1},
+		{"validate-no-schema", []string{"validate"}, sample, "", 2},
+		{"unknown", []string{"unknown"}, "", "", 2},
+		{"none", nil, "", "", 2},
+	} {
+		tt := tt // shadow
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, strings.NewReader(tt.in), &stdout, &stderr)
+			if code != tt.code {
+				t.Errorf("expected exit code %d, got %d (stderr: %q)", tt.code, code, stderr.String())
+			}
+			if stdout.String() != tt.out {
+				t.Errorf("expected output %q, got %q", tt.out, stdout.String())
+			}
+		})
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	in, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "UserGameStatsSchema_630.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text, json, binary bytes.Buffer
+	if code := run([]string{"convert", "-to", "text"}, bytes.NewReader(in), &text, ioutil.Discard); code != 0 {
+		t.Fatal("binary to text failed")
+	}
+	if code := run([]string{"convert", "-to", "json"}, bytes.NewReader(text.Bytes()), &json, ioutil.Discard); code != 0 {
+		t.Fatal("text to JSON failed")
+	}
+	if code := run([]string{"convert", "-to", "binary"}, bytes.NewReader(json.Bytes()), &binary, ioutil.Discard); code != 0 {
+		t.Fatal("JSON to binary failed")
+	}
+
+	var text2 bytes.Buffer
+	if code := run([]string{"convert"}, bytes.NewReader(binary.Bytes()), &text2, ioutil.Discard); code != 0 {
+		t.Fatal("binary to text failed")
+	}
+	if text.String() != text2.String() {
+		t.Error("round trip through JSON changed the data")
+	}
+}
+
+func TestConvertTypes(t *testing.T) {
+	in, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "UserGameStatsSchema_630.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text, binary bytes.Buffer
+	if code := run([]string{"convert", "-types"}, bytes.NewReader(in), &text, ioutil.Discard); code != 0 {
+		t.Fatal("binary to text failed")
+	}
+	if !strings.Contains(text.String(), "//vdf:int") {
+		t.Error("text output has no type annotations")
+	}
+	if code := run([]string{"convert", "-types", "-to", "binary"}, bytes.NewReader(text.Bytes()), &binary, ioutil.Discard); code != 0 {
+		t.Fatal("text to binary failed")
+	}
+	if !bytes.Equal(in, binary.Bytes()) {
+		t.Error("round trip through annotated text changed the binary")
+	}
+}
+
+func TestSetBinary(t *testing.T) {
+	t.Parallel()
+
+	root := new(vdf.Node)
+	root.SetName("root")
+	for _, c := range []struct {
+		name string
+		set  func(*vdf.Node)
+	}{
+		{"n", func(n *vdf.Node) { n.SetInt(7) }},
+		{"f", func(n *vdf.Node) { n.SetFloat(0.5) }},
+		{"c", func(n *vdf.Node) { n.SetColor(color.NRGBA{1, 2, 3, 4}) }},
+		{"s", func(n *vdf.Node) { n.SetString("x") }},
+	} {
+		n := new(vdf.Node)
+		n.SetName(c.name)
+		c.set(n)
+		root.Append(n)
+	}
+	in, err := root.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		path, value string
+		kind        vdf.Kind
+		str         string
+	}{
+		{"root/n", "8", vdf.KindInt, "8"},
+		{"root/f", "1.5", vdf.KindFloat, "1.5"},
+		{"root/c", "5 6 7 8", vdf.KindColor, "5 6 7 8"},
+		{"root/s", "9", vdf.KindString, "9"},
+		{"root/new", "10", vdf.KindString, "10"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"set", tt.path, tt.value}, bytes.NewReader(in), &stdout, &stderr); code != 0 {
+			t.Errorf("%s: set failed: %s", tt.path, stderr.String())
+			continue
+		}
+		out, err := vdf.DecodeBinary(stdout.Bytes(), nil)
+		if err != nil {
+			t.Errorf("%s: couldn't decode output: %v", tt.path, err)
+			continue
+		}
+		n := out.FirstByName(tt.path[len("root/"):])
+		if n.Kind() != tt.kind || n.String() != tt.str {
+			t.Errorf("%s: expected %v %q, got %v %q", tt.path, tt.kind, tt.str, n.Kind(), n.String())
+		}
+	}
+
+	var stderr bytes.Buffer
+	if code := run([]string{"set", "root/n", "x"}, bytes.NewReader(in), ioutil.Discard, &stderr); code != 1 {
+		t.Errorf("expected exit code 1 for a value that is not an int, got %d", code)
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "sample.txt")
+	if err = ioutil.WriteFile(name, []byte(sample), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	if code := run([]string{"set", "-w", "root/sub/y", "3", name}, nil, &stdout, ioutil.Discard); code != 0 {
+		t.Fatal("set failed")
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("unexpected output from set -w: %q", stdout.String())
+	}
+	if code := run([]string{"fmt", "-l", "-w", name}, nil, &stdout, ioutil.Discard); code != 0 {
+		t.Fatal("fmt failed")
+	}
+	if stdout.String() != name+"\n" {
+		t.Errorf("expected fmt -l to list %q, got %q", name, stdout.String())
+	}
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "// comment\n\"root\" {\n\t\"key\" \"value\"\n\t\"sub\" [$WIN32] {\n\t\t\"x\" \"1\"\n\t\t\"y\" \"3\"\n\t}\n\t\"sub\" {\n\t\t\"x\" \"2\"\n\t}\n}\n"
+	if string(b) != expected {
+		t.Errorf("expected %q, got %q", expected, b)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"fmt", "-l", name}, nil, &stdout, ioutil.Discard); code != 0 || stdout.Len() != 0 {
+		t.Errorf("formatted file was listed: %q", stdout.String())
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+
+	"github.com/BenLubar/vdf"
+)
+
+var getCommand = &command{
+	name:  "get",
+	usage: "<path> [file]",
+	short: "print values by path",
+	run:   runGet,
+}
+
+var setCommand = &command{
+	name:  "set",
+	usage: "[-w] <path> <value> [file]",
+	short: "change a value by path, preserving formatting",
+	run:   runSet,
+}
+
+var errNotFound = errors.New("path not found")
+
+func runGet(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return errUsage
+	}
+
+	path, err := splitPath(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	in, err := readInput(fs.Args()[1:], stdin)
+	if err != nil {
+		return err
+	}
+	n, err := decode(in, detectFormat(in), nil)
+	if err != nil {
+		return err
+	}
+
+	matches := lookup(n, path)
+	if len(matches) == 0 {
+		return errNotFound
+	}
+
+	// Values are printed one per line. Subtrees are printed as text.
+	for _, m := range matches {
+		var b []byte
+		if m.Kind() == vdf.KindSubtree {
+			b, err = m.MarshalText()
+			if err != nil {
+				return err
+			}
+		} else {
+			b = []byte(m.String() + "\n")
+		}
+		if _, err = stdout.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runSet(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	write := fs.Bool("w", false, "write the result to the file instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errUsage
+	}
+	if *write && fs.NArg() != 3 {
+		return fmt.Errorf("cannot use -w with standard input")
+	}
+
+	path, err := splitPath(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	in, err := readInput(fs.Args()[2:], stdin)
+	if err != nil {
+		return err
+	}
+	format := detectFormat(in)
+	n, err := decode(in, format, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = set(n, path, fs.Arg(1)); err != nil {
+		return err
+	}
+
+	out, err := encode(n, format, nil)
+	if err != nil {
+		return err
+	}
+
+	if *write {
+		return writeFile(fs.Arg(2), out)
+	}
+	_, err = stdout.Write(out)
+	return err
+}
+
+func splitPath(s string) ([]string, error) {
+	if s == "" {
+		return nil, errors.New("empty path")
+	}
+	return strings.Split(s, "/"), nil
+}
+
+// lookup returns every node matching path, where the first element of path
+// is matched against first and its siblings.
+func lookup(first *vdf.Node, path []string) []*vdf.Node {
+	var matches []*vdf.Node
+	for c := first; c != nil; c = c.NextChild() {
+		if strings.EqualFold(c.Name(), path[0]) {
+			matches = append(matches, c)
+		}
+	}
+
+	for _, name := range path[1:] {
+		var next []*vdf.Node
+		for _, m := range matches {
+			for c := m.FirstByName(name); c != nil; c = c.NextByName(name) {
+				next = append(next, c)
+			}
+		}
+		matches = next
+	}
+
+	return matches
+}
+
+// set changes the value of the first node matching path, creating any
+// missing keys below the root. A value keeps its type, so that binary files
+// keep their types, and a new key or a subtree becomes a string. A subtree
+// that is replaced by a value loses its own custom formatting, as the
+// formatting of its braces no longer applies, but the rest of the document
+// keeps its formatting.
+func set(first *vdf.Node, path []string, value string) error {
+	n := lookup(first, path[:1])
+	if len(n) == 0 {
+		return errNotFound
+	}
+
+	current := n[0]
+	for _, name := range path[1:] {
+		c := current.FirstByName(name)
+		if c == nil {
+			c = new(vdf.Node)
+			c.SetName(name)
+			c.SetString("")
+			current.Append(c)
+		}
+		current = c
+	}
+
+	return setValue(current, value)
+}
+
+// setValue parses s as the type of the value n already has and stores it.
+func setValue(n *vdf.Node, s string) error {
+	// The parsing is done by the checked accessors of a string node with
+	// the same name, so that errors name the key.
+	v := new(vdf.Node)
+	v.SetName(n.Name())
+	v.SetString(s)
+
+	var err error
+	switch n.Kind() {
+	case vdf.KindInt:
+		var i int32
+		if i, err = v.IntE(); err == nil {
+			n.SetInt(i)
+		}
+	case vdf.KindFloat:
+		var f float32
+		if f, err = v.FloatE(); err == nil {
+			n.SetFloat(f)
+		}
+	case vdf.KindPtr:
+		var p uint32
+		if p, err = v.PtrE(); err == nil {
+			n.SetPtr(p)
+		}
+	case vdf.KindWString:
+		n.SetWString(v.WString())
+	case vdf.KindColor:
+		var c color.NRGBA
+		if c, err = v.ColorE(); err == nil {
+			n.SetColor(c)
+		}
+	case vdf.KindUint64:
+		var u uint64
+		if u, err = v.Uint64E(); err == nil {
+			n.SetUint64(u)
+		}
+	case vdf.KindInt64:
+		var i int64
+		if i, err = v.Int64E(); err == nil {
+			n.SetInt64(i)
+		}
+	default:
+		n.SetString(s)
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/BenLubar/vdf"
+	"github.com/BenLubar/vdf/schema"
+)
+
+var validateCommand = &command{
+	name:  "validate",
+	usage: "-schema file [-json] [file ...]",
+	short: "check KeyValues against a schema",
+	run:   runValidate,
+}
+
+// validateResult is the JSON form of a schema error.
+type validateResult struct {
+	File    string `json:"file"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func runValidate(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	schemaName := fs.String("schema", "", "text KeyValues `file` containing the schema")
+	asJSON := fs.Bool("json", false, "write errors as a JSON array")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *schemaName == "" {
+		return errUsage
+	}
+
+	b, err := ioutil.ReadFile(*schemaName)
+	if err != nil {
+		return err
+	}
+	var sn vdf.Node
+	if err = sn.UnmarshalText(b); err != nil {
+		return fmt.Errorf("%s: %v", *schemaName, err)
+	}
+	s, err := schema.Parse(&sn)
+	if err != nil {
+		return fmt.Errorf("%s: %v", *schemaName, err)
+	}
+
+	results := []validateResult{}
+	count := 0
+	check := func(name string, in []byte) error {
+		n, err := decode(in, detectFormat(in), nil)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		for _, e := range s.Validate(n) {
+			count++
+			if *asJSON {
+				results = append(results, validateResult{File: name, Path: e.Path, Message: e.Msg})
+				continue
+			}
+			if _, err = fmt.Fprintf(stdout, "%s: %v\n", name, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if fs.NArg() == 0 {
+		in, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		if err = check("<standard input>", in); err != nil {
+			return err
+		}
+	}
+	for _, name := range fs.Args() {
+		in, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err = check(name, in); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	}
+
+	if count != 0 {
+		return errFailed
+	}
+	return nil
+}
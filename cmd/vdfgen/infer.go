@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/BenLubar/vdf"
+)
+
+// shape is what has been observed about every key at one path in the
+// samples. Keys are merged without regard to case, as FirstByName does.
+type shape struct {
+	name string
+
+	values   int
+	subtrees int
+	repeated bool
+
+	// What the non-empty values look like.
+	nonEmpty int
+	notInt   bool
+	notInt32 bool
+	notFloat bool
+
+	children []*shape
+	index    map[string]*shape
+}
+
+func newShape(name string) *shape {
+	return &shape{name: name, index: make(map[string]*shape)}
+}
+
+func (s *shape) child(name string) *shape {
+	key := strings.ToLower(name)
+	if c, ok := s.index[key]; ok {
+		return c
+	}
+	c := newShape(name)
+	s.children = append(s.children, c)
+	s.index[key] = c
+	return c
+}
+
+// observeSubtree records the children of n.
+func (s *shape) observeSubtree(n *vdf.Node) {
+	s.subtrees++
+
+	counts := make(map[*shape]int)
+	sub := n.FirstSubTree()
+	for c := n.FirstChild(); c != nil; c = c.NextChild() {
+		cs := s.child(c.Name())
+		counts[cs]++
+		if c == sub {
+			sub = c.NextSubTree()
+			cs.observeSubtree(c)
+		} else {
+			cs.observeValue(c.String())
+		}
+	}
+
+	for cs, count := range counts {
+		if count > 1 {
+			cs.repeated = true
+		}
+	}
+}
+
+func (s *shape) observeValue(v string) {
+	s.values++
+	if v == "" {
+		return
+	}
+
+	s.nonEmpty++
+	if i, err := strconv.ParseInt(v, 10, 64); err != nil {
+		s.notInt = true
+	} else if int64(int32(i)) != i {
+		s.notInt32 = true
+	}
+	if _, err := strconv.ParseFloat(v, 64); err != nil {
+		s.notFloat = true
+	}
+}
+
+// merge adds everything observed in o to s.
+func (s *shape) merge(o *shape) {
+	s.values += o.values
+	s.subtrees += o.subtrees
+	s.repeated = s.repeated || o.repeated
+	s.nonEmpty += o.nonEmpty
+	s.notInt = s.notInt || o.notInt
+	s.notInt32 = s.notInt32 || o.notInt32
+	s.notFloat = s.notFloat || o.notFloat
+	for _, c := range o.children {
+		s.child(c.name).merge(c)
+	}
+}
+
+// isSubtree reports whether keys with this shape should be treated as
+// subtrees. Empty values are allowed in place of empty subtrees.
+func (s *shape) isSubtree() bool {
+	return s.subtrees != 0 && s.nonEmpty == 0
+}
+
+// isMixed reports whether keys with this shape are sometimes subtrees and
+// sometimes non-empty values.
+func (s *shape) isMixed() bool {
+	return s.subtrees != 0 && s.nonEmpty != 0
+}
+
+// isMap reports whether the children of this subtree are keyed by numeric
+// IDs, in which case they are treated as a map rather than as the fields of
+// a struct.
+func (s *shape) isMap() bool {
+	if len(s.children) == 0 {
+		return false
+	}
+	for _, c := range s.children {
+		if _, err := strconv.ParseInt(c.name, 10, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// element returns the shape of the elements of a map.
+func (s *shape) element() *shape {
+	e := newShape("")
+	for _, c := range s.children {
+		e.merge(c)
+	}
+	return e
+}
+
+// valueType returns the Go type for values with this shape.
+func (s *shape) valueType() string {
+	switch {
+	case s.nonEmpty == 0 || s.notFloat:
+		return "string"
+	case s.notInt:
+		return "float64"
+	case s.notInt32:
+		return "int64"
+	default:
+		return "int"
+	}
+}
@@ -0,0 +1,245 @@
+// Command vdfgen generates Go types for a VDF format from sample files.
+//
+// Usage:
+//
+//	vdfgen [-type name] [-package name] [-o file] [sample ...]
+//
+// Each sample is a text or binary VDF file, or standard input if there are
+// none. The keys observed in all of the samples are merged, ignoring case,
+// into a hierarchy of struct types with vdf struct tags, suitable for use
+// with vdf.Unmarshal:
+//
+//   - Values that are always integers become int (or int64 if they do not
+//     fit in 32 bits), other numeric values become float64, and everything
+//     else becomes string.
+//   - Subtrees whose keys are all numeric IDs become maps keyed by int.
+//   - Keys that appear more than once in the same subtree become slices.
+//   - Keys that are sometimes subtrees and sometimes values become *vdf.Node.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/BenLubar/vdf"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("vdfgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: vdfgen [-type name] [-package name] [-o file] [sample ...]")
+		fs.PrintDefaults()
+	}
+	typeName := fs.String("type", "", "`name` of the root type (default based on the root key)")
+	pkg := fs.String("package", "main", "package `name` for the generated code")
+	output := fs.String("o", "", "write the generated code to `file` instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	root := newShape("")
+	observe := func(name string, b []byte) error {
+		n := new(vdf.Node)
+		var err error
+		if len(b) != 0 && b[0] < '\t' {
+			err = n.UnmarshalBinary(b)
+		} else {
+			err = n.UnmarshalText(b)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if root.name == "" {
+			root.name = n.Name()
+		}
+		root.observeSubtree(n)
+		return nil
+	}
+
+	var err error
+	if fs.NArg() == 0 {
+		var b []byte
+		if b, err = ioutil.ReadAll(stdin); err == nil {
+			err = observe("<standard input>", b)
+		}
+	}
+	for _, name := range fs.Args() {
+		if err != nil {
+			break
+		}
+		var b []byte
+		if b, err = ioutil.ReadFile(name); err == nil {
+			err = observe(name, b)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "vdfgen:", err)
+		return 1
+	}
+
+	if *typeName == "" {
+		*typeName = "Root"
+		if r := []rune(root.name); len(r) != 0 && unicode.IsLetter(r[0]) {
+			*typeName = goName(root.name)
+		}
+	}
+
+	src, err := generate(root, *typeName, *pkg, fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "vdfgen:", err)
+		return 1
+	}
+
+	if *output != "" {
+		err = ioutil.WriteFile(*output, src, 0666)
+	} else {
+		_, err = stdout.Write(src)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "vdfgen:", err)
+		return 1
+	}
+	return 0
+}
+
+// generator writes the struct types for a shape and the shapes nested in it.
+type generator struct {
+	buf     bytes.Buffer
+	names   map[string]bool
+	pending []pendingType
+	useVDF  bool
+}
+
+type pendingType struct {
+	name string
+	s    *shape
+}
+
+func generate(root *shape, typeName, pkg string, samples []string) ([]byte, error) {
+	g := &generator{names: make(map[string]bool)}
+	name := g.uniqueName(typeName)
+
+	var body bytes.Buffer
+	if root.isMap() {
+		fmt.Fprintf(&body, "type %s %s\n\n", name, g.typeOf(root, typeName))
+	} else {
+		g.pending = append(g.pending, pendingType{name, root})
+	}
+	for len(g.pending) != 0 {
+		t := g.pending[0]
+		g.pending = g.pending[1:]
+		g.writeStruct(&body, t.name, t.s)
+	}
+
+	from := "standard input"
+	if len(samples) != 0 {
+		from = strings.Join(samples, ", ")
+	}
+	fmt.Fprintf(&g.buf, "// Code generated by vdfgen from %s. DO NOT EDIT.\n\n", from)
+	fmt.Fprintf(&g.buf, "package %s\n\n", pkg)
+	if g.useVDF {
+		fmt.Fprintf(&g.buf, "import %q\n\n", "github.com/BenLubar/vdf")
+	}
+	g.buf.Write(body.Bytes())
+
+	return format.Source(g.buf.Bytes())
+}
+
+func (g *generator) uniqueName(name string) string {
+	unique := name
+	for i := 2; g.names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	g.names[unique] = true
+	return unique
+}
+
+func (g *generator) writeStruct(w *bytes.Buffer, name string, s *shape) {
+	fmt.Fprintf(w, "type %s struct {\n", name)
+
+	fields := make(map[string]bool)
+	for _, c := range s.children {
+		field := goName(c.name)
+		unique := field
+		for i := 2; fields[unique]; i++ {
+			unique = field + strconv.Itoa(i)
+		}
+		fields[unique] = true
+
+		fmt.Fprintf(w, "\t%s %s `vdf:%q`\n", unique, g.typeOf(c, name+field), c.name)
+	}
+
+	fmt.Fprintf(w, "}\n\n")
+}
+
+// typeOf returns the Go type for keys with the shape s. If a new struct type
+// is needed, it is given a name based on typeName.
+func (g *generator) typeOf(s *shape, typeName string) string {
+	var t string
+	switch {
+	case s.isMixed():
+		g.useVDF = true
+		t = "*vdf.Node"
+	case !s.isSubtree():
+		t = s.valueType()
+	case s.isMap():
+		t = "map[int]" + g.typeOf(s.element(), singular(typeName))
+	default:
+		t = g.uniqueName(typeName)
+		g.pending = append(g.pending, pendingType{t, s})
+	}
+
+	if s.repeated {
+		t = "[]" + t
+	}
+	return t
+}
+
+// goName converts a key to an exported Go identifier by capitalizing each
+// run of letters and digits.
+func goName(key string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && !unicode.IsLetter(r) {
+			b.WriteString("Key")
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "Key"
+	}
+	return b.String()
+}
+
+// singular makes a guess at the name of an element of a map.
+func singular(name string) string {
+	if strings.HasSuffix(name, "ies") {
+		return strings.TrimSuffix(name, "ies") + "y"
+	}
+	if strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") {
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "Elem"
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "out_items.golden"))
+	if err != nil {
+		t.Fatal("couldn't read expected output: ", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-package", "items", filepath.Join("testdata", "in_items1.txt"), filepath.Join("testdata", "in_items2.txt")}
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if !bytes.Equal(expected, stdout.Bytes()) {
+		t.Error("generated code differs!")
+		t.Logf("expected: %s", expected)
+		t.Logf("actual:   %s", stdout.Bytes())
+	}
+}
+
+func TestGenerateStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := `"440" { "1" { "x" "1" } "2" { "x" "y" } }`
+	if code := run([]string{"-type", "Schema"}, strings.NewReader(in), &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+
+	out := stdout.String()
+	for _, s := range []string{
+		"from standard input",
+		"package main\n",
+		"type Schema map[int]SchemaElem\n",
+		"type SchemaElem struct {\n\tX string `vdf:\"x\"`\n}\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in output:\n%s", s, out)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for in, expected := range map[string]string{
+		"name":          "Name",
+		"type_int":      "TypeInt",
+		"damage bonus":  "DamageBonus",
+		"GameName":      "GameName",
+		"1":             "Key1",
+		"$WIN32":        "WIN32",
+		"":              "Key",
+		"icon-gray.jpg": "IconGrayJpg",
+	} {
+		if actual := goName(in); actual != expected {
+			t.Errorf("goName(%q): expected %q, got %q", in, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package vdf
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownColor is wrapped by the *ValueError returned by ColorResolver
+// for a name that is not defined in the scheme.
+var ErrUnknownColor = errors.New("vdf: unknown color name")
+
+// colorStyle is the way a color was written in text.
+type colorStyle uint8
+
+const (
+	colorRGBA     colorStyle = iota // "255 128 0 255"
+	colorRGB                        // "255 128 0"
+	colorHex                        // "#ff8000"
+	colorHexUpper                   // "#FF8000"
+)
+
+// maxColorDepth limits how many names ColorResolver follows to find a color,
+// in case the names refer to each other.
+const maxColorDepth = 16
+
+// parseColor parses a color in any of the forms accepted by Color, and
+// returns the form it was written in. The error is strconv.ErrSyntax or
+// strconv.ErrRange.
+func parseColor(s string) (color.NRGBA, colorStyle, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "#") {
+		hex := s[1:]
+		if len(hex) != 6 && len(hex) != 8 {
+			return color.NRGBA{}, 0, strconv.ErrSyntax
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return color.NRGBA{}, 0, strconv.ErrSyntax
+		}
+		if len(hex) == 6 {
+			v = v<<8 | 0xff
+		}
+		style := colorHex
+		if hex != strings.ToLower(hex) {
+			style = colorHexUpper
+		}
+		return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, style, nil
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) != 3 && len(fields) != 4 {
+		return color.NRGBA{}, 0, strconv.ErrSyntax
+	}
+	c := [4]uint8{3: 255}
+	for i, f := range fields {
+		v, err := strconv.ParseUint(f, 10, 8)
+		if err != nil {
+			return color.NRGBA{}, 0, err.(*strconv.NumError).Err
+		}
+		c[i] = uint8(v)
+	}
+	style := colorRGBA
+	if len(fields) == 3 {
+		style = colorRGB
+	}
+	return color.NRGBA{c[0], c[1], c[2], c[3]}, style, nil
+}
+
+// formatColor writes c in the given style. Styles that leave out the alpha
+// component are only used if c is opaque.
+func formatColor(c color.NRGBA, style colorStyle) string {
+	opaque := c.A == 255
+	switch {
+	case style == colorRGB && opaque:
+		return fmt.Sprintf("%d %d %d", c.R, c.G, c.B)
+	case style == colorHex && opaque:
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	case style == colorHex:
+		return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+	case style == colorHexUpper && opaque:
+		return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+	case style == colorHexUpper:
+		return fmt.Sprintf("#%02X%02X%02X%02X", c.R, c.G, c.B, c.A)
+	}
+	return fmt.Sprintf("%d %d %d %d", c.R, c.G, c.B, c.A)
+}
+
+// ColorResolver converts the values of Nodes to colors like ColorE, and
+// also accepts the names of colors defined in a VGUI scheme, as used by the
+// .res files that refer to the scheme.
+//
+// The zero value of ColorResolver has no scheme, so it accepts the same
+// values as ColorE.
+type ColorResolver struct {
+	// Scheme is the "Scheme" key of a scheme file, such as
+	// resource/ClientScheme.res. Names are looked up in its Colors
+	// subtree, and then in its BaseSettings subtree, whose values may be
+	// the names of other colors. Names are compared ignoring case.
+	Scheme *Node
+}
+
+// Color returns the value of n as a color. If the value is not in one of
+// the forms accepted by ColorE, it is looked up as a name in the scheme. If
+// it is not found, the *ValueError wraps ErrUnknownColor.
+//
+// Color is safe to call from multiple goroutines at the same time, as long
+// as the scheme is not modified.
+func (r *ColorResolver) Color(n *Node) (color.NRGBA, error) {
+	s, isString, ok := n.checkedString()
+	if !ok || !isString || r == nil || r.Scheme == nil {
+		return n.ColorE()
+	}
+	if c, _, err := parseColor(s); err == nil {
+		return c, nil
+	}
+
+	if c, ok := r.lookup(s, 0); ok {
+		return c, nil
+	}
+	return color.NRGBA{}, n.valueError("color.NRGBA", ErrUnknownColor)
+}
+
+// lookup returns the color with the given name in the scheme.
+func (r *ColorResolver) lookup(name string, depth int) (color.NRGBA, bool) {
+	if depth >= maxColorDepth {
+		return color.NRGBA{}, false
+	}
+
+	for _, section := range [...]string{"Colors", "BaseSettings"} {
+		v := r.Scheme.FirstByName(section).FirstByName(name)
+		if v == nil || v.FirstChild() != nil {
+			continue
+		}
+		if c, err := v.ColorE(); err == nil {
+			return c, true
+		}
+		if c, ok := r.lookup(v.String(), depth+1); ok {
+			return c, true
+		}
+	}
+	return color.NRGBA{}, false
+}
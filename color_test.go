@@ -0,0 +1,138 @@
+package vdf_test
+
+import (
+	"errors"
+	"image/color"
+	"strconv"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+func TestColorForms(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want color.NRGBA
+		err  error
+	}{
+		{"1 2 3 4", color.NRGBA{1, 2, 3, 4}, nil},
+		{" 255  128 0 ", color.NRGBA{255, 128, 0, 255}, nil},
+		{"#ff8000", color.NRGBA{255, 128, 0, 255}, nil},
+		{"#FF800080", color.NRGBA{255, 128, 0, 128}, nil},
+		{"#ff80", color.NRGBA{}, strconv.ErrSyntax},
+		{"#gg8000", color.NRGBA{}, strconv.ErrSyntax},
+		{"1 2 3 4 5", color.NRGBA{}, strconv.ErrSyntax},
+		{"-1 2 3", color.NRGBA{}, strconv.ErrSyntax},
+		{"White", color.NRGBA{}, strconv.ErrSyntax},
+	}
+
+	for _, tt := range tests {
+		tt := tt // shadow
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+
+			n := checkedNode(tt.in)
+			if c := n.Color(); c != tt.want {
+				t.Errorf("Color: expected %v, got %v", tt.want, c)
+			}
+			c, err := n.ColorE()
+			if c != tt.want || !errors.Is(err, tt.err) || (tt.err == nil) != (err == nil) {
+				t.Errorf("ColorE: expected %v %v, got %v %v", tt.want, tt.err, c, err)
+			}
+		})
+	}
+}
+
+func TestColorResolver(t *testing.T) {
+	t.Parallel()
+
+	var scheme vdf.Node
+	if err := scheme.UnmarshalText([]byte(`"Scheme"
+{
+	"Colors"
+	{
+		"White"		"255 255 255 255"
+		"Orange"	"255 128 0"
+		"Loop"		"Loop"
+	}
+	"BaseSettings"
+	{
+		"Button.TextColor"	"white"
+		"Border.Bright"		"Button.TextColor"
+		"Label.Bg"		"#00000080"
+		"Missing"		"NotAColor"
+	}
+}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var res vdf.Node
+	if err := res.UnmarshalText([]byte(`"panel" { "fgcolor" "orange" "bgcolor" "Border.Bright" "tint" "Label.Bg" "raw" "1 2 3" "loop" "Loop" "bad" "Missing" "sub" { } }`)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &vdf.ColorResolver{Scheme: &scheme}
+	tests := []struct {
+		key  string
+		want color.NRGBA
+		err  error
+	}{
+		{"fgcolor", color.NRGBA{255, 128, 0, 255}, nil},
+		{"bgcolor", color.NRGBA{255, 255, 255, 255}, nil},
+		{"tint", color.NRGBA{0, 0, 0, 128}, nil},
+		{"raw", color.NRGBA{1, 2, 3, 255}, nil},
+		{"loop", color.NRGBA{}, vdf.ErrUnknownColor},
+		{"bad", color.NRGBA{}, vdf.ErrUnknownColor},
+		{"sub", color.NRGBA{}, vdf.ErrNoValue},
+	}
+	for _, tt := range tests {
+		c, err := r.Color(res.FirstByName(tt.key))
+		if c != tt.want || !errors.Is(err, tt.err) || (tt.err == nil) != (err == nil) {
+			t.Errorf("%s: expected %v %v, got %v %v", tt.key, tt.want, tt.err, c, err)
+		}
+	}
+
+	// Without a scheme, names are not resolved.
+	var noScheme *vdf.ColorResolver
+	if _, err := noScheme.Color(res.FirstByName("fgcolor")); !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("expected invalid syntax, got %v", err)
+	}
+}
+
+func TestSetColorKeepsForm(t *testing.T) {
+	t.Parallel()
+
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte("\"root\"\n{\n\t\"rgb\"  \"255 128 0\"\n\t\"hex\"\t#FF8000 // comment\n\t\"rgba\" \"1 2 3 4\"\n\t\"text\" \"x\"\n}\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"rgb", "hex", "rgba", "text"} {
+		n.FirstByName(name).SetColor(color.NRGBA{16, 32, 48, 255})
+	}
+	out, err := n.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "\"root\"\n{\n\t\"rgb\"  \"16 32 48\"\n\t\"hex\"\t#102030 // comment\n\t\"rgba\" \"16 32 48 255\"\n\t\"text\" \"16 32 48 255\"\n}\n"
+	if string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	// Colors that are not opaque need all four components.
+	n.FirstByName("rgb").SetColor(color.NRGBA{1, 2, 3, 4})
+	n.FirstByName("hex").SetColor(color.NRGBA{1, 2, 3, 4})
+	if out, err = n.MarshalText(); err != nil {
+		t.Fatal(err)
+	}
+	expected = "\"root\"\n{\n\t\"rgb\"  \"1 2 3 4\"\n\t\"hex\"\t#01020304 // comment\n\t\"rgba\" \"16 32 48 255\"\n\t\"text\" \"16 32 48 255\"\n}\n"
+	if string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	if c := n.FirstByName("hex").Color(); c != (color.NRGBA{1, 2, 3, 4}) {
+		t.Errorf("unexpected color %v", c)
+	}
+}
@@ -0,0 +1,195 @@
+package vdf
+
+import (
+	"strings"
+)
+
+// comment holds the // comments attached to a Node.
+type comment struct {
+	leading  string
+	trailing string
+}
+
+func newComment(leading, trailing string) *comment {
+	if leading == "" && trailing == "" {
+		return nil
+	}
+	return &comment{leading: leading, trailing: trailing}
+}
+
+// Comments returns the text of the // comments on the lines before this Node
+// and on the same line as the end of this Node, without the slashes or the
+// space after them. Lines of a leading comment are separated by "\n". For a
+// subtree, the trailing comment is the one after the closing brace.
+//
+// Comments that are not next to a key, such as those before a closing brace,
+// are only kept by the formatting recorded by UnmarshalText.
+//
+// Comments is an accessor.
+func (n *Node) Comments() (leading, trailing string) {
+	if c := n.notNil().comment; c != nil {
+		return c.leading, c.trailing
+	}
+	return "", ""
+}
+
+// SetLeadingComment sets the comment written on the lines before this Node.
+// Each line of the comment, separated by "\n", is written as a separate //
+// comment. An empty string removes the comment.
+//
+// SetLeadingComment is a mutator.
+func (n *Node) SetLeadingComment(text string) {
+	_, trailing := n.Comments()
+	n.comment = newComment(text, trailing)
+	if n.cf != nil {
+		n.cf.before = replaceLeadingComment(n.cf.before, text)
+	}
+}
+
+// SetTrailingComment sets the comment written at the end of the last line of
+// this Node. Putting a newline in a trailing comment will panic. An empty
+// string removes the comment.
+//
+// SetTrailingComment is a mutator.
+func (n *Node) SetTrailingComment(text string) {
+	if strings.ContainsAny(text, "\r\n") {
+		panic("vdf: trailing comment cannot contain a newline")
+	}
+	leading, _ := n.Comments()
+	n.comment = newComment(leading, text)
+	if n.cf == nil {
+		return
+	}
+	if n.value != nil {
+		n.cf.after = replaceTrailingComment(n.cf.after, text)
+	} else if i := closeBrace(n.cf.after); i != -1 {
+		n.cf.after = n.cf.after[:i+1] + replaceTrailingComment(n.cf.after[i+1:], text)
+	}
+}
+
+// leadingComment extracts the comment text from the whitespace and //
+// comments before a key.
+func leadingComment(prefix string) string {
+	if !strings.Contains(prefix, "//") {
+		return ""
+	}
+	var lines []string
+	for _, line := range strings.Split(prefix, "\n") {
+		line = strings.TrimLeft(line, " \t\v\f\r")
+		if strings.HasPrefix(line, "//") {
+			lines = append(lines, commentText(line))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// trailingComment extracts the comment text from the end of a line, as
+// returned by readLineEnding.
+func trailingComment(suffix string) string {
+	_, text, _ := splitLineEnding(suffix)
+	if !strings.HasPrefix(text, "//") {
+		return ""
+	}
+	return commentText(text)
+}
+
+// commentText removes the slashes, the space after them, and the line ending
+// from a // comment.
+func commentText(line string) string {
+	line = strings.TrimPrefix(line, "//")
+	line = strings.TrimPrefix(line, " ")
+	return strings.TrimRight(line, "\r\n")
+}
+
+// splitLineEnding splits the end of a line into the space before a comment,
+// the comment itself, and the line ending. Comments other than // comments,
+// which are allowed by DecodeOptions, are returned as well, but are not
+// reported by Comments.
+func splitLineEnding(s string) (space, comment, rest string) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return r != ' ' && r != '\t' && r != '\v' && r != '\f'
+	})
+	if i == -1 {
+		return s, "", ""
+	}
+	space, s = s[:i], s[i:]
+	if i = strings.IndexAny(s, "\r\n"); i == -1 {
+		return space, s, ""
+	}
+	return space, s[:i], s[i:]
+}
+
+// closeBrace returns the index of the } in the formatting after a subtree,
+// skipping over any comments before it.
+func closeBrace(after string) int {
+	for i := 0; i < len(after); i++ {
+		var start int
+		var end string
+		switch {
+		case after[i] == '}':
+			return i
+		case strings.HasPrefix(after[i:], "/*"):
+			start, end = i+2, "*/"
+		case after[i] == '/' || after[i] == '#':
+			start, end = i+1, "\n"
+		default:
+			continue
+		}
+		j := strings.Index(after[start:], end)
+		if j == -1 {
+			return -1
+		}
+		i = start + j + len(end) - 1
+	}
+	return -1
+}
+
+// writeComment formats text as one or more // comments, each preceded by
+// indent and followed by a newline.
+func writeComment(b *strings.Builder, indent, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(indent)
+		b.WriteString("//")
+		if line != "" {
+			b.WriteString(" ")
+			b.WriteString(line)
+		}
+		b.WriteString("\n")
+	}
+}
+
+// replaceLeadingComment replaces the // comments in prefix with text,
+// keeping any blank lines and the indentation of the key.
+func replaceLeadingComment(prefix, text string) string {
+	indent := prefix[strings.LastIndexByte(prefix, '\n')+1:]
+
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(prefix[:len(prefix)-len(indent)], "\n") {
+		if !strings.HasPrefix(strings.TrimLeft(line, " \t\v\f\r"), "//") {
+			b.WriteString(line)
+		}
+	}
+	if text != "" {
+		writeComment(&b, indent, text)
+	}
+	b.WriteString(indent)
+	return b.String()
+}
+
+// replaceTrailingComment replaces the // comment in a line ending with text.
+func replaceTrailingComment(suffix, text string) string {
+	space, old, rest := splitLineEnding(suffix)
+	if text == "" {
+		if old == "" {
+			return suffix
+		}
+		return rest
+	}
+	if old == "" && space == "" {
+		space = " "
+	}
+	if rest == "" {
+		rest = "\n"
+	}
+	return space + "// " + text + rest
+}
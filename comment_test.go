@@ -0,0 +1,285 @@
+package vdf_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+const commentedText = `// header
+// second line
+
+"root" // after root
+{
+	// about a
+	"a" "1" // after a
+	"b" "2" [$WIN32] // after b
+
+	//no space
+	"sub"
+	{
+		"c" "3"
+		// before close
+	} // after sub
+}
+`
+
+func TestComments(t *testing.T) {
+	t.Parallel()
+
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte(commentedText)); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := n.FirstByName("sub")
+	for _, tt := range []struct {
+		name     string
+		n        *vdf.Node
+		leading  string
+		trailing string
+	}{
+		{"root", &n, "header\nsecond line", ""},
+		{"a", n.FirstByName("a"), "about a", "after a"},
+		{"b", n.FirstByName("b"), "", "after b"},
+		{"sub", sub, "no space", "after sub"},
+		{"c", sub.FirstByName("c"), "", ""},
+	} {
+		leading, trailing := tt.n.Comments()
+		if leading != tt.leading || trailing != tt.trailing {
+			t.Errorf("%s: expected comments %q, %q, got %q, %q", tt.name, tt.leading, tt.trailing, leading, trailing)
+		}
+	}
+
+	n.ClearFormatting()
+	out, err := n.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `// header
+// second line
+"root" {
+	// about a
+	"a" "1" // after a
+	"b" "2" [$WIN32] // after b
+	// no space
+	"sub" {
+		"c" "3"
+	} // after sub
+}
+`
+	if string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestSetComments(t *testing.T) {
+	t.Parallel()
+
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte(commentedText)); err != nil {
+		t.Fatal(err)
+	}
+
+	n.SetLeadingComment("")
+	n.FirstByName("a").SetLeadingComment("new\nlines")
+	n.FirstByName("a").SetTrailingComment("")
+	n.FirstByName("b").SetTrailingComment("changed")
+	sub := n.FirstByName("sub")
+	sub.SetTrailingComment("")
+	sub.FirstByName("c").SetLeadingComment("added")
+	sub.FirstByName("c").SetTrailingComment("too")
+
+	out, err := n.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+"root" // after root
+{
+	// new
+	// lines
+	"a" "1"
+	"b" "2" [$WIN32] // changed
+
+	//no space
+	"sub"
+	{
+		// added
+		"c" "3" // too
+		// before close
+	}
+}
+`
+	if string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	var c vdf.Node
+	c.SetName("new")
+	c.SetString("value")
+	c.SetLeadingComment("leading")
+	c.SetTrailingComment("trailing")
+	if leading, trailing := c.Comments(); leading != "leading" || trailing != "trailing" {
+		t.Errorf("unexpected comments %q, %q", leading, trailing)
+	}
+	if out, err = c.MarshalText(); err != nil {
+		t.Fatal(err)
+	} else if expected = "// leading\n\"new\" \"value\" // trailing\n"; string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestDiscardComments(t *testing.T) {
+	t.Parallel()
+
+	n, err := vdf.DecodeText([]byte(commentedText), &vdf.DecodeOptions{DiscardComments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*vdf.Node{n, n.FirstByName("a"), n.FirstByName("sub")} {
+		if leading, trailing := c.Comments(); leading != "" || trailing != "" {
+			t.Errorf("%s: unexpected comments %q, %q", c.Name(), leading, trailing)
+		}
+	}
+}
+
+func TestBlockAndHashComments(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		opts vdf.DecodeOptions
+		out  string // after DiscardComments and ClearFormatting
+	}{
+		{
+			name: "block",
+			in:   "/* header\n   more */\n\"root\" /* between */ {\n\t\"a\" /* before value */ \"1\" /* before condition */ [$X]\n\tb/* glued */c\n}\n",
+			opts: vdf.DecodeOptions{BlockComments: true},
+			out:  "\"root\" {\n\t\"a\" \"1\" [$X]\n\t\"b\" \"c\"\n}\n",
+		},
+		{
+			name: "block-condition",
+			in:   "\"root\" /* a */ [!$X] /* b */ {\n\t\"a\" \"1\"\n}\n",
+			opts: vdf.DecodeOptions{BlockComments: true},
+			out:  "\"root\" [!$X] {\n\t\"a\" \"1\"\n}\n",
+		},
+		{
+			name: "block-close",
+			in:   "\"root\" {\n\t\"a\" \"1\"\n\t/* } */\n} /* end */\n",
+			opts: vdf.DecodeOptions{BlockComments: true},
+			out:  "\"root\" {\n\t\"a\" \"1\"\n}\n",
+		},
+		{
+			name: "hash",
+			in:   "# generated\n#base \"other.res\"\n\"root\" {\n\t\"a\" \"1\" # after a\n\t## more\n\t\"b\" \"#2\"\n}\n",
+			opts: vdf.DecodeOptions{HashComments: true},
+			out:  "\"#base\" \"other.res\"\n\"root\" {\n\t\"a\" \"1\"\n\t\"b\" \"#2\"\n}\n",
+		},
+		{
+			name: "end-of-input",
+			in:   "\"a\" \"1\" # end",
+			opts: vdf.DecodeOptions{HashComments: true},
+			out:  "\"a\" \"1\"\n",
+		},
+		{
+			name: "both",
+			in:   "\"root\" {\n\t# line\n\t/* block */ \"a\" \"1\" // slashes\n}\n",
+			opts: vdf.DecodeOptions{BlockComments: true, HashComments: true},
+			out:  "\"root\" {\n\t\"a\" \"1\"\n}\n",
+		},
+	} {
+		tt := tt // shadow
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			n, err := vdf.DecodeText([]byte(tt.in), &tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := n.MarshalText()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.in {
+				t.Errorf("round trip: expected %q, got %q", tt.in, out)
+			}
+
+			opts := tt.opts
+			opts.DiscardComments = true
+			if n, err = vdf.DecodeText([]byte(tt.in), &opts); err != nil {
+				t.Fatal(err)
+			}
+			n.ClearFormatting()
+			if out, err = n.MarshalText(); err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.out {
+				t.Errorf("discarded: expected %q, got %q", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestCommentsAcrossBufferBoundary(t *testing.T) {
+	t.Parallel()
+
+	// Enough short lines that line comment markers land on every offset
+	// of the decoder's read buffer, including its last byte.
+	var buf bytes.Buffer
+	buf.WriteString("\"root\" {\n")
+	for i := 0; buf.Len() < 3*4096; i++ {
+		fmt.Fprintf(&buf, "\t\"k%d\" \"%d\" // c%d\n\t# h%d\n", i, i, i, i)
+	}
+	buf.WriteString("}\n")
+
+	n, err := vdf.DecodeText(buf.Bytes(), &vdf.DecodeOptions{HashComments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := n.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, buf.Bytes()) {
+		t.Errorf("round trip changed %d byte document", buf.Len())
+	}
+}
+
+func TestBlockCommentErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := vdf.DecodeText([]byte("\"a\" /* \"b\""), &vdf.DecodeOptions{BlockComments: true}); err == nil || err.Error() != "vdf: unterminated block comment" {
+		t.Errorf("unexpected error for unterminated comment: %v", err)
+	}
+
+	// Without BlockComments, the comment is read as keys and values.
+	n, err := vdf.DecodeText([]byte("\"a\" \"1\" /* x */ \"y\""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := n.NextChild(); c.Name() != "/*" || c.String() != "x" {
+		t.Errorf("expected comment to be read as keys, got %q %q", c.Name(), c.String())
+	}
+}
+
+func TestSetCommentsWithBlockComments(t *testing.T) {
+	t.Parallel()
+
+	in := "\"root\" {\n\t\"a\" \"1\" /* block */\n\t\"sub\" {\n\t\t/* } */\n\t} # hash\n}\n"
+	n, err := vdf.DecodeText([]byte(in), &vdf.DecodeOptions{BlockComments: true, HashComments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n.FirstByName("sub").SetTrailingComment("replaced")
+	out, err := n.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "\"root\" {\n\t\"a\" \"1\" /* block */\n\t\"sub\" {\n\t\t/* } */\n\t} // replaced\n}\n"; string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
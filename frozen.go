@@ -0,0 +1,392 @@
+package vdf
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// Frozen is an immutable copy of a Node and its children. All methods on a
+// Frozen are accessors, so a Frozen can be shared between goroutines
+// without locking. The update methods, With and Without, return a new
+// Frozen that shares the parts of the tree that did not change.
+//
+// A Frozen keeps the names, conditions, values, and comments of the Nodes
+// it was made from, but not the formatting recorded by UnmarshalText.
+type Frozen struct {
+	name      string
+	condition string
+	value     interface{}
+	comment   *comment
+	children  []Frozen
+
+	// siblings is the slice that this Frozen is stored in, and index is
+	// its position in that slice. top is set if siblings holds top-level
+	// keys.
+	siblings []Frozen
+	index    int
+	top      bool
+}
+
+// Freeze returns an immutable copy of this Node and its children. If this
+// Node has no parent, its siblings are also copied, and can be reached
+// using NextChild.
+//
+// Freeze is an accessor.
+func (n *Node) Freeze() *Frozen {
+	if n == nil {
+		return nil
+	}
+	list := freezeList(n, n.parent == nil)
+	return &relink(list, true)[0]
+}
+
+// freezeList copies first and, if all is set, its following siblings.
+func freezeList(first *Node, all bool) []Frozen {
+	count := 0
+	for c := first; c != nil; c = c.next {
+		count++
+		if !all {
+			break
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+
+	list := make([]Frozen, 0, count)
+	for c := first; c != nil && len(list) < count; c = c.next {
+		list = append(list, Frozen{
+			name:      c.name,
+			condition: c.condition,
+			value:     c.value,
+			comment:   c.comment,
+			children:  relink(freezeList(c.child, true), false),
+		})
+	}
+	return list
+}
+
+// relink records the position of each element of list in that element.
+func relink(list []Frozen, top bool) []Frozen {
+	for i := range list {
+		list[i].siblings = list
+		list[i].index = i
+		list[i].top = top
+	}
+	return list
+}
+
+// Thaw returns a mutable copy of this Frozen and its children. If this
+// Frozen is a top-level key, as returned by Freeze, the siblings after it
+// are also copied.
+//
+// Thaw is an accessor.
+func (f *Frozen) Thaw() *Node {
+	if f == nil {
+		return nil
+	}
+	var first, last *Node
+	for c := f; c != nil; c = c.NextChild() {
+		n := c.thaw()
+		if last == nil {
+			first = n
+		} else {
+			n.prev = last
+			last.next = n
+		}
+		last = n
+		if !f.top {
+			break
+		}
+	}
+	return first
+}
+
+func (f *Frozen) thaw() *Node {
+	n := &Node{name: f.name, condition: f.condition, value: f.value, comment: f.comment}
+	var last *Node
+	for i := range f.children {
+		c := f.children[i].thaw()
+		c.parent = n
+		if last == nil {
+			n.child = c
+		} else {
+			c.prev = last
+			last.next = c
+		}
+		last = c
+	}
+	return n
+}
+
+// With returns a copy of this Frozen with the key at path set to value.
+// The receiver is not modified, and the parts of the tree that are not on
+// the path are shared with the result.
+//
+// The path is the names of the keys from a child of this Frozen, separated
+// by slashes. Each name refers to the first key with that name, compared
+// ignoring case. Keys that do not exist are added at the end of their
+// subtree, and keys that hold a value are replaced by a subtree if needed.
+// An empty path sets the value of this Frozen itself.
+//
+// The value may be any type accepted by a Node setter, such as string,
+// int32, or color.NRGBA, or a *Frozen or *Node whose value or children
+// replace those of the key. Any other type will panic.
+//
+// The result has the same siblings as the receiver, so to update a key
+// deeper in the tree, call With on the top-level key.
+//
+// With is an accessor.
+func (f *Frozen) With(path string, value interface{}) *Frozen {
+	v := frozenValue(value)
+	return f.update(path, func(old Frozen) Frozen {
+		old.value, old.children = v.value, v.children
+		return old
+	})
+}
+
+// Without returns a copy of this Frozen with the key at path removed. The
+// path is interpreted as it is by With. If there is no key at path, the
+// receiver is returned.
+//
+// Without is an accessor.
+func (f *Frozen) Without(path string) *Frozen {
+	if path == "" {
+		return f
+	}
+	i := strings.LastIndexByte(path, '/')
+	parent, name := "", path
+	if i != -1 {
+		parent, name = path[:i], path[i+1:]
+	}
+	if f.find(parent).FirstByName(name) == nil {
+		return f
+	}
+
+	return f.update(parent, func(old Frozen) Frozen {
+		c := old.FirstByName(name)
+		children := make([]Frozen, 0, len(old.children)-1)
+		children = append(children, old.children[:c.index]...)
+		children = append(children, old.children[c.index+1:]...)
+		old.children = relink(children, false)
+		return old
+	})
+}
+
+// find returns the key at path, as interpreted by With, or nil.
+func (f *Frozen) find(path string) *Frozen {
+	if path == "" {
+		return f
+	}
+	for _, name := range strings.Split(path, "/") {
+		f = f.FirstByName(name)
+	}
+	return f
+}
+
+// update replaces this Frozen with a copy in which the key at path has been
+// changed by set, copying each subtree along the path.
+func (f *Frozen) update(path string, set func(Frozen) Frozen) *Frozen {
+	var names []string
+	if path != "" {
+		names = strings.Split(path, "/")
+	}
+
+	var siblings []Frozen
+	index, top := 0, true
+	if f == nil {
+		siblings = []Frozen{{}}
+	} else {
+		siblings, index, top = f.siblings, f.index, f.top
+	}
+
+	list := make([]Frozen, len(siblings))
+	copy(list, siblings)
+	list[index] = list[index].updated(names, set)
+	return &relink(list, top)[index]
+}
+
+// updated returns a copy of f in which the key at the path given by names
+// has been changed by set.
+func (f Frozen) updated(names []string, set func(Frozen) Frozen) Frozen {
+	if len(names) == 0 {
+		return set(f)
+	}
+
+	children := make([]Frozen, len(f.children), len(f.children)+1)
+	copy(children, f.children)
+	i := -1
+	for j := range children {
+		if strings.EqualFold(children[j].name, names[0]) {
+			i = j
+			break
+		}
+	}
+	if i == -1 {
+		i = len(children)
+		children = append(children, Frozen{name: names[0]})
+	}
+
+	children[i] = children[i].updated(names[1:], set)
+	f.value = nil
+	f.children = relink(children, false)
+	return f
+}
+
+// frozenValue returns a Frozen holding the value or children described by
+// value, as accepted by With.
+func frozenValue(value interface{}) Frozen {
+	switch v := value.(type) {
+	case string, int32, float32, uint32, color.NRGBA, uint64, int64:
+		return Frozen{value: v}
+	case []uint16:
+		c := make([]uint16, len(v))
+		copy(c, v)
+		return Frozen{value: c}
+	case *Frozen:
+		if v == nil {
+			return Frozen{}
+		}
+		return Frozen{value: v.value, children: v.children}
+	case *Node:
+		if v == nil {
+			return Frozen{}
+		}
+		return Frozen{value: v.value, children: relink(freezeList(v.child, true), false)}
+	}
+	panic(fmt.Sprintf("vdf: cannot use %T as a value", value))
+}
+
+// node returns a Node with the same value as this Frozen, for use by the
+// accessors that convert values.
+func (f *Frozen) node() Node {
+	if f == nil || len(f.children) != 0 {
+		return Node{}
+	}
+	return Node{value: f.value}
+}
+
+// Name returns the name of this Frozen.
+func (f *Frozen) Name() string {
+	if f == nil {
+		return ""
+	}
+	return f.name
+}
+
+// Condition returns the condition of this Frozen, as described by
+// Node.Condition.
+func (f *Frozen) Condition() string {
+	if f == nil {
+		return ""
+	}
+	return f.condition
+}
+
+// Comments returns the comments of this Frozen, as described by
+// Node.Comments.
+func (f *Frozen) Comments() (leading, trailing string) {
+	if f == nil || f.comment == nil {
+		return "", ""
+	}
+	return f.comment.leading, f.comment.trailing
+}
+
+// Kind returns the type of the value stored in this Frozen.
+func (f *Frozen) Kind() Kind {
+	n := f.node()
+	return n.Kind()
+}
+
+func (f *Frozen) String() string {
+	n := f.node()
+	return n.String()
+}
+
+func (f *Frozen) Int() int32 {
+	n := f.node()
+	return n.Int()
+}
+
+func (f *Frozen) Float() float32 {
+	n := f.node()
+	return n.Float()
+}
+
+func (f *Frozen) Ptr() uint32 {
+	n := f.node()
+	return n.Ptr()
+}
+
+func (f *Frozen) WString() []uint16 {
+	if f == nil || len(f.children) != 0 {
+		return nil
+	}
+	n := f.node()
+	return n.WString()
+}
+
+func (f *Frozen) Color() color.NRGBA {
+	n := f.node()
+	return n.Color()
+}
+
+func (f *Frozen) Uint64() uint64 {
+	n := f.node()
+	return n.Uint64()
+}
+
+func (f *Frozen) Int64() int64 {
+	n := f.node()
+	return n.Int64()
+}
+
+func (f *Frozen) advanceSimple(simple bool) *Frozen {
+	for next := f; next != nil; next = next.NextChild() {
+		if simple && len(next.children) == 0 {
+			return next
+		}
+		if !simple && next.value == nil {
+			return next
+		}
+	}
+	return nil
+}
+
+func (f *Frozen) FirstChild() *Frozen {
+	if f == nil || len(f.children) == 0 {
+		return nil
+	}
+	return &f.children[0]
+}
+
+func (f *Frozen) NextChild() *Frozen {
+	if f == nil || f.index+1 >= len(f.siblings) {
+		return nil
+	}
+	return &f.siblings[f.index+1]
+}
+
+func (f *Frozen) FirstSubTree() *Frozen { return f.FirstChild().advanceSimple(false) }
+func (f *Frozen) FirstValue() *Frozen   { return f.FirstChild().advanceSimple(true) }
+func (f *Frozen) NextSubTree() *Frozen  { return f.NextChild().advanceSimple(false) }
+func (f *Frozen) NextValue() *Frozen    { return f.NextChild().advanceSimple(true) }
+
+func (f *Frozen) FirstByName(name string) *Frozen {
+	for c := f.FirstChild(); c != nil; c = c.NextChild() {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (f *Frozen) NextByName(name string) *Frozen {
+	for c := f.NextChild(); c != nil; c = c.NextChild() {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package vdf_test
+
+import (
+	"image/color"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+func TestFreeze(t *testing.T) {
+	t.Parallel()
+
+	root := allKinds()
+	root.FirstByName("int").SetLeadingComment("comment")
+	root.FirstByName("float").SetCondition("$X")
+	f := root.Freeze()
+
+	if f.Name() != "root" || f.Kind() != vdf.KindSubtree || f.String() != "" {
+		t.Errorf("unexpected root: %q %v %q", f.Name(), f.Kind(), f.String())
+	}
+	n, c := root.FirstChild(), f.FirstChild()
+	for ; n != nil && c != nil; n, c = n.NextChild(), c.NextChild() {
+		if c.Name() != n.Name() || c.Kind() != n.Kind() || c.Condition() != n.Condition() ||
+			c.String() != n.String() || c.Int() != n.Int() || c.Float() != n.Float() ||
+			c.Ptr() != n.Ptr() || !reflect.DeepEqual(c.WString(), n.WString()) ||
+			c.Color() != n.Color() || c.Uint64() != n.Uint64() || c.Int64() != n.Int64() {
+			t.Errorf("%s: frozen value differs", n.Name())
+		}
+		nl, nt := n.Comments()
+		cl, ct := c.Comments()
+		if nl != cl || nt != ct {
+			t.Errorf("%s: frozen comments differ", n.Name())
+		}
+	}
+	if n != nil || c != nil {
+		t.Error("different number of children")
+	}
+	if f.FirstValue().Name() != "string" || f.FirstSubTree().Name() != "empty" || f.FirstByName("COLOR").Color() != (color.NRGBA{1, 2, 3, 4}) {
+		t.Error("unexpected result from search")
+	}
+
+	// Changing the Node does not change the Frozen.
+	root.FirstByName("string").SetString("changed")
+	if s := f.FirstByName("string").String(); s != "5" {
+		t.Errorf("expected %q, got %q", "5", s)
+	}
+
+	var nilFrozen *vdf.Frozen
+	if nilFrozen.Name() != "" || nilFrozen.FirstChild() != nil || nilFrozen.NextChild() != nil || nilFrozen.Int() != 0 || nilFrozen.Thaw() != nil {
+		t.Error("unexpected result for nil Frozen")
+	}
+}
+
+func TestFrozenWith(t *testing.T) {
+	t.Parallel()
+
+	n, err := vdf.DecodeText([]byte(`"config" { "server" { "port" "80" "host" "a" } "limits" { "max" "5" } }
+"other" "1"`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := n.Freeze()
+
+	v2 := v1.With("Server/port", int32(8080))
+	if v1.FirstByName("server").FirstByName("port").String() != "80" {
+		t.Error("With modified the receiver")
+	}
+	if port := v2.FirstByName("server").FirstByName("port"); port.Kind() != vdf.KindInt || port.Int() != 8080 {
+		t.Errorf("expected int 8080, got %v %q", port.Kind(), port.String())
+	}
+	if v1.FirstByName("limits").FirstChild() != v2.FirstByName("limits").FirstChild() {
+		t.Error("unchanged subtree was not shared")
+	}
+	if v2.NextChild().Name() != "other" {
+		t.Error("top-level siblings were not kept")
+	}
+
+	v3 := v2.With("server/tls/cert", "x.pem").With("limits", "none").With("", "flat")
+	if s := v2.Thaw(); s.FirstByName("server").FirstByName("tls") != nil {
+		t.Error("With modified the receiver")
+	}
+	out, err := v3.Thaw().MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "\"config\" \"flat\"\n\"other\" \"1\"\n"
+	if string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	var sub vdf.Node
+	if err = sub.UnmarshalText([]byte(`"x" { "y" "z" }`)); err != nil {
+		t.Fatal(err)
+	}
+	v4 := v2.With("server/tls", &sub).With("limits", v2.FirstByName("server")).Without("server/host").Without("missing/key")
+	out, err = v4.Thaw().MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "\"config\" {\n" +
+		"\t\"server\" {\n\t\t\"port\" \"8080\"\n\t\t\"tls\" {\n\t\t\t\"y\" \"z\"\n\t\t}\n\t}\n" +
+		"\t\"limits\" {\n\t\t\"port\" \"8080\"\n\t\t\"host\" \"a\"\n\t}\n" +
+		"}\n\"other\" \"1\"\n"
+	if string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for invalid value type")
+			}
+		}()
+		v1.With("x", 5)
+	}()
+}
+
+func TestFrozenConcurrent(t *testing.T) {
+	t.Parallel()
+
+	var root vdf.Node
+	root.SetName("root")
+	current := root.Freeze()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(f *vdf.Frozen) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for c := f.FirstChild(); c != nil; c = c.NextChild() {
+					_ = c.String()
+				}
+				f = f.With("key", int32(j))
+			}
+		}(current)
+	}
+	wg.Wait()
+
+	if current.FirstChild() != nil {
+		t.Error("shared snapshot was modified")
+	}
+}
@@ -0,0 +1,113 @@
+package vdf_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+var fuzzOptions = vdf.DecodeOptions{
+	MaxDepth:        64,
+	MaxNodes:        1 << 12,
+	MaxStringLength: 1 << 12,
+	MaxBytes:        1 << 20,
+}
+
+func addFuzzSeeds(f *testing.F, pattern string) {
+	files, err := filepath.Glob(filepath.Join("testdata", pattern))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, name := range files {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+}
+
+func FuzzText(f *testing.F) {
+	addFuzzSeeds(f, "*.txt")
+	f.Add([]byte("a { b { c { d 1 } } }"))
+	f.Add([]byte("\"a\\\"\" // comment\n[$X] {}"))
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		n, err := vdf.DecodeText(in, &fuzzOptions)
+		if err != nil {
+			return
+		}
+
+		out1, err := n.MarshalText()
+		if err != nil {
+			t.Fatal("couldn't serialize: ", err)
+		}
+		n2, err := vdf.DecodeText(out1, nil)
+		if err != nil {
+			t.Fatalf("couldn't parse serialized text %q: %v", out1, err)
+		}
+		out2, err := n2.MarshalText()
+		if err != nil {
+			t.Fatal("couldn't serialize again: ", err)
+		}
+		if !bytes.Equal(out1, out2) {
+			t.Errorf("serialization is not stable:\nfirst:  %q\nsecond: %q", out1, out2)
+		}
+
+		n.ClearFormatting()
+		out, err := n.MarshalText()
+		if err != nil {
+			t.Fatal("couldn't clean and serialize: ", err)
+		}
+		if _, err = vdf.DecodeText(out, nil); err != nil {
+			t.Errorf("couldn't parse cleaned output %q: %v", out, err)
+		}
+	})
+}
+
+func FuzzParseText(f *testing.F) {
+	addFuzzSeeds(f, "*.txt")
+	f.Add([]byte("a { b { c { d 1 } } }"))
+	f.Add([]byte("\"a\\\"\" // comment\n[$X] {}"))
+	f.Add([]byte("a /* b */ c # d\n"))
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		opts := fuzzOptions
+		opts.BlockComments = true
+		opts.HashComments = true
+		checkParseText(t, in, &opts)
+		opts.DiscardComments = true
+		checkParseText(t, in, &opts)
+	})
+}
+
+func FuzzBinary(f *testing.F) {
+	addFuzzSeeds(f, "*.bin")
+	f.Add([]byte("\x00a\x00\x05b\x00\x02\x00h\x00i\x00\x08\x08"))
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		n, err := vdf.DecodeBinary(in, &fuzzOptions)
+		if err != nil {
+			return
+		}
+
+		out1, err := n.MarshalBinary()
+		if err != nil {
+			t.Fatal("couldn't serialize: ", err)
+		}
+		n, err = vdf.DecodeBinary(out1, nil)
+		if err != nil {
+			t.Fatal("couldn't parse serialized data: ", err)
+		}
+		out2, err := n.MarshalBinary()
+		if err != nil {
+			t.Fatal("couldn't serialize again: ", err)
+		}
+		if !bytes.Equal(out1, out2) {
+			t.Errorf("serialization is not stable:\nfirst:  % x\nsecond: % x", out1, out2)
+		}
+	})
+}
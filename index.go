@@ -0,0 +1,121 @@
+package vdf
+
+import "sort"
+
+// childIndex maps the names of the children of a Node, compared ignoring
+// case, to the children with that name.
+type childIndex struct {
+	names map[string][]indexEntry
+	seqs  map[*Node]int
+	seq   int
+}
+
+// indexEntry is a child in a childIndex. Children that come later in the
+// list of children have a higher seq.
+type indexEntry struct {
+	node *Node
+	seq  int
+}
+
+// Index builds an index of the names of the children of this Node, so that
+// FirstByName and NextByName do not need to look at every child. This is
+// useful for subtrees with thousands of children. The index is kept up to
+// date by Append, Remove, and SetName. Calling Index on a Node that already
+// has an index does nothing.
+//
+// Index is a mutator.
+func (n *Node) Index() {
+	if n.index != nil {
+		return
+	}
+	idx := &childIndex{
+		names: make(map[string][]indexEntry),
+		seqs:  make(map[*Node]int),
+	}
+	for c := n.child; c != nil; c = c.next {
+		idx.add(c)
+	}
+	n.index = idx
+}
+
+// lookup returns the children named name. It does not allocate.
+func (idx *childIndex) lookup(name string) []indexEntry {
+	var buf [64]byte
+	return idx.names[string(appendFoldKey(buf[:0], name))]
+}
+
+// indexKey returns the key for name in childIndex.names, which is name
+// itself if it is already folded.
+func indexKey(name string) string {
+	var buf [64]byte
+	if key := appendFoldKey(buf[:0], name); string(key) != name {
+		return string(key)
+	}
+	return name
+}
+
+// search returns the position in list of the first child after seq.
+func search(list []indexEntry, seq int) int {
+	return sort.Search(len(list), func(i int) bool {
+		return list[i].seq > seq
+	})
+}
+
+func (idx *childIndex) first(name string) *Node {
+	if list := idx.lookup(name); len(list) != 0 {
+		return list[0].node
+	}
+	return nil
+}
+
+// next returns the first child after c named name. The second result is
+// false if c is not in the index.
+func (idx *childIndex) next(c *Node, name string) (*Node, bool) {
+	seq, ok := idx.seqs[c]
+	if !ok {
+		return nil, false
+	}
+	list := idx.lookup(name)
+	if i := search(list, seq); i < len(list) {
+		return list[i].node, true
+	}
+	return nil, true
+}
+
+// add adds c after the other children.
+func (idx *childIndex) add(c *Node) {
+	idx.insert(c, idx.seq)
+	idx.seq++
+}
+
+// insert adds c to the index in the position given by seq.
+func (idx *childIndex) insert(c *Node, seq int) {
+	key := indexKey(c.name)
+	list := idx.names[key]
+	i := search(list, seq)
+	list = append(list, indexEntry{})
+	copy(list[i+1:], list[i:])
+	list[i] = indexEntry{node: c, seq: seq}
+	idx.names[key] = list
+	idx.seqs[c] = seq
+}
+
+// remove removes c from the index, and returns the seq it had. The second
+// result is false if c is not in the index.
+func (idx *childIndex) remove(c *Node) (int, bool) {
+	seq, ok := idx.seqs[c]
+	if !ok {
+		return 0, false
+	}
+	delete(idx.seqs, c)
+
+	key := indexKey(c.name)
+	list := idx.names[key]
+	i := search(list, seq) - 1
+	if len(list) == 1 {
+		delete(idx.names, key)
+	} else {
+		idx.names[key] = append(list[:i], list[i+1:]...)
+	}
+	return seq, true
+}
@@ -0,0 +1,142 @@
+package vdf_test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+// checkIndex compares the results of FirstByName and NextByName on an
+// indexed Node with the results on an unindexed copy.
+func checkIndex(t *testing.T, n *vdf.Node, names ...string) {
+	t.Helper()
+
+	b, err := n.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plain vdf.Node
+	if err = plain.UnmarshalText(b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		expected, actual := plain.FirstByName(name), n.FirstByName(name)
+		for expected != nil && actual != nil {
+			if expected.Name() != actual.Name() || expected.String() != actual.String() {
+				t.Errorf("%q: expected %q %q, got %q %q", name, expected.Name(), expected.String(), actual.Name(), actual.String())
+			}
+			expected, actual = expected.NextByName(name), actual.NextByName(name)
+		}
+		if expected != nil || actual != nil {
+			t.Errorf("%q: different number of results", name)
+		}
+
+		// NextByName also works from a child with a different name.
+		expected, actual = plain.FirstChild(), n.FirstChild()
+		for expected != nil && actual != nil {
+			if expected.NextByName(name).String() != actual.NextByName(name).String() {
+				t.Errorf("%q: NextByName from %q differs", name, actual.Name())
+			}
+			expected, actual = expected.NextChild(), actual.NextChild()
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	t.Parallel()
+
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte(`"root" { "a" "1" "B" "2" "A" "3" "c" "4" "a" "5" }`)); err != nil {
+		t.Fatal(err)
+	}
+	n.Index()
+	n.Index()
+	names := []string{"a", "b", "C", "d", "new"}
+	checkIndex(t, &n, names...)
+
+	var c vdf.Node
+	c.SetName("A")
+	c.SetString("6")
+	n.Append(&c)
+	checkIndex(t, &n, names...)
+
+	n.FirstByName("a").NextByName("a").Remove()
+	n.FirstByName("c").Remove()
+	checkIndex(t, &n, names...)
+
+	n.FirstByName("b").SetName("a")
+	n.FirstChild().SetName("new")
+	c.SetName("b")
+	checkIndex(t, &n, names...)
+
+	// A removed child is no longer in the index, even if it is renamed.
+	c.Remove()
+	c.SetName("a")
+	checkIndex(t, &n, names...)
+
+	n.SetString("x")
+	if n.FirstByName("a") != nil {
+		t.Error("found a child after SetString")
+	}
+	c = vdf.Node{}
+	c.SetName("a")
+	n.Append(&c)
+	if n.FirstByName("A") != &c {
+		t.Error("index was not updated after SetString")
+	}
+}
+
+func wideSubtree(b *testing.B) *vdf.Node {
+	var doc strings.Builder
+	doc.WriteString("\"items\" {\n")
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&doc, "\t\"%d\" \"item\"\n", i)
+	}
+	doc.WriteString("}\n")
+
+	n, err := vdf.ParseText([]byte(doc.String()), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return n
+}
+
+func benchmarkWideSubtree(b *testing.B, index bool) {
+	n := wideSubtree(b)
+	if index {
+		n.Index()
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if n.FirstByName(strconv.Itoa(i%20000)) == nil {
+			b.Fatal("not found")
+		}
+	}
+}
+
+func BenchmarkFirstByNameWide(b *testing.B) {
+	b.Run("Plain", func(b *testing.B) {
+		benchmarkWideSubtree(b, false)
+	})
+	b.Run("Index", func(b *testing.B) {
+		benchmarkWideSubtree(b, true)
+	})
+}
+
+func BenchmarkIndex(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		n := wideSubtree(b)
+		b.StartTimer()
+
+		n.Index()
+	}
+}
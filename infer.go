@@ -0,0 +1,173 @@
+package vdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InferOptions controls how InferTypes converts string values.
+//
+// The zero value of InferOptions converts integers that fit in 32 bits to
+// KindInt and decimal numbers to KindFloat, which is how Source detects
+// numbers when it reads text KeyValues.
+type InferOptions struct {
+	// Kinds are the kinds that a string may be converted to, in order of
+	// preference. The first kind that the string can be parsed as is used.
+	// If Kinds is nil, KindInt and KindFloat are used.
+	//
+	// A value is only inferred to be KindFloat if it has a decimal point
+	// or an exponent, so integers that are too large for the other kinds
+	// stay strings rather than losing precision. KindColor requires four
+	// integers from 0 to 255 separated by spaces.
+	Kinds []Kind
+
+	// Overrides set the kind of the values at specific paths, regardless
+	// of Kinds. The first override whose path matches is used. A value
+	// that cannot be parsed as the kind of its override is an error.
+	Overrides []TypeOverride
+
+	// Strict only converts a value if it would be written as the same text
+	// afterwards, so "007", "+1", and "1.50" stay strings. Values matched
+	// by Overrides must still be parsed, but are not required to keep
+	// their text.
+	Strict bool
+}
+
+// TypeOverride sets the kind of the values at a path.
+type TypeOverride struct {
+	// Path is the names of the keys from the Node passed to InferTypes,
+	// starting with its own name and separated by slashes. Names are
+	// matched ignoring case, and * matches any one name.
+	Path string
+	// Kind is the kind of the value. KindString leaves the value as a
+	// string.
+	Kind Kind
+}
+
+var defaultInferKinds = []Kind{KindInt, KindFloat}
+
+// InferTypes converts the string values of this Node and its children to
+// other kinds according to opts, so that MarshalBinary writes them with a
+// more specific type. A nil *InferOptions is equivalent to the zero value.
+// Empty strings and values that are not strings are never changed. If
+// this Node has no parent, its siblings are also converted.
+//
+// InferTypes is a mutator.
+func (n *Node) InferTypes(opts *InferOptions) error {
+	if opts == nil {
+		opts = &InferOptions{}
+	}
+	kinds := opts.Kinds
+	if kinds == nil {
+		kinds = defaultInferKinds
+	}
+
+	overrides := make([][]string, len(opts.Overrides))
+	for i, o := range opts.Overrides {
+		overrides[i] = strings.Split(o.Path, "/")
+	}
+
+	inf := &inferrer{opts: opts, kinds: kinds, overrides: overrides}
+	for c := n; c != nil; c = c.next {
+		if err := inf.infer(c, nil); err != nil {
+			return err
+		}
+		if c.parent != nil {
+			break
+		}
+	}
+	return nil
+}
+
+type inferrer struct {
+	opts      *InferOptions
+	kinds     []Kind
+	overrides [][]string
+}
+
+func (inf *inferrer) infer(n *Node, path []string) error {
+	path = append(path, n.name)
+
+	if n.Kind() == KindSubtree {
+		for c := n.child; c != nil; c = c.next {
+			if err := inf.infer(c, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s, ok := n.value.(string)
+	if !ok || s == "" {
+		return nil
+	}
+
+	for i, o := range inf.overrides {
+		if !matchPath(o, path) {
+			continue
+		}
+		k := inf.opts.Overrides[i].Kind
+		v, err := parseKind(s, k)
+		if err != nil {
+			return fmt.Errorf("vdf: %s: invalid %v value %q", strings.Join(path, "/"), k, s)
+		}
+		n.value = v
+		return nil
+	}
+
+	for _, k := range inf.kinds {
+		if !looksLike(s, k) {
+			continue
+		}
+		v, err := parseKind(s, k)
+		if err != nil {
+			continue
+		}
+		old := n.value
+		n.value = v
+		if inf.opts.Strict && n.String() != s {
+			n.value = old
+			continue
+		}
+		return nil
+	}
+	return nil
+}
+
+// parseKind converts s to a value of kind k.
+func parseKind(s string, k Kind) (interface{}, error) {
+	if k == KindSubtree {
+		return nil, fmt.Errorf("vdf: cannot infer subtree")
+	}
+	return parseAnnotated(s, k.String())
+}
+
+// looksLike rejects strings that strconv would accept but that are not
+// written as numbers of kind k in KeyValues, such as "inf" and "0x1p3" for
+// floats and integers for floats.
+func looksLike(s string, k Kind) bool {
+	switch k {
+	case KindFloat:
+		if !strings.ContainsAny(s, ".eE") {
+			return false
+		}
+		return strings.Trim(s, "+-.0123456789eE") == ""
+	case KindInt, KindInt64, KindPtr, KindUint64:
+		return strings.Trim(s, "+-0123456789") == ""
+	}
+	return true
+}
+
+// matchPath reports whether path matches the pattern, as described in
+// TypeOverride.
+func matchPath(pattern, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && !strings.EqualFold(p, path[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package vdf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+func TestInferTypes(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		value string
+		opts  *vdf.InferOptions
+		kind  vdf.Kind
+		out   string
+	}{
+		{"int", "42", nil, vdf.KindInt, "42"},
+		{"negative", "-7", nil, vdf.KindInt, "-7"},
+		{"float", "0.25", nil, vdf.KindFloat, "0.25"},
+		{"exponent", "1e3", nil, vdf.KindFloat, "1000"},
+		{"too-big", "4294967296", nil, vdf.KindString, "4294967296"},
+		{"word", "hello", nil, vdf.KindString, "hello"},
+		{"inf", "inf", nil, vdf.KindString, "inf"},
+		{"hex", "0x10", nil, vdf.KindString, "0x10"},
+		{"empty", "", nil, vdf.KindString, ""},
+		{"color-default", "1 2 3 4", nil, vdf.KindString, "1 2 3 4"},
+		{"leading-zero", "007", nil, vdf.KindInt, "7"},
+		{"leading-zero-strict", "007", &vdf.InferOptions{Strict: true}, vdf.KindString, "007"},
+		{"trailing-zero-strict", "1.50", &vdf.InferOptions{Strict: true}, vdf.KindString, "1.50"},
+		{"precision-strict", "3.14159265358979", &vdf.InferOptions{Strict: true}, vdf.KindString, "3.14159265358979"},
+		{"float-strict", "1.5", &vdf.InferOptions{Strict: true}, vdf.KindFloat, "1.5"},
+		{"uint64", "4294967296", &vdf.InferOptions{Kinds: []vdf.Kind{vdf.KindInt, vdf.KindUint64}}, vdf.KindUint64, "4294967296"},
+		{"uint64-negative", "-4294967296", &vdf.InferOptions{Kinds: []vdf.Kind{vdf.KindInt, vdf.KindUint64}}, vdf.KindString, "-4294967296"},
+		{"color", "1 2 3 4", &vdf.InferOptions{Kinds: []vdf.Kind{vdf.KindColor}}, vdf.KindColor, "1 2 3 4"},
+		{"color-range", "1 2 3 400", &vdf.InferOptions{Kinds: []vdf.Kind{vdf.KindColor}}, vdf.KindString, "1 2 3 400"},
+		{"int-only", "0.5", &vdf.InferOptions{Kinds: []vdf.Kind{vdf.KindInt}}, vdf.KindString, "0.5"},
+	} {
+		tt := tt // shadow
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var n vdf.Node
+			n.SetName("key")
+			n.SetString(tt.value)
+			if err := n.InferTypes(tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if k := n.Kind(); k != tt.kind {
+				t.Errorf("expected %v, got %v", tt.kind, k)
+			}
+			if s := n.String(); s != tt.out {
+				t.Errorf("expected %q, got %q", tt.out, s)
+			}
+		})
+	}
+}
+
+func TestInferTypesOverrides(t *testing.T) {
+	t.Parallel()
+
+	n, err := vdf.DecodeText([]byte(`"root" {
+	"version" "3"
+	"name" "123"
+	"items" {
+		"1" { "id" "1" "price" "5" "tint" "255 0 0 255" }
+		"2" { "id" "2" "price" "7.5" "tint" "0 0 255 255" }
+	}
+}
+"other" "1"
+`), &vdf.DecodeOptions{InferTypes: &vdf.InferOptions{
+		Overrides: []vdf.TypeOverride{
+			{Path: "root/name", Kind: vdf.KindString},
+			{Path: "root/items/*/PRICE", Kind: vdf.KindFloat},
+			{Path: "root/items/*/tint", Kind: vdf.KindColor},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := n.FirstByName("items")
+	for _, tt := range []struct {
+		n    *vdf.Node
+		kind vdf.Kind
+	}{
+		{n.FirstByName("version"), vdf.KindInt},
+		{n.FirstByName("name"), vdf.KindString},
+		{items.FirstByName("1").FirstByName("id"), vdf.KindInt},
+		{items.FirstByName("1").FirstByName("price"), vdf.KindFloat},
+		{items.FirstByName("2").FirstByName("price"), vdf.KindFloat},
+		{items.FirstByName("2").FirstByName("tint"), vdf.KindColor},
+		{n.NextChild(), vdf.KindInt},
+	} {
+		if k := tt.n.Kind(); k != tt.kind {
+			t.Errorf("%s: expected %v, got %v", tt.n.Name(), tt.kind, k)
+		}
+	}
+
+	// The text is written as it was read.
+	out, err := vdf.EncodeText(items.FirstByName("1"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `"1" { "id" "1" "price" "5" "tint" "255 0 0 255" }`; strings.TrimSpace(string(out)) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	_, err = vdf.DecodeText([]byte(`"root" { "a" "x" }`), &vdf.DecodeOptions{InferTypes: &vdf.InferOptions{
+		Overrides: []vdf.TypeOverride{{Path: "root/a", Kind: vdf.KindInt}},
+	}})
+	if expected := `vdf: root/a: invalid int value "x"`; err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
@@ -21,18 +21,54 @@ func TestContract(t *testing.T) {
 		name string
 		f    func(t *testing.T, n *vdf.Node)
 	}{
+		{
+			name: "Bool",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.Bool()
+			},
+		},
+		{
+			name: "BoolE",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.BoolE()
+			},
+		},
 		{
 			name: "Color",
 			f: func(t *testing.T, n *vdf.Node) {
 				n.Color()
 			},
 		},
+		{
+			name: "ColorE",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.ColorE()
+			},
+		},
+		{
+			name: "Comments",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.Comments()
+			},
+		},
 		{
 			name: "Condition",
 			f: func(t *testing.T, n *vdf.Node) {
 				n.Condition()
 			},
 		},
+		{
+			name: "Duration",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.Duration()
+			},
+		},
+		{
+			name: "DurationE",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.DurationE()
+			},
+		},
 		{
 			name: "FirstByName",
 			f: func(t *testing.T, n *vdf.Node) {
@@ -63,12 +99,60 @@ func TestContract(t *testing.T) {
 				n.Float()
 			},
 		},
+		{
+			name: "FloatE",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.FloatE()
+			},
+		},
+		{
+			name: "Freeze",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.Freeze()
+			},
+		},
 		{
 			name: "Int",
 			f: func(t *testing.T, n *vdf.Node) {
 				n.Int()
 			},
 		},
+		{
+			name: "Int64",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.Int64()
+			},
+		},
+		{
+			name: "Int64E",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.Int64E()
+			},
+		},
+		{
+			name: "IntE",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.IntE()
+			},
+		},
+		{
+			name: "IntMap",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.IntMap()
+			},
+		},
+		{
+			name: "Keys",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.Keys()
+			},
+		},
+		{
+			name: "Kind",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.Kind()
+			},
+		},
 		{
 			name: "MarshalBinary",
 			f: func(t *testing.T, n *vdf.Node) {
@@ -123,18 +207,42 @@ func TestContract(t *testing.T) {
 				n.Ptr()
 			},
 		},
+		{
+			name: "PtrE",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.PtrE()
+			},
+		},
 		{
 			name: "String",
 			f: func(t *testing.T, n *vdf.Node) {
 				n.String()
 			},
 		},
+		{
+			name: "StringMap",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.StringMap()
+			},
+		},
+		{
+			name: "Symbol",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.Symbol()
+			},
+		},
 		{
 			name: "Uint64",
 			f: func(t *testing.T, n *vdf.Node) {
 				n.Uint64()
 			},
 		},
+		{
+			name: "Uint64E",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.Uint64E()
+			},
+		},
 		{
 			name: "WString",
 			f: func(t *testing.T, n *vdf.Node) {
@@ -159,6 +267,20 @@ func TestContract(t *testing.T) {
 				n.ClearFormatting()
 			},
 		},
+		{
+			name: "Index",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.Index()
+			},
+		},
+		{
+			name: "InferTypes",
+			f: func(t *testing.T, n *vdf.Node) {
+				if err := n.InferTypes(nil); err != nil {
+					t.Error(err)
+				}
+			},
+		},
 		{
 			name: "Remove",
 			f: func(t *testing.T, n *vdf.Node) {
@@ -189,6 +311,18 @@ func TestContract(t *testing.T) {
 				n.SetInt(42)
 			},
 		},
+		{
+			name: "SetInt64",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.SetInt64(-1234567890123456789)
+			},
+		},
+		{
+			name: "SetLeadingComment",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.SetLeadingComment("Hello,\nWorld!")
+			},
+		},
 		{
 			name: "SetName",
 			f: func(t *testing.T, n *vdf.Node) {
@@ -207,6 +341,12 @@ func TestContract(t *testing.T) {
 				n.SetString("Hello, World!")
 			},
 		},
+		{
+			name: "SetTrailingComment",
+			f: func(t *testing.T, n *vdf.Node) {
+				n.SetTrailingComment("Hello, World!")
+			},
+		},
 		{
 			name: "SetUint64",
 			f: func(t *testing.T, n *vdf.Node) {
@@ -0,0 +1,155 @@
+package vdf
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Kind is the type of the value stored in a Node. Each Kind other than
+// KindSubtree corresponds to one of the types in the binary format.
+type Kind int
+
+const (
+	// KindSubtree is a Node with children, or an empty subtree.
+	KindSubtree Kind = iota
+	KindString
+	// KindInt is a signed 32-bit integer, as set by SetInt.
+	KindInt
+	// KindFloat is a 32-bit floating-point number, as set by SetFloat.
+	KindFloat
+	// KindPtr is an unsigned 32-bit integer, as set by SetPtr.
+	KindPtr
+	// KindWString is a UTF-16 string, as set by SetWString.
+	KindWString
+	// KindColor is a color, as set by SetColor.
+	KindColor
+	// KindUint64 is an unsigned 64-bit integer, as set by SetUint64.
+	KindUint64
+	// KindInt64 is a signed 64-bit integer, as set by SetInt64.
+	KindInt64
+)
+
+var kindNames = [...]string{
+	KindSubtree: "subtree",
+	KindString:  "string",
+	KindInt:     "int",
+	KindFloat:   "float",
+	KindPtr:     "ptr",
+	KindWString: "wstring",
+	KindColor:   "color",
+	KindUint64:  "uint64",
+	KindInt64:   "int64",
+}
+
+func (k Kind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Kind returns the type of the value stored in this Node. Values read from
+// text are strings unless they were annotated with their type; see
+// EncodeOptions.TypeAnnotations.
+//
+// Kind is an accessor.
+func (n *Node) Kind() Kind {
+	n = n.notNil()
+	if n.child != nil {
+		return KindSubtree
+	}
+
+	switch n.value.(type) {
+	case nil:
+		return KindSubtree
+	case string:
+		return KindString
+	case int32:
+		return KindInt
+	case float32:
+		return KindFloat
+	case uint32:
+		return KindPtr
+	case []uint16:
+		return KindWString
+	case color.NRGBA:
+		return KindColor
+	case uint64:
+		return KindUint64
+	case int64:
+		return KindInt64
+	}
+	panic("invalid vdf.Node")
+}
+
+// typeAnnotation is the start of the trailing comment that records the
+// type of a value in text written with EncodeOptions.TypeAnnotations.
+const typeAnnotation = "vdf:"
+
+// annotation returns the type annotation for n, or an empty string if n
+// does not need one.
+func (n *Node) annotation() string {
+	switch k := n.Kind(); k {
+	case KindSubtree, KindString:
+		return ""
+	default:
+		return typeAnnotation + k.String()
+	}
+}
+
+// splitAnnotation separates a type annotation from the rest of a trailing
+// comment.
+func splitAnnotation(text string) (kind, rest string, ok bool) {
+	if !strings.HasPrefix(text, typeAnnotation) {
+		return "", text, false
+	}
+	kind = strings.TrimPrefix(text, typeAnnotation)
+	if i := strings.IndexByte(kind, ' '); i != -1 {
+		kind, rest = kind[:i], kind[i+1:]
+	}
+	return kind, rest, true
+}
+
+// parseAnnotated converts s to a value of the type named by kind.
+func parseAnnotated(s, kind string) (interface{}, error) {
+	var v interface{}
+	var err error
+	switch kind {
+	case "string":
+		v = s
+	case "int":
+		var i int64
+		i, err = strconv.ParseInt(s, 10, 32)
+		v = int32(i)
+	case "float":
+		var f float64
+		f, err = strconv.ParseFloat(s, 32)
+		v = float32(f)
+	case "ptr":
+		var i uint64
+		i, err = strconv.ParseUint(s, 10, 32)
+		v = uint32(i)
+	case "wstring":
+		v = utf16.Encode([]rune(s))
+	case "color":
+		var c color.NRGBA
+		var extra string
+		if n, _ := fmt.Sscanf(s+" ", "%d %d %d %d %s", &c.R, &c.G, &c.B, &c.A, &extra); n != 4 {
+			err = fmt.Errorf("expected four components")
+		}
+		v = c
+	case "uint64":
+		v, err = strconv.ParseUint(s, 10, 64)
+	case "int64":
+		v, err = strconv.ParseInt(s, 10, 64)
+	default:
+		return nil, fmt.Errorf("vdf: unknown type annotation %q", kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vdf: invalid %s value %q", kind, s)
+	}
+	return v, nil
+}
@@ -0,0 +1,192 @@
+package vdf_test
+
+import (
+	"bytes"
+	"image/color"
+	"io/ioutil"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+func allKinds() *vdf.Node {
+	var root vdf.Node
+	root.SetName("root")
+	add := func(name string, set func(n *vdf.Node)) {
+		n := new(vdf.Node)
+		n.SetName(name)
+		set(n)
+		root.Append(n)
+	}
+	add("string", func(n *vdf.Node) { n.SetString("5") })
+	add("int", func(n *vdf.Node) { n.SetInt(-5) })
+	add("float", func(n *vdf.Node) { n.SetFloat(0.5) })
+	add("ptr", func(n *vdf.Node) { n.SetPtr(0xdeadbeef) })
+	add("wstring", func(n *vdf.Node) { n.SetWString([]uint16{0xd83d, 0xdca9}) })
+	add("color", func(n *vdf.Node) { n.SetColor(color.NRGBA{1, 2, 3, 4}) })
+	add("uint64", func(n *vdf.Node) { n.SetUint64(1 << 63) })
+	add("int64", func(n *vdf.Node) { n.SetInt64(-1 << 40) })
+	add("empty", func(n *vdf.Node) {})
+	return &root
+}
+
+func TestKind(t *testing.T) {
+	t.Parallel()
+
+	expected := []vdf.Kind{vdf.KindString, vdf.KindInt, vdf.KindFloat, vdf.KindPtr, vdf.KindWString, vdf.KindColor, vdf.KindUint64, vdf.KindInt64, vdf.KindSubtree}
+	root := allKinds()
+	if k := root.Kind(); k != vdf.KindSubtree {
+		t.Errorf("root: expected %v, got %v", vdf.KindSubtree, k)
+	}
+	i := 0
+	for c := root.FirstChild(); c != nil; c = c.NextChild() {
+		if k := c.Kind(); k != expected[i] {
+			t.Errorf("%s: expected %v, got %v", c.Name(), expected[i], k)
+		}
+		i++
+	}
+
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte(`"a" "5"`)); err != nil {
+		t.Fatal(err)
+	}
+	if k := n.Kind(); k != vdf.KindString {
+		t.Errorf("text value: expected %v, got %v", vdf.KindString, k)
+	}
+	if s := vdf.Kind(100).String(); s != "Kind(100)" {
+		t.Errorf("unexpected name for invalid kind: %q", s)
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	t.Parallel()
+
+	root := allKinds()
+	root.FirstByName("int").SetTrailingComment("comment")
+	root.FirstByName("float").SetCondition("$X")
+
+	text, err := vdf.EncodeText(root, &vdf.EncodeOptions{TypeAnnotations: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "\"root\" {\n" +
+		"\t\"string\" \"5\"\n" +
+		"\t\"int\" \"-5\" //vdf:int comment\n" +
+		"\t\"float\" \"0.5\" [$X] //vdf:float\n" +
+		"\t\"ptr\" \"3735928559\" //vdf:ptr\n" +
+		"\t\"wstring\" \"\U0001F4A9\" //vdf:wstring\n" +
+		"\t\"color\" \"1 2 3 4\" //vdf:color\n" +
+		"\t\"uint64\" \"9223372036854775808\" //vdf:uint64\n" +
+		"\t\"int64\" \"-1099511627776\" //vdf:int64\n" +
+		"\t\"empty\" {\n\t}\n" +
+		"}\n"
+	if string(text) != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+
+	n, err := vdf.DecodeText(text, &vdf.DecodeOptions{TypeAnnotations: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eopts := &vdf.EncodeOptions{ExtendedTypes: true}
+	want, err := vdf.EncodeBinary(root, eopts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := vdf.EncodeBinary(n, eopts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("binary differs after annotated text round trip")
+	}
+	if _, trailing := n.FirstByName("int").Comments(); trailing != "comment" {
+		t.Errorf("expected annotation to be removed from comment, got %q", trailing)
+	}
+
+	// The recorded formatting is kept, with annotations updated to match
+	// the values.
+	n.FirstByName("string").SetInt(5)
+	n.FirstByName("int").SetString("x")
+	n.FirstByName("ptr").SetString("x")
+	out, err := vdf.EncodeText(n, &vdf.EncodeOptions{TypeAnnotations: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "\"root\" {\n" +
+		"\t\"string\" \"5\" //vdf:int\n" +
+		"\t\"int\" \"x\" // comment\n" +
+		"\t\"float\" \"0.5\" [$X] //vdf:float\n" +
+		"\t\"ptr\" \"x\"\n" +
+		"\t\"wstring\" \"\U0001F4A9\" //vdf:wstring\n" +
+		"\t\"color\" \"1 2 3 4\" //vdf:color\n" +
+		"\t\"uint64\" \"9223372036854775808\" //vdf:uint64\n" +
+		"\t\"int64\" \"-1099511627776\" //vdf:int64\n" +
+		"\t\"empty\" {\n\t}\n" +
+		"}\n"
+	if string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	// Without the decoder option, annotations are only comments.
+	if n, err = vdf.DecodeText(text, nil); err != nil {
+		t.Fatal(err)
+	}
+	if k := n.FirstByName("int").Kind(); k != vdf.KindString {
+		t.Errorf("expected %v without TypeAnnotations, got %v", vdf.KindString, k)
+	}
+}
+
+func TestTypeAnnotationsBinary(t *testing.T) {
+	t.Parallel()
+
+	in, err := ioutil.ReadFile("testdata/UserGameStatsSchema_630.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n vdf.Node
+	if err = n.UnmarshalBinary(in); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := vdf.EncodeText(&n, &vdf.EncodeOptions{TypeAnnotations: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := vdf.DecodeText(text, &vdf.DecodeOptions{TypeAnnotations: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := decoded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(in, out) {
+		t.Error("binary differs after annotated text round trip")
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		err  string
+	}{
+		{"unknown", `"a" "1" //vdf:bogus`, `vdf: unknown type annotation "bogus"`},
+		{"int", `"a" "x" //vdf:int`, `vdf: invalid int value "x"`},
+		{"int-range", `"a" "3000000000" //vdf:int`, `vdf: invalid int value "3000000000"`},
+		{"color", `"a" "1 2 3" //vdf:color`, `vdf: invalid color value "1 2 3"`},
+		{"color-extra", `"a" "1 2 3 4 5" //vdf:color`, `vdf: invalid color value "1 2 3 4 5"`},
+	} {
+		tt := tt // shadow
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := vdf.DecodeText([]byte(tt.in), &vdf.DecodeOptions{TypeAnnotations: true})
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,1113 @@
+package kv3
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// Magic numbers at the start of binary KeyValues3 data, read as
+// little-endian 32-bit integers.
+const (
+	magicVKV3 = 0x03564b56 // "VKV\x03"
+	magicKV3  = 0x4b563300 // "\x003VK" plus the version in the low byte
+)
+
+const (
+	trailerVKV3 = 0xffffffff
+	trailerKV3  = 0xffeedd00
+)
+
+// Compression methods of KV3 version 1 and later.
+const (
+	compressionNone = 0
+	compressionLZ4  = 1
+	compressionZstd = 2
+)
+
+// Node types in binary KeyValues3.
+const (
+	typeNull       = 1
+	typeBool       = 2
+	typeInt64      = 3
+	typeUint64     = 4
+	typeDouble     = 5
+	typeString     = 6
+	typeBlob       = 7
+	typeArray      = 8
+	typeObject     = 9
+	typeTypedArray = 10
+	typeInt32      = 11
+	typeUint32     = 12
+	typeTrue       = 13
+	typeFalse      = 14
+	typeInt64Zero  = 15
+	typeInt64One   = 16
+	typeDoubleZero = 17
+	typeDoubleOne  = 18
+	typeFloat      = 19
+	typeFlagged    = 0x80
+	typeMask       = 0x3f
+)
+
+// Node types added in KV3 versions 4 and 5.
+const (
+	typeInt16          = 20
+	typeUint16         = 21
+	typeInt32Byte      = 23 // an int32 stored in the byte buffer
+	typeByteTypedArray = 24 // a typed array with its length in the byte buffer
+	typeAuxTypedArray  = 25 // a byte typed array with its elements in the first buffer
+)
+
+// The last node type of each version of the format.
+const (
+	maxTypeV3 = typeFloat
+	maxTypeV4 = typeByteTypedArray
+	maxTypeV5 = typeAuxTypedArray
+)
+
+// encodingBlockBC is the GUID of the block compressed encoding, which is only
+// used by the legacy VKV3 format.
+const encodingBlockBC = "95791a46-95bc-4f6c-a70b-05bca1b7dfd2"
+
+// maxBinaryVersion is the latest version of the KV3 format that DecodeBinary
+// supports.
+const maxBinaryVersion = 5
+
+// ErrUnsupported is wrapped by errors returned by DecodeBinary for valid
+// binary KeyValues3 data that uses a feature this package does not
+// implement, such as Zstandard dictionaries.
+var ErrUnsupported = errors.New("kv3: unsupported binary KeyValues3 feature")
+
+// DecodeBinary decodes binary KeyValues3 data, as found in the DATA block of
+// Source 2 resource files, from r.
+//
+// The legacy VKV3 format is supported with the uncompressed, LZ4, and block
+// compressed encodings. The KV3 format is supported for versions 1 through
+// 5 when it is uncompressed, LZ4 compressed, or Zstandard compressed without
+// a dictionary, including binary blobs stored in separate blocks.
+func DecodeBinary(r io.Reader) (*Document, error) {
+	var magic uint32
+	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if magic == magicVKV3 {
+		return decodeVKV3(r)
+	}
+	if magic&^0xff == magicKV3 && magic&0xff != 0 {
+		version := int(magic & 0xff)
+		if version > maxBinaryVersion {
+			return nil, fmt.Errorf("%w: version %d", ErrUnsupported, version)
+		}
+		return decodeKV3(r, version)
+	}
+	return nil, fmt.Errorf("kv3: invalid magic number %08x", magic)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func readGUID(r io.Reader) (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	return formatGUID(b), nil
+}
+
+// formatGUID formats a GUID stored in the Microsoft byte order, where the
+// first three groups are little-endian.
+func formatGUID(b [16]byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:]),
+		binary.LittleEndian.Uint16(b[4:]),
+		binary.LittleEndian.Uint16(b[6:]),
+		b[8:10], b[10:])
+}
+
+func formatName(guid string) string {
+	if guid == FormatGeneric {
+		return "generic"
+	}
+	return "unknown"
+}
+
+func decodeVKV3(r io.Reader) (*Document, error) {
+	encoding, err := readGUID(r)
+	if err != nil {
+		return nil, err
+	}
+	format, err := readGUID(r)
+	if err != nil {
+		return nil, err
+	}
+	rest, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Document{Header: Header{
+		EncodingVersion: encoding,
+		Format:          formatName(format),
+		FormatVersion:   format,
+	}}
+
+	var buf []byte
+	switch encoding {
+	case EncodingBinary:
+		d.Header.Encoding = "binary"
+		buf = rest
+	case EncodingBinaryLZ4:
+		d.Header.Encoding = "binary_lz4"
+		if len(rest) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		size := binary.LittleEndian.Uint32(rest)
+		if buf, err = decompressLZ4(rest[4:], int(size)); err != nil {
+			return nil, err
+		}
+	case encodingBlockBC:
+		d.Header.Encoding = "binary_bc"
+		if buf, err = decompressBlock(rest); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("kv3: unknown encoding %s", encoding)
+	}
+
+	// Everything is stored inline in a single stream.
+	c := &cursor{b: buf}
+	kr := &binaryReader{
+		valueBuffers: valueBuffers{bytes: c, ints: c, eights: c},
+		types:        c,
+		size:         len(buf),
+		budget:       len(buf),
+		maxType:      maxTypeV3,
+	}
+	count, err := c.int32()
+	if err != nil {
+		return nil, err
+	}
+	if kr.strings, err = c.strings(int(count)); err != nil {
+		return nil, err
+	}
+	if d.Root, err = kr.value(); err != nil {
+		return nil, err
+	}
+	if trailer, err := c.int32(); err != nil || uint32(trailer) != trailerVKV3 {
+		return nil, fmt.Errorf("kv3: missing trailer")
+	}
+	return d, nil
+}
+
+// decompressBlock decompresses Valve's block compression, which is a simple
+// LZ77 variant used by early Source 2 resources.
+func decompressBlock(src []byte) ([]byte, error) {
+	if len(src) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	size := int(src[0]) | int(src[1])<<8 | int(src[2])<<16
+	if src[3]&0x80 != 0 {
+		return src[4:], nil
+	}
+
+	// The size is only trusted as far as the input could produce it.
+	dst := make([]byte, 0, minInt(size, 9*len(src)))
+	for i := 4; len(dst) < size; {
+		if i+2 > len(src) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		mask := binary.LittleEndian.Uint16(src[i:])
+		i += 2
+		for bit := uint(0); bit < 16 && len(dst) < size; bit++ {
+			if mask&(1<<bit) == 0 {
+				if i >= len(src) {
+					return nil, io.ErrUnexpectedEOF
+				}
+				dst = append(dst, src[i])
+				i++
+				continue
+			}
+
+			if i+2 > len(src) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			lookup := binary.LittleEndian.Uint16(src[i:])
+			i += 2
+			offset := int(lookup>>4) + 1
+			length := int(lookup&15) + 3
+			if offset > len(dst) || length > size-len(dst) {
+				return nil, fmt.Errorf("kv3: corrupt block compressed data")
+			}
+			start := len(dst) - offset
+			for j := 0; j < length; j++ {
+				dst = append(dst, dst[start+j])
+			}
+		}
+	}
+	return dst, nil
+}
+
+// kv3Header is the header of the KV3 format, after the format GUID. Version 1
+// only has Compression, BytesCount, IntsCount, EightsCount, and Uncompressed,
+// versions 2 and 3 have kv3HeaderV2, and each later version appends fields.
+type kv3Header struct {
+	kv3HeaderV2
+	kv3HeaderV4
+	kv3HeaderV5
+}
+
+type kv3HeaderV2 struct {
+	Compression      uint32
+	DictionaryID     uint16
+	FrameSize        uint16
+	BytesCount       uint32
+	IntsCount        uint32
+	EightsCount      uint32
+	StringsTypesSize uint32
+	ObjectCount      uint16
+	ArrayCount       uint16
+	Uncompressed     uint32
+	Compressed       uint32
+	BlockCount       uint32
+	BlockTotalSize   uint32
+}
+
+// kv3HeaderV4 adds a buffer of 2-byte values and the size of the LZ4 chunk
+// lengths of the blocks.
+type kv3HeaderV4 struct {
+	ShortsCount      uint32
+	ChunkLengthsSize uint32
+}
+
+// kv3HeaderV5 describes the two buffers of version 5. The counts in
+// kv3HeaderV2 and kv3HeaderV4 are for the first buffer, which holds the
+// strings and the values of auxiliary typed arrays, and these counts are
+// for the second, which holds everything else.
+type kv3HeaderV5 struct {
+	Uncompressed1 uint32
+	Compressed1   uint32
+	Uncompressed2 uint32
+	Compressed2   uint32
+	BytesCount2   uint32
+	ShortsCount2  uint32
+	IntsCount2    uint32
+	EightsCount2  uint32
+	_             uint32
+	ObjectCount2  uint32
+	ArrayCount2   uint32
+	_             uint32
+}
+
+func decodeKV3(r io.Reader, version int) (*Document, error) {
+	format, err := readGUID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var h kv3Header
+	if version == 1 {
+		var v1 struct {
+			Compression  uint32
+			BytesCount   uint32
+			IntsCount    uint32
+			EightsCount  uint32
+			Uncompressed uint32
+		}
+		if err = binary.Read(r, binary.LittleEndian, &v1); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		h.Compression = v1.Compression
+		h.BytesCount = v1.BytesCount
+		h.IntsCount = v1.IntsCount
+		h.EightsCount = v1.EightsCount
+		h.Uncompressed = v1.Uncompressed
+	} else {
+		err = binary.Read(r, binary.LittleEndian, &h.kv3HeaderV2)
+		if err == nil && version >= 4 {
+			err = binary.Read(r, binary.LittleEndian, &h.kv3HeaderV4)
+		}
+		if err == nil && version >= 5 {
+			err = binary.Read(r, binary.LittleEndian, &h.kv3HeaderV5)
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+
+	if h.BlockCount == 0 && h.BlockTotalSize != 0 {
+		return nil, fmt.Errorf("kv3: block size without blocks")
+	}
+
+	d := &Document{Header: Header{
+		Format:        formatName(format),
+		FormatVersion: format,
+	}}
+	switch h.Compression {
+	case compressionNone:
+		d.Header.Encoding, d.Header.EncodingVersion = "binary", EncodingBinary
+	case compressionLZ4:
+		d.Header.Encoding, d.Header.EncodingVersion = "binary_lz4", EncodingBinaryLZ4
+	case compressionZstd:
+		d.Header.Encoding = "binary_zstd"
+	default:
+		return nil, fmt.Errorf("kv3: unknown compression method %d", h.Compression)
+	}
+
+	var kr *binaryReader
+	var blobs []byte
+	switch {
+	case version == 1:
+		var buf []byte
+		if h.Compression == compressionLZ4 {
+			// The compressed data continues to the end of the input.
+			if buf, err = ioutil.ReadAll(r); err == nil {
+				buf, err = decompressLZ4(buf, int(h.Uncompressed))
+			}
+		} else {
+			buf, _, err = readBuffer(r, h.Compression, h.Uncompressed, h.Uncompressed, 0)
+		}
+		if err != nil {
+			return nil, err
+		}
+		kr, err = splitBuffers(buf, &h, version)
+	case version < 5:
+		var buf []byte
+		if buf, blobs, err = readBuffer(r, h.Compression, h.Compressed, h.Uncompressed, h.BlockTotalSize); err != nil {
+			return nil, err
+		}
+		kr, err = splitBuffers(buf, &h, version)
+	default:
+		var buf1, buf2 []byte
+		if buf1, _, err = readBuffer(r, h.Compression, h.Compressed1, h.Uncompressed1, 0); err != nil {
+			return nil, err
+		}
+		if buf2, blobs, err = readBuffer(r, h.Compression, h.Compressed2, h.Uncompressed2, h.BlockTotalSize); err != nil {
+			return nil, err
+		}
+		kr, err = splitBuffersV5(buf1, buf2, &h)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if h.BlockCount != 0 {
+		if err = kr.readBlocks(r, &h, blobs); err != nil {
+			return nil, err
+		}
+	}
+	if d.Root, err = kr.value(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// readBuffer reads compressed bytes of data that decompress to size bytes.
+// Zstandard compressed data is followed by blobs bytes of binary blobs in
+// the same stream, which are returned separately.
+func readBuffer(r io.Reader, compression, compressed, size, blobs uint32) ([]byte, []byte, error) {
+	if compression == compressionNone {
+		compressed = size
+	}
+	// The size comes from the header, so the buffer only grows as the data
+	// is actually read.
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(compressed)))
+	if err == nil && len(data) != int(compressed) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch compression {
+	case compressionLZ4:
+		data, err = decompressLZ4(data, int(size))
+	case compressionZstd:
+		if data, err = decompressZstd(data, int(size)+int(blobs)); err == nil {
+			return data[:size], data[size:], nil
+		}
+	}
+	return data, nil, err
+}
+
+// splitter splits decompressed data into separate buffers.
+type splitter struct {
+	b   []byte
+	off int
+}
+
+func (s *splitter) next(n uint64, align int) ([]byte, error) {
+	if align > 1 && s.off%align != 0 {
+		s.off += align - s.off%align
+	}
+	if s.off > len(s.b) || n > uint64(len(s.b)-s.off) {
+		return nil, fmt.Errorf("kv3: buffer sizes exceed data size")
+	}
+	b := s.b[s.off : s.off+int(n)]
+	s.off += int(n)
+	return b, nil
+}
+
+// values splits off the buffers of 1, 2, 4, and 8 byte values, each aligned
+// to its size.
+func (s *splitter) values(bytes, shorts, ints, eights uint32) (valueBuffers, error) {
+	var vb valueBuffers
+	for _, part := range []struct {
+		c     **cursor
+		count uint32
+		size  int
+	}{
+		{&vb.bytes, bytes, 1},
+		{&vb.shorts, shorts, 2},
+		{&vb.ints, ints, 4},
+		{&vb.eights, eights, 8},
+	} {
+		b, err := s.next(uint64(part.count)*uint64(part.size), part.size)
+		if err != nil {
+			return vb, err
+		}
+		*part.c = &cursor{b: b}
+	}
+	return vb, nil
+}
+
+// end splits off the lengths of the blocks, the trailer, and the LZ4 chunk
+// lengths, which must be all that is left of the data.
+func (s *splitter) end(kr *binaryReader, h *kv3Header, version int) error {
+	if h.BlockCount != 0 {
+		b, err := s.next(uint64(h.BlockCount)*4, 1)
+		if err != nil {
+			return err
+		}
+		kr.blockLengths = &cursor{b: b}
+	}
+
+	trailer, err := s.next(4, 1)
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(trailer) != trailerKV3 {
+		return fmt.Errorf("kv3: missing trailer")
+	}
+
+	if h.BlockCount != 0 && h.Compression == compressionLZ4 {
+		// Before version 4, the size of the chunk lengths is not in the
+		// header, and they take up the rest of the data.
+		n := uint64(h.ChunkLengthsSize)
+		if version < 4 {
+			n = uint64(len(s.b) - s.off)
+		}
+		b, err := s.next(n, 1)
+		if err != nil {
+			return err
+		}
+		if len(b)%2 != 0 {
+			return fmt.Errorf("kv3: invalid LZ4 chunk lengths")
+		}
+		kr.chunkLengths = &cursor{b: b}
+	}
+
+	if s.off != len(s.b) {
+		return fmt.Errorf("kv3: %d bytes of unused data", len(s.b)-s.off)
+	}
+	return nil
+}
+
+// splitBuffers finds the separate buffers for each size of value in the
+// decompressed data of versions 1 through 4. The layout is:
+//
+//	bytes (booleans and blob contents)
+//	2-byte values (version 4)
+//	4-byte values, starting with the number of strings
+//	8-byte values
+//	null-terminated strings
+//	type bytes
+//	uncompressed length of each block (if there are blocks)
+//	trailer
+//	compressed length of each LZ4 chunk of the blocks (if there are blocks)
+//
+// Each buffer of values is aligned to the size of its values.
+func splitBuffers(buf []byte, h *kv3Header, version int) (*binaryReader, error) {
+	s := &splitter{b: buf}
+	kr := &binaryReader{size: len(buf), budget: len(buf), maxType: maxTypeV3}
+	if version >= 4 {
+		kr.maxType = maxTypeV4
+	}
+	var err error
+	if kr.valueBuffers, err = s.values(h.BytesCount, h.ShortsCount, h.IntsCount, h.EightsCount); err != nil {
+		return nil, err
+	}
+
+	count, err := kr.ints.int32()
+	if err != nil {
+		return nil, err
+	}
+
+	var rest []byte
+	if version == 1 {
+		if len(buf)-s.off < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		rest, err = s.next(uint64(len(buf)-s.off-4), 1)
+	} else {
+		rest, err = s.next(uint64(h.StringsTypesSize), 1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &cursor{b: rest}
+	if kr.strings, err = c.strings(int(count)); err != nil {
+		return nil, err
+	}
+	kr.types = c
+
+	if err = s.end(kr, h, version); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// splitBuffersV5 finds the separate buffers in the two decompressed buffers
+// of version 5. The first buffer holds the values of auxiliary typed arrays
+// followed by the strings:
+//
+//	bytes
+//	2-byte values
+//	4-byte values, starting with the number of strings
+//	8-byte values
+//	null-terminated strings
+//
+// The second buffer holds the rest of the data:
+//
+//	number of members of each object
+//	bytes
+//	2-byte values
+//	4-byte values
+//	8-byte values
+//	type bytes
+//	uncompressed length of each block (if there are blocks)
+//	trailer
+//	compressed length of each LZ4 chunk of the blocks (if there are blocks)
+func splitBuffersV5(buf1, buf2 []byte, h *kv3Header) (*binaryReader, error) {
+	size := len(buf1) + len(buf2)
+	kr := &binaryReader{size: size, budget: size, maxType: maxTypeV5}
+
+	s := &splitter{b: buf1}
+	aux, err := s.values(h.BytesCount, h.ShortsCount, h.IntsCount, h.EightsCount)
+	if err != nil {
+		return nil, err
+	}
+	kr.aux = &aux
+	count, err := aux.ints.int32()
+	if err != nil {
+		return nil, err
+	}
+	c := &cursor{b: buf1[s.off:]}
+	if kr.strings, err = c.strings(int(count)); err != nil {
+		return nil, err
+	}
+	if c.remaining() != 0 {
+		return nil, fmt.Errorf("kv3: %d bytes of unused data", c.remaining())
+	}
+
+	s = &splitter{b: buf2}
+	b, err := s.next(uint64(h.ObjectCount2)*4, 1)
+	if err != nil {
+		return nil, err
+	}
+	kr.objectLengths = &cursor{b: b}
+	if kr.valueBuffers, err = s.values(h.BytesCount2, h.ShortsCount2, h.IntsCount2, h.EightsCount2); err != nil {
+		return nil, err
+	}
+
+	// The type bytes take up whatever the end of the buffer does not.
+	tail := uint64(h.BlockCount)*4 + 4
+	if h.BlockCount != 0 && h.Compression == compressionLZ4 {
+		tail += uint64(h.ChunkLengthsSize)
+	}
+	if tail > uint64(len(buf2)-s.off) {
+		return nil, fmt.Errorf("kv3: buffer sizes exceed data size")
+	}
+	if b, err = s.next(uint64(len(buf2)-s.off)-tail, 1); err != nil {
+		return nil, err
+	}
+	kr.types = &cursor{b: b}
+
+	if err = s.end(kr, h, 5); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// readBlocks reads the binary blobs that are stored in separate blocks after
+// the rest of the data. Zstandard compressed blocks have already been
+// decompressed to data. LZ4 compressed blocks are split into chunks of at
+// most the frame size, each compressed as a continuation of the previous
+// chunks.
+func (kr *binaryReader) readBlocks(r io.Reader, h *kv3Header, data []byte) error {
+	lengths := make([]int, h.BlockCount)
+	total := 0
+	for i := range lengths {
+		n, err := kr.blockLengths.int32()
+		if err != nil {
+			return err
+		}
+		if n < 0 || int(n) > int(h.BlockTotalSize)-total {
+			return fmt.Errorf("kv3: block sizes exceed total block size")
+		}
+		lengths[i] = int(n)
+		total += int(n)
+	}
+	if total != int(h.BlockTotalSize) {
+		return fmt.Errorf("kv3: block sizes do not match total block size")
+	}
+
+	var err error
+	switch h.Compression {
+	case compressionNone:
+		data, err = ioutil.ReadAll(io.LimitReader(r, int64(total)))
+		if err == nil && len(data) != total {
+			err = io.ErrUnexpectedEOF
+		}
+	case compressionLZ4:
+		data, err = kr.readLZ4Chunks(r, lengths, int(h.FrameSize))
+	}
+	if err != nil {
+		return err
+	}
+
+	kr.blobs = make([][]byte, len(lengths))
+	for i, n := range lengths {
+		kr.blobs[i], data = data[:n:n], data[n:]
+	}
+	return nil
+}
+
+func (kr *binaryReader) readLZ4Chunks(r io.Reader, lengths []int, frameSize int) ([]byte, error) {
+	if frameSize == 0 {
+		return nil, fmt.Errorf("kv3: invalid LZ4 frame size 0")
+	}
+	chunks := kr.chunkLengths
+	var compressed int
+	for c := (&cursor{b: chunks.b}); c.remaining() != 0; {
+		b, _ := c.next(2)
+		compressed += int(binary.LittleEndian.Uint16(b))
+	}
+	src, err := ioutil.ReadAll(io.LimitReader(r, int64(compressed)))
+	if err == nil && len(src) != compressed {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dst []byte
+	for _, n := range lengths {
+		for n != 0 {
+			b, err := chunks.next(2)
+			if err != nil {
+				return nil, err
+			}
+			size := int(binary.LittleEndian.Uint16(b))
+			m := minInt(n, frameSize)
+			if dst, err = appendLZ4(dst, src[:size], m); err != nil {
+				return nil, err
+			}
+			src, n = src[size:], n-m
+		}
+	}
+	if chunks.remaining() != 0 {
+		return nil, fmt.Errorf("kv3: unused LZ4 chunks")
+	}
+	return dst, nil
+}
+
+// cursor is a position in a buffer.
+type cursor struct {
+	b   []byte
+	off int
+}
+
+func (c *cursor) next(n int) ([]byte, error) {
+	if n < 0 || n > len(c.b)-c.off {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := c.b[c.off : c.off+n]
+	c.off += n
+	return b, nil
+}
+
+func (c *cursor) remaining() int {
+	return len(c.b) - c.off
+}
+
+func (c *cursor) byte() (byte, error) {
+	b, err := c.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (c *cursor) int16() (int16, error) {
+	b, err := c.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.LittleEndian.Uint16(b)), nil
+}
+
+func (c *cursor) int32() (int32, error) {
+	b, err := c.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(b)), nil
+}
+
+func (c *cursor) uint64() (uint64, error) {
+	b, err := c.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (c *cursor) strings(count int) ([]string, error) {
+	if count < 0 || count > len(c.b)-c.off {
+		return nil, fmt.Errorf("kv3: invalid string count %d", count)
+	}
+	s := make([]string, count)
+	for i := range s {
+		start := c.off
+		for {
+			b, err := c.byte()
+			if err != nil {
+				return nil, err
+			}
+			if b == 0 {
+				break
+			}
+		}
+		s[i] = string(c.b[start : c.off-1])
+	}
+	return s, nil
+}
+
+// valueBuffers are the buffers that values of each size are read from.
+// Before version 4, there are no 2-byte values.
+type valueBuffers struct {
+	bytes  *cursor
+	shorts *cursor
+	ints   *cursor
+	eights *cursor
+}
+
+// binaryReader reads values from the separate buffers of binary
+// KeyValues3. In the legacy VKV3 format, all of the cursors are the same.
+type binaryReader struct {
+	valueBuffers
+	types   *cursor
+	strings []string
+	size    int
+	depth   int
+	maxType byte
+
+	// In version 5, aux holds the values of auxiliary typed arrays, and
+	// the number of members of each object is read from objectLengths
+	// instead of the 4-byte values.
+	aux           *valueBuffers
+	objectLengths *cursor
+
+	// blockLengths and chunkLengths are the lengths of the blocks that
+	// hold binary blobs and of their LZ4 compressed chunks, and blobs
+	// are the blobs that have not been read yet. Without blocks, blobs
+	// is nil and blob contents are stored with the bytes.
+	blockLengths *cursor
+	chunkLengths *cursor
+	blobs        [][]byte
+
+	// budget is the number of typed array elements that can still be
+	// read, counting each as at least one byte of input.
+	budget int
+}
+
+// maxDepth limits recursion on corrupt input.
+const maxDepth = 1000
+
+// maxElements returns the number of values of type t that fit in what is
+// left of the buffer they are read from. Types that are not read from any
+// buffer, or that do not exist in this version of the format, are limited by
+// the size of the input.
+func (kr *binaryReader) maxElements(t byte) int {
+	if t > kr.maxType {
+		return kr.size
+	}
+	switch t {
+	case typeBool, typeInt32Byte, typeByteTypedArray, typeAuxTypedArray:
+		return kr.bytes.remaining()
+	case typeInt16, typeUint16:
+		return kr.shorts.remaining() / 2
+	case typeObject:
+		if kr.objectLengths != nil {
+			return kr.objectLengths.remaining() / 4
+		}
+		return kr.ints.remaining() / 4
+	case typeBlob:
+		if kr.blobs != nil {
+			return len(kr.blobs)
+		}
+		return kr.ints.remaining() / 4
+	case typeInt64, typeUint64, typeDouble:
+		return kr.eights.remaining() / 8
+	case typeInt32, typeUint32, typeFloat, typeString,
+		typeArray, typeTypedArray:
+		return kr.ints.remaining() / 4
+	}
+	return kr.size
+}
+
+func (kr *binaryReader) readType() (byte, Flag, error) {
+	t, err := kr.types.byte()
+	if err != nil {
+		return 0, 0, err
+	}
+	if t&typeFlagged == 0 {
+		return t, FlagNone, nil
+	}
+	f, err := kr.types.byte()
+	if err != nil {
+		return 0, 0, err
+	}
+	if int(f) >= len(flagNames) {
+		return 0, 0, fmt.Errorf("kv3: invalid flag %d", f)
+	}
+	return t & typeMask, Flag(f), nil
+}
+
+func (kr *binaryReader) string() (string, error) {
+	i, err := kr.ints.int32()
+	if err != nil {
+		return "", err
+	}
+	if i == -1 {
+		return "", nil
+	}
+	if i < 0 || int(i) >= len(kr.strings) {
+		return "", fmt.Errorf("kv3: invalid string index %d", i)
+	}
+	return kr.strings[i], nil
+}
+
+// count reads the length of a blob or container, which is stored in the
+// 4-byte values unless t says otherwise.
+func (kr *binaryReader) count(t byte) (int, error) {
+	c := kr.ints
+	switch {
+	case t == typeByteTypedArray || t == typeAuxTypedArray:
+		n, err := kr.bytes.byte()
+		return int(n), err
+	case t == typeObject && kr.objectLengths != nil:
+		c = kr.objectLengths
+	}
+
+	n, err := c.int32()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("kv3: invalid count %d", n)
+	}
+	return int(n), nil
+}
+
+func (kr *binaryReader) value() (*Value, error) {
+	t, flag, err := kr.readType()
+	if err != nil {
+		return nil, err
+	}
+	return kr.valueOfType(t, flag)
+}
+
+func (kr *binaryReader) valueOfType(t byte, flag Flag) (*Value, error) {
+	if t > kr.maxType {
+		return nil, fmt.Errorf("kv3: unknown binary type %d", t)
+	}
+
+	v := &Value{Flag: flag}
+	switch t {
+	case typeNull:
+		v.Kind = KindNull
+	case typeBool:
+		b, err := kr.bytes.byte()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.Bool = KindBool, b != 0
+	case typeTrue, typeFalse:
+		v.Kind, v.Bool = KindBool, t == typeTrue
+	case typeInt64:
+		i, err := kr.eights.uint64()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.Int = KindInt, int64(i)
+	case typeInt64Zero, typeInt64One:
+		v.Kind, v.Int = KindInt, int64(t-typeInt64Zero)
+	case typeUint64:
+		i, err := kr.eights.uint64()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.Uint = KindUint, i
+	case typeDouble:
+		i, err := kr.eights.uint64()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.Double = KindDouble, math.Float64frombits(i)
+	case typeDoubleZero, typeDoubleOne:
+		v.Kind, v.Double = KindDouble, float64(t-typeDoubleZero)
+	case typeInt32:
+		i, err := kr.ints.int32()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.Int = KindInt, int64(i)
+	case typeUint32:
+		i, err := kr.ints.int32()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.Uint = KindUint, uint64(uint32(i))
+	case typeFloat:
+		i, err := kr.ints.int32()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.Double = KindDouble, float64(math.Float32frombits(uint32(i)))
+	case typeInt16:
+		i, err := kr.shorts.int16()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.Int = KindInt, int64(i)
+	case typeUint16:
+		i, err := kr.shorts.int16()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.Uint = KindUint, uint64(uint16(i))
+	case typeInt32Byte:
+		b, err := kr.bytes.byte()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.Int = KindInt, int64(b)
+	case typeString:
+		s, err := kr.string()
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.String = KindString, s
+	case typeBlob:
+		if kr.blobs != nil {
+			if len(kr.blobs) == 0 {
+				return nil, fmt.Errorf("kv3: more blobs than blocks")
+			}
+			v.Kind, v.Blob = KindBlob, append([]byte{}, kr.blobs[0]...)
+			kr.blobs = kr.blobs[1:]
+			break
+		}
+		n, err := kr.count(t)
+		if err != nil {
+			return nil, err
+		}
+		b, err := kr.bytes.next(n)
+		if err != nil {
+			return nil, err
+		}
+		v.Kind, v.Blob = KindBlob, append([]byte{}, b...)
+	case typeArray, typeTypedArray, typeObject, typeByteTypedArray, typeAuxTypedArray:
+		if kr.depth++; kr.depth > maxDepth {
+			return nil, fmt.Errorf("kv3: nesting deeper than %d", maxDepth)
+		}
+		defer func() { kr.depth-- }()
+		return v, kr.container(v, t)
+	default:
+		return nil, fmt.Errorf("kv3: unknown binary type %d", t)
+	}
+	return v, nil
+}
+
+func (kr *binaryReader) container(v *Value, t byte) error {
+	n, err := kr.count(t)
+	if err != nil {
+		return err
+	}
+	// Every element but those of a typed array takes at least one byte,
+	// so a count larger than the input is corrupt and should not be used to
+	// allocate memory. Typed arrays are checked once the element type is
+	// known.
+	if n > kr.size && (t == typeObject || t == typeArray) {
+		return fmt.Errorf("kv3: invalid count %d", n)
+	}
+
+	switch t {
+	case typeObject:
+		v.Kind, v.Object = KindObject, make([]Member, 0, n)
+		for i := 0; i < n; i++ {
+			name, err := kr.string()
+			if err != nil {
+				return err
+			}
+			e, err := kr.value()
+			if err != nil {
+				return err
+			}
+			v.Object = append(v.Object, Member{Name: name, Value: e})
+		}
+	case typeArray:
+		v.Kind, v.Array = KindArray, make([]*Value, 0, n)
+		for i := 0; i < n; i++ {
+			e, err := kr.value()
+			if err != nil {
+				return err
+			}
+			v.Array = append(v.Array, e)
+		}
+	default:
+		et, flag, err := kr.readType()
+		if err != nil {
+			return err
+		}
+		if t == typeAuxTypedArray {
+			saved := kr.valueBuffers
+			kr.valueBuffers = *kr.aux
+			defer func() { kr.valueBuffers = saved }()
+		}
+		// The elements have no type bytes, and some have no data at
+		// all, so each is counted as at least one byte of the input.
+		if n > kr.maxElements(et) || n > kr.budget {
+			return fmt.Errorf("kv3: invalid count %d", n)
+		}
+		kr.budget -= n
+		v.Kind, v.Array = KindArray, make([]*Value, 0, n)
+		for i := 0; i < n; i++ {
+			e, err := kr.valueOfType(et, flag)
+			if err != nil {
+				return err
+			}
+			v.Array = append(v.Array, e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package kv3_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/BenLubar/vdf/kv3"
+)
+
+func TestBinary(t *testing.T) {
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "out_binary.kv3"))
+	if err != nil {
+		t.Fatal("couldn't read expected output: ", err)
+	}
+
+	for _, name := range []string{
+		"vkv3_binary",
+		"vkv3_lz4",
+		"vkv3_bc",
+		"kv3v1_binary",
+		"kv3v1_lz4",
+		"kv3v2_binary",
+		"kv3v2_lz4",
+		"kv3v3_binary",
+		"kv3v3_lz4",
+		"kv3v3_zstd",
+		"kv3v3_binary_blocks",
+		"kv3v3_lz4_blocks",
+		"kv3v3_zstd_blocks",
+		"kv3v4_binary",
+		"kv3v4_binary_blocks",
+		"kv3v4_lz4",
+		"kv3v4_lz4_blocks",
+		"kv3v4_zstd",
+		"kv3v4_zstd_blocks",
+		"kv3v5_binary",
+		"kv3v5_binary_blocks",
+		"kv3v5_lz4",
+		"kv3v5_lz4_blocks",
+		"kv3v5_zstd",
+		"kv3v5_zstd_blocks",
+	} {
+		name := name // shadow
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			in, err := ioutil.ReadFile(filepath.Join("testdata", name+".kv3b"))
+			if err != nil {
+				t.Fatal("couldn't read input: ", err)
+			}
+
+			d, err := kv3.DecodeBinary(bytes.NewReader(in))
+			if err != nil {
+				t.Fatal("couldn't decode: ", err)
+			}
+
+			out, err := d.MarshalText()
+			if err != nil {
+				t.Fatal("couldn't serialize: ", err)
+			}
+			if !bytes.Equal(expected, out) {
+				t.Error("serialized version differs!")
+				t.Logf("expected: %q", expected)
+				t.Logf("actual:   %q", out)
+			}
+
+			// Every proper prefix of the input is truncated.
+			for i := 0; i < len(in); i++ {
+				if _, err = kv3.DecodeBinary(bytes.NewReader(in[:i])); err == nil {
+					t.Errorf("no error for input truncated to %d bytes", i)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestBinaryUnsupported(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   []byte
+	}{
+		{"version", []byte("\x06\x33\x56\x4b")},
+		{"zstd dictionary", kv3v3(2, 8, 16, []byte("\x28\xb5\x2f\xfd\x01\x00\x05\x00"))},
+	} {
+		_, err := kv3.DecodeBinary(bytes.NewReader(tt.in))
+		if !errors.Is(err, kv3.ErrUnsupported) {
+			t.Errorf("%s: expected ErrUnsupported, got %v", tt.name, err)
+		}
+	}
+}
+
+// kv3v3 builds KV3 version 3 data with a header claiming compressed and
+// uncompressed sizes, followed by data.
+func kv3v3(compression, compressed, uncompressed uint32, data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x03\x33\x56\x4b")
+	buf.Write(make([]byte, 16)) // format GUID
+	for _, v := range []interface{}{
+		compression,
+		uint16(0), uint16(0), // dictionary ID, frame size
+		uint32(0), uint32(0), uint32(0), uint32(0), // buffer sizes
+		uint16(0), uint16(0), // object count, array count
+		uncompressed, compressed,
+		uint32(0), uint32(0), // block count, block total size
+	} {
+		_ = binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// typedArray builds uncompressed KV3 version 3 data whose root is a typed
+// array of count elements of type elem, with ints following the count in
+// the 4-byte buffer.
+func typedArray(elem byte, count int32, ints ...int32) []byte {
+	ints = append([]int32{0, count}, ints...) // no strings
+	var buf bytes.Buffer
+	for _, i := range ints {
+		_ = binary.Write(&buf, binary.LittleEndian, i)
+	}
+	if buf.Len()%8 != 0 {
+		buf.Write(make([]byte, 8-buf.Len()%8))
+	}
+	buf.Write([]byte{10, elem})
+	buf.WriteString("\x00\xdd\xee\xff")
+
+	in := kv3v3(0, 0, uint32(buf.Len()), buf.Bytes())
+	// Fill in the counts of 4-byte values and of strings and types.
+	binary.LittleEndian.PutUint32(in[32:], uint32(len(ints)))
+	binary.LittleEndian.PutUint32(in[40:], 2)
+	return in
+}
+
+func TestBinaryTypedArray(t *testing.T) {
+	t.Parallel()
+
+	d, err := kv3.DecodeBinary(bytes.NewReader(typedArray(13, 3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Root.Kind != kv3.KindArray || len(d.Root.Array) != 3 || !d.Root.Array[2].Bool {
+		t.Errorf("unexpected value: %+v", d.Root)
+	}
+
+	d, err = kv3.DecodeBinary(bytes.NewReader(typedArray(11, 2, 5, -5)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Root.Array) != 2 || d.Root.Array[0].Int != 5 || d.Root.Array[1].Int != -5 {
+		t.Errorf("unexpected value: %+v", d.Root)
+	}
+}
+
+func TestBinaryCorruptSizes(t *testing.T) {
+	// Not parallel, so that the allocations counted are only this test's.
+
+	for _, tt := range []struct {
+		name string
+		in   []byte
+	}{
+		{"uncompressed size", kv3v3(0, 0, 0xffffffff, nil)},
+		{"compressed size", kv3v3(1, 0xffffffff, 16, nil)},
+		{"decompressed size", kv3v3(1, 2, 0x7fffffff, []byte{0xf0, 0xff})},
+		{"zstd decompressed size", kv3v3(2, 11, 0x7fffffff, []byte("\x28\xb5\x2f\xfd\x00\x00\x11\x00\x00\xff\xff"))},
+		{"typed array of null", typedArray(1, 0x7fffffff)},
+		{"typed array of true", typedArray(13, 1000)},
+		{"typed array of int32", typedArray(11, 3, 5, -5)},
+		{"typed array of int64", typedArray(3, 1)},
+	} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := kv3.DecodeBinary(bytes.NewReader(tt.in))
+		runtime.ReadMemStats(&after)
+
+		if err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+		if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+			t.Errorf("%s: allocated %d bytes for %d bytes of input", tt.name, n, len(tt.in))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package kv3_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/BenLubar/vdf/kv3"
+)
+
+func addFuzzSeeds(f *testing.F, pattern string) {
+	files, err := filepath.Glob(filepath.Join("testdata", pattern))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, name := range files {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+}
+
+func FuzzDecodeText(f *testing.F) {
+	addFuzzSeeds(f, "*.kv3")
+	f.Add([]byte("<!-- kv3 -->"))
+	f.Add([]byte("{ a = [ 1, 2.5, \"x\" ] b = #[ 00 ff ] c = resource:\"x\" }"))
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		var d kv3.Document
+		if err := d.UnmarshalText(in); err != nil {
+			return
+		}
+
+		out1, err := d.MarshalText()
+		if err != nil {
+			t.Fatal("couldn't serialize: ", err)
+		}
+		var d2 kv3.Document
+		if err = d2.UnmarshalText(out1); err != nil {
+			t.Fatalf("couldn't parse serialized text %q: %v", out1, err)
+		}
+		out2, err := d2.MarshalText()
+		if err != nil {
+			t.Fatal("couldn't serialize again: ", err)
+		}
+		if !bytes.Equal(out1, out2) {
+			t.Errorf("serialization is not stable:\nfirst:  %q\nsecond: %q", out1, out2)
+		}
+	})
+}
+
+func FuzzDecodeBinary(f *testing.F) {
+	addFuzzSeeds(f, "*.kv3b")
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		d, err := kv3.DecodeBinary(bytes.NewReader(in))
+		if err != nil {
+			return
+		}
+
+		if _, err = d.MarshalText(); err != nil {
+			t.Fatal("couldn't serialize: ", err)
+		}
+	})
+}
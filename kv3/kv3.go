@@ -0,0 +1,38 @@
+// Package kv3 implements KeyValues3, the data format used by Source 2.
+//
+// KeyValues3 is documented on the Valve Developer Community wiki:
+// https://developer.valvesoftware.com/wiki/KeyValues3
+//
+// A KeyValues3 document consists of a header naming its encoding and format,
+// followed by a single value, which is usually an object.
+//
+// Values can be converted to and from vdf.Node using Value.Node and FromNode
+// so that tools written for KeyValues can read Source 2 data.
+package kv3
+
+// GUIDs from the headers of KeyValues3 files written by Valve's tools.
+const (
+	EncodingText      = "e21c7f3c-8a33-41c5-9977-a76d3a32aa0d"
+	EncodingBinary    = "1b860500-f7d8-40c1-ad82-75a48267e714"
+	EncodingBinaryLZ4 = "6847348a-63a1-4f5c-a197-53806fd9b119"
+	FormatGeneric     = "7412167c-06e9-4698-aff2-e63eb59037e7"
+)
+
+// Header is the header of a KeyValues3 document.
+type Header struct {
+	// Encoding is the name of the encoding, such as "text" or
+	// "binary_lz4", and EncodingVersion is its GUID. Binary encodings
+	// without a GUID, such as "binary_zstd", leave EncodingVersion empty.
+	Encoding        string
+	EncodingVersion string
+	// Format is the name of the format of the data, such as "generic", and
+	// FormatVersion is its GUID.
+	Format        string
+	FormatVersion string
+}
+
+// Document is a KeyValues3 document.
+type Document struct {
+	Header Header
+	Root   *Value
+}
@@ -0,0 +1,99 @@
+package kv3
+
+import "errors"
+
+var errLZ4 = errors.New("kv3: corrupt LZ4 data")
+
+// decompressLZ4 decompresses a single LZ4 block (without the LZ4 frame
+// format) that is expected to decompress to exactly size bytes.
+//
+// https://github.com/lz4/lz4/blob/dev/doc/lz4_Block_format.md
+func decompressLZ4(src []byte, size int) ([]byte, error) {
+	// The size is only trusted as far as the input could produce it, so a
+	// corrupt header cannot allocate more than a small multiple of the
+	// input before the data runs out.
+	return appendLZ4(make([]byte, 0, minInt(size, 4*len(src))), src, size)
+}
+
+// appendLZ4 decompresses a single LZ4 block to exactly size bytes appended
+// to dst. Matches may refer back into the existing contents of dst, as
+// they do when consecutive blocks are decoded as a chain.
+func appendLZ4(dst, src []byte, size int) ([]byte, error) {
+	size += len(dst)
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+
+		literals := int(token >> 4)
+		if literals == 15 {
+			for {
+				if i >= len(src) {
+					return nil, errLZ4
+				}
+				b := src[i]
+				i++
+				literals += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		if literals > len(src)-i || literals > size-len(dst) {
+			return nil, errLZ4
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+
+		if i == len(src) {
+			// the last sequence has no match
+			break
+		}
+
+		if i+2 > len(src) {
+			return nil, errLZ4
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return nil, errLZ4
+		}
+
+		length := int(token & 15)
+		if length == 15 {
+			for {
+				if i >= len(src) {
+					return nil, errLZ4
+				}
+				b := src[i]
+				i++
+				length += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		length += 4
+		if length > size-len(dst) {
+			return nil, errLZ4
+		}
+
+		// The match may overlap the bytes it is producing, so it has to be
+		// copied one byte at a time.
+		start := len(dst) - offset
+		for j := 0; j < length; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+
+	if len(dst) != size {
+		return nil, errLZ4
+	}
+	return dst, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
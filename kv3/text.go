@@ -0,0 +1,580 @@
+package kv3
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const headerPrefix = "<!-- kv3 "
+const headerSuffix = " -->"
+
+var escapeString = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\t", "\\t", "\r", "\\r")
+
+// MarshalText encodes d as KeyValues3 text. The encoding in the header is
+// always text. If the format is not set, it is generic.
+func (d *Document) MarshalText() ([]byte, error) {
+	format, formatVersion := d.Header.Format, d.Header.FormatVersion
+	if format == "" {
+		format, formatVersion = "generic", FormatGeneric
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%sencoding:text:version{%s} format:%s:version{%s}%s\n", headerPrefix, EncodingText, format, formatVersion, headerSuffix)
+	if err := d.Root.writeText(&buf, 0, false); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// writeText writes v at the given level of indentation. If member is true,
+// v is the value of an object member, and subtrees start on a new line.
+func (v *Value) writeText(w *bytes.Buffer, indent int, member bool) error {
+	if v == nil {
+		w.WriteString("null")
+		return nil
+	}
+
+	if v.Flag != FlagNone {
+		if int(v.Flag) >= len(flagNames) {
+			return fmt.Errorf("kv3: invalid flag %d", v.Flag)
+		}
+		w.WriteString(v.Flag.String())
+		w.WriteByte(':')
+	}
+
+	switch v.Kind {
+	case KindNull:
+		w.WriteString("null")
+	case KindBool:
+		w.WriteString(strconv.FormatBool(v.Bool))
+	case KindInt:
+		w.WriteString(strconv.FormatInt(v.Int, 10))
+	case KindUint:
+		w.WriteString(strconv.FormatUint(v.Uint, 10))
+	case KindDouble:
+		w.WriteString(formatDouble(v.Double))
+	case KindString:
+		writeString(w, v.String)
+	case KindBlob:
+		w.WriteString("#[")
+		for i, c := range v.Blob {
+			if i != 0 && i%32 == 0 {
+				w.WriteByte('\n')
+				w.WriteString(strings.Repeat("\t", indent+1))
+			} else {
+				w.WriteByte(' ')
+			}
+			fmt.Fprintf(w, "%02X", c)
+		}
+		w.WriteString(" ]")
+	case KindArray:
+		if v.isSimpleArray() {
+			w.WriteByte('[')
+			for i, e := range v.Array {
+				if i != 0 {
+					w.WriteByte(',')
+				}
+				w.WriteByte(' ')
+				if err := e.writeText(w, indent, false); err != nil {
+					return err
+				}
+			}
+			w.WriteString(" ]")
+			return nil
+		}
+		if member {
+			w.WriteString("\n" + strings.Repeat("\t", indent))
+		}
+		w.WriteString("[\n")
+		for _, e := range v.Array {
+			w.WriteString(strings.Repeat("\t", indent+1))
+			if err := e.writeText(w, indent+1, false); err != nil {
+				return err
+			}
+			w.WriteString(",\n")
+		}
+		w.WriteString(strings.Repeat("\t", indent) + "]")
+	case KindObject:
+		if member {
+			w.WriteString("\n" + strings.Repeat("\t", indent))
+		}
+		w.WriteString("{\n")
+		for _, m := range v.Object {
+			w.WriteString(strings.Repeat("\t", indent+1))
+			writeKey(w, m.Name)
+			w.WriteString(" = ")
+			if err := m.Value.writeText(w, indent+1, true); err != nil {
+				return err
+			}
+			w.WriteByte('\n')
+		}
+		w.WriteString(strings.Repeat("\t", indent) + "}")
+	default:
+		return fmt.Errorf("kv3: invalid kind %d", v.Kind)
+	}
+	return nil
+}
+
+// isSimpleArray reports whether v is short and contains only numbers, so
+// that it can be written on a single line.
+func (v *Value) isSimpleArray() bool {
+	if len(v.Array) > 16 {
+		return false
+	}
+	for _, e := range v.Array {
+		if e == nil || e.Flag != FlagNone {
+			return false
+		}
+		switch e.Kind {
+		case KindBool, KindInt, KindUint, KindDouble:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func formatDouble(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func writeKey(w *bytes.Buffer, s string) {
+	if isIdentifier(s) {
+		w.WriteString(s)
+		return
+	}
+	writeString(w, s)
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isIdentByte(s[i]) || s[i] == '-' || s[i] == '+' {
+			return false
+		}
+	}
+	return true
+}
+
+func writeString(w *bytes.Buffer, s string) {
+	if strings.Contains(s, "\n") && !strings.Contains(s, `"""`) && !strings.Contains(s, "\r") {
+		w.WriteString("\"\"\"\n")
+		w.WriteString(s)
+		w.WriteString("\n\"\"\"")
+		return
+	}
+	w.WriteByte('"')
+	w.WriteString(escapeString.Replace(s))
+	w.WriteByte('"')
+}
+
+// SyntaxError is returned when KeyValues3 text cannot be parsed.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("kv3: line %d column %d: %s", err.Line, err.Column, err.Msg)
+}
+
+// UnmarshalText decodes KeyValues3 text into d. The header is optional.
+func (d *Document) UnmarshalText(b []byte) error {
+	*d = Document{}
+
+	p := &textParser{b: b, line: 1}
+	if err := p.skipSpace(); err != nil {
+		return err
+	}
+	if bytes.HasPrefix(p.b[p.off:], []byte(headerPrefix)) {
+		if err := p.header(&d.Header); err != nil {
+			return err
+		}
+	}
+
+	root, err := p.value()
+	if err != nil {
+		return err
+	}
+	d.Root = root
+
+	if err = p.skipSpace(); err != nil {
+		return err
+	}
+	if p.off != len(p.b) {
+		return p.errorf("unexpected %q after end of document", p.b[p.off])
+	}
+	return nil
+}
+
+type textParser struct {
+	b    []byte
+	off  int
+	line int
+	bol  int
+}
+
+func (p *textParser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{
+		Line:   p.line,
+		Column: utf8.RuneCount(p.b[p.bol:p.off]) + 1,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *textParser) advance(n int) {
+	for _, c := range p.b[p.off : p.off+n] {
+		p.off++
+		if c == '\n' {
+			p.line++
+			p.bol = p.off
+		}
+	}
+}
+
+func (p *textParser) peek() (byte, error) {
+	if p.off >= len(p.b) {
+		return 0, p.errorf("unexpected end of input")
+	}
+	return p.b[p.off], nil
+}
+
+func (p *textParser) skipSpace() error {
+	for p.off < len(p.b) {
+		rest := p.b[p.off:]
+		switch {
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r' || rest[0] == '\n':
+			p.advance(1)
+		case bytes.HasPrefix(rest, []byte("//")):
+			if i := bytes.IndexByte(rest, '\n'); i != -1 {
+				p.advance(i + 1)
+			} else {
+				p.advance(len(rest))
+			}
+		case bytes.HasPrefix(rest, []byte("/*")):
+			i := bytes.Index(rest[2:], []byte("*/"))
+			if i == -1 {
+				return p.errorf("unterminated block comment")
+			}
+			p.advance(i + 4)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *textParser) header(h *Header) error {
+	rest := p.b[p.off+len(headerPrefix):]
+	end := bytes.Index(rest, []byte(headerSuffix))
+	if end == -1 {
+		return p.errorf("unterminated header")
+	}
+	fields := strings.Fields(string(rest[:end]))
+	for _, f := range fields {
+		parts := strings.SplitN(f, ":", 3)
+		if len(parts) != 3 || !strings.HasPrefix(parts[2], "version{") || !strings.HasSuffix(parts[2], "}") {
+			return p.errorf("invalid header field %q", f)
+		}
+		version := strings.TrimSuffix(strings.TrimPrefix(parts[2], "version{"), "}")
+		switch parts[0] {
+		case "encoding":
+			h.Encoding, h.EncodingVersion = parts[1], version
+		case "format":
+			h.Format, h.FormatVersion = parts[1], version
+		default:
+			return p.errorf("unknown header field %q", parts[0])
+		}
+	}
+	p.advance(len(headerPrefix) + end + len(headerSuffix))
+	return p.skipSpace()
+}
+
+func (p *textParser) value() (*Value, error) {
+	c, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"':
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Kind: KindString, String: s}, nil
+	case c == '#':
+		return p.blob()
+	case isIdentByte(c):
+		word := p.word()
+		if next, _ := p.peek(); next == ':' {
+			flag, ok := parseFlag(word)
+			if !ok {
+				return nil, p.errorf("unknown flag %q", word)
+			}
+			p.advance(1)
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			if v.Flag != FlagNone {
+				return nil, p.errorf("multiple flags on one value")
+			}
+			v.Flag = flag
+			return v, nil
+		}
+		return p.literal(word)
+	default:
+		return nil, p.errorf("unexpected %q", c)
+	}
+}
+
+func isIdentByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-' || c == '+' || c == '$'
+}
+
+func (p *textParser) word() string {
+	start := p.off
+	for p.off < len(p.b) && isIdentByte(p.b[p.off]) {
+		p.off++
+	}
+	return string(p.b[start:p.off])
+}
+
+func (p *textParser) literal(word string) (*Value, error) {
+	switch word {
+	case "null":
+		return &Value{Kind: KindNull}, nil
+	case "true":
+		return &Value{Kind: KindBool, Bool: true}, nil
+	case "false":
+		return &Value{Kind: KindBool, Bool: false}, nil
+	case "nan":
+		return &Value{Kind: KindDouble, Double: math.NaN()}, nil
+	case "inf":
+		return &Value{Kind: KindDouble, Double: math.Inf(1)}, nil
+	case "-inf":
+		return &Value{Kind: KindDouble, Double: math.Inf(-1)}, nil
+	}
+
+	if !strings.ContainsAny(word, ".eE") {
+		if i, err := strconv.ParseInt(word, 10, 64); err == nil {
+			return &Value{Kind: KindInt, Int: i}, nil
+		}
+		if u, err := strconv.ParseUint(word, 10, 64); err == nil {
+			return &Value{Kind: KindUint, Uint: u}, nil
+		}
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil {
+		return &Value{Kind: KindDouble, Double: f}, nil
+	}
+	return nil, p.errorf("invalid literal %q", word)
+}
+
+func (p *textParser) quoted() (string, error) {
+	if bytes.HasPrefix(p.b[p.off:], []byte(`"""`)) {
+		return p.multiline()
+	}
+
+	p.advance(1)
+	var buf []byte
+	for {
+		c, err := p.peek()
+		if err != nil {
+			return "", err
+		}
+		switch c {
+		case '"':
+			p.advance(1)
+			return string(buf), nil
+		case '\n':
+			return "", p.errorf("newline in string")
+		case '\\':
+			p.advance(1)
+			if c, err = p.peek(); err != nil {
+				return "", err
+			}
+			switch c {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case 'r':
+				c = '\r'
+			case '"', '\\', '\'':
+			default:
+				return "", p.errorf("invalid escape sequence \\%c", c)
+			}
+		}
+		buf = append(buf, c)
+		p.advance(1)
+	}
+}
+
+func (p *textParser) multiline() (string, error) {
+	p.advance(3)
+	rest := p.b[p.off:]
+	switch {
+	case bytes.HasPrefix(rest, []byte("\r\n")):
+		p.advance(2)
+	case bytes.HasPrefix(rest, []byte("\n")):
+		p.advance(1)
+	default:
+		return "", p.errorf("multi-line string must start on a new line")
+	}
+
+	rest = p.b[p.off:]
+	end := bytes.Index(rest, []byte("\n\"\"\""))
+	if end == -1 {
+		return "", p.errorf("unterminated multi-line string")
+	}
+	s := string(rest[:end])
+	p.advance(end + 4)
+	s = strings.TrimSuffix(s, "\r")
+	return strings.Replace(s, "\r\n", "\n", -1), nil
+}
+
+func (p *textParser) blob() (*Value, error) {
+	if !bytes.HasPrefix(p.b[p.off:], []byte("#[")) {
+		return nil, p.errorf("expected #[")
+	}
+	p.advance(2)
+
+	v := &Value{Kind: KindBlob, Blob: []byte{}}
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		c, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == ']' {
+			p.advance(1)
+			return v, nil
+		}
+		if p.off+2 > len(p.b) {
+			return nil, p.errorf("unexpected end of input")
+		}
+		b, err := strconv.ParseUint(string(p.b[p.off:p.off+2]), 16, 8)
+		if err != nil {
+			return nil, p.errorf("invalid byte %q in blob", p.b[p.off:p.off+2])
+		}
+		v.Blob = append(v.Blob, byte(b))
+		p.advance(2)
+	}
+}
+
+func (p *textParser) array() (*Value, error) {
+	p.advance(1)
+
+	v := &Value{Kind: KindArray, Array: []*Value{}}
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		c, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == ']' {
+			p.advance(1)
+			return v, nil
+		}
+
+		e, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		v.Array = append(v.Array, e)
+
+		if err = p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if c, err = p.peek(); err != nil {
+			return nil, err
+		}
+		switch c {
+		case ',':
+			p.advance(1)
+		case ']':
+		default:
+			return nil, p.errorf("expected , or ] in array, found %q", c)
+		}
+	}
+}
+
+func (p *textParser) object() (*Value, error) {
+	p.advance(1)
+
+	v := &Value{Kind: KindObject, Object: []Member{}}
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		c, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == '}' {
+			p.advance(1)
+			return v, nil
+		}
+
+		var name string
+		switch {
+		case c == '"':
+			if name, err = p.quoted(); err != nil {
+				return nil, err
+			}
+		case isIdentByte(c):
+			name = p.word()
+		default:
+			return nil, p.errorf("expected key, found %q", c)
+		}
+
+		if err = p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if c, err = p.peek(); err != nil {
+			return nil, err
+		}
+		if c != '=' {
+			return nil, p.errorf("expected = after key %q, found %q", name, c)
+		}
+		p.advance(1)
+		if err = p.skipSpace(); err != nil {
+			return nil, err
+		}
+
+		e, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		v.Object = append(v.Object, Member{Name: name, Value: e})
+	}
+}
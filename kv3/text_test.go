@@ -0,0 +1,169 @@
+package kv3_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+	"github.com/BenLubar/vdf/kv3"
+)
+
+func TestText(t *testing.T) {
+	for _, name := range []string{
+		"generic",
+	} {
+		name := name // shadow
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			in, err := ioutil.ReadFile(filepath.Join("testdata", "in_"+name+".kv3"))
+			if err != nil {
+				t.Fatal("couldn't read input: ", err)
+			}
+			out, err := ioutil.ReadFile(filepath.Join("testdata", "out_"+name+".kv3"))
+			if err != nil {
+				t.Fatal("couldn't read expected output: ", err)
+			}
+
+			var d kv3.Document
+			if err = d.UnmarshalText(in); err != nil {
+				t.Fatal("couldn't parse: ", err)
+			}
+
+			out1, err := d.MarshalText()
+			if err != nil {
+				t.Fatal("couldn't serialize: ", err)
+			}
+			if !bytes.Equal(out, out1) {
+				t.Error("serialized version differs!")
+				t.Logf("expected: %q", out)
+				t.Logf("actual:   %q", out1)
+			}
+
+			var d2 kv3.Document
+			if err = d2.UnmarshalText(out1); err != nil {
+				t.Fatal("couldn't parse serialized version: ", err)
+			}
+			out2, err := d2.MarshalText()
+			if err != nil {
+				t.Fatal("couldn't serialize again: ", err)
+			}
+			if !bytes.Equal(out1, out2) {
+				t.Error("serialization is not stable")
+			}
+		})
+	}
+}
+
+func TestTextErrors(t *testing.T) {
+	for _, in := range []string{
+		`{ a = }`,
+		`{ a 1 }`,
+		`{ a = [ 1 2 ] }`,
+		`{ a = bogus:"x" }`,
+		`{ a = "unterminated }`,
+		`{ a = #[ 0g ] }`,
+		`{ a = 1 } extra`,
+		`{ /* unterminated`,
+		`<!-- kv3 -->`,
+		`<!-- kv3 encoding:text:version{e21c7f3c-8a33-41c5-9977-a76d3a32aa0d}`,
+	} {
+		var d kv3.Document
+		err := d.UnmarshalText([]byte(in))
+		if _, ok := err.(*kv3.SyntaxError); !ok {
+			t.Errorf("%q: expected *kv3.SyntaxError, got %T: %v", in, err, err)
+		}
+	}
+}
+
+func TestNode(t *testing.T) {
+	var d kv3.Document
+	err := d.UnmarshalText([]byte(`{
+		name = "test"
+		flag = true
+		count = 3
+		big = -5000000000
+		scale = 0.5
+		list = [ 10, 20 ]
+		data = #[ 0a ff ]
+		sub = { x = null }
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := d.Root.Node("root")
+	n.ClearFormatting()
+	out, err := n.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `"root" {
+	"name" "test"
+	"flag" "1"
+	"count" "3"
+	"big" "-5000000000"
+	"scale" "0.5"
+	"list" {
+		"0" "10"
+		"1" "20"
+	}
+	"data" "0A FF"
+	"sub" {
+		"x" ""
+	}
+}
+`
+	if string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	if n.FirstByName("big").Int64() != -5000000000 {
+		t.Errorf("int64 value was not preserved")
+	}
+
+	var src vdf.Node
+	if err = src.UnmarshalText([]byte(`"root" { "a" "1" "b" { "c" "x" } "e" {} }`)); err != nil {
+		t.Fatal(err)
+	}
+	v := kv3.FromNode(&src)
+	if v.Kind != kv3.KindObject || v.Get("a").String != "1" || v.Get("b").Get("c").String != "x" {
+		t.Errorf("unexpected conversion: %+v", v)
+	}
+	if e := v.Get("e"); e == nil || e.Kind != kv3.KindObject || len(e.Object) != 0 {
+		t.Errorf("expected empty object, got %+v", e)
+	}
+
+	typed := new(vdf.Node)
+	typed.SetName("root")
+	for _, c := range []struct {
+		name string
+		set  func(*vdf.Node)
+	}{
+		{"int", func(n *vdf.Node) { n.SetInt(-1) }},
+		{"int64", func(n *vdf.Node) { n.SetInt64(-5000000000) }},
+		{"uint64", func(n *vdf.Node) { n.SetUint64(1 << 63) }},
+		{"float", func(n *vdf.Node) { n.SetFloat(0.5) }},
+		{"empty", func(n *vdf.Node) { n.SetString("") }},
+		{"sub", func(n *vdf.Node) { n.Append(new(vdf.Node)) }},
+	} {
+		n := new(vdf.Node)
+		n.SetName(c.name)
+		c.set(n)
+		typed.Append(n)
+	}
+	opts := &vdf.EncodeOptions{ExtendedTypes: true}
+	before, err := vdf.EncodeBinary(typed, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := vdf.EncodeBinary(kv3.FromNode(typed).Node("root"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("typed values did not round-trip:\nbefore: % x\nafter:  % x", before, after)
+	}
+}
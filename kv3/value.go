@@ -0,0 +1,216 @@
+package kv3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BenLubar/vdf"
+)
+
+// Kind is the type of a Value.
+type Kind uint8
+
+const (
+	KindNull Kind = iota
+	KindBool
+	KindInt
+	KindUint
+	KindDouble
+	KindString
+	KindBlob
+	KindArray
+	KindObject
+)
+
+var kindNames = [...]string{
+	KindNull:   "null",
+	KindBool:   "bool",
+	KindInt:    "int",
+	KindUint:   "uint",
+	KindDouble: "double",
+	KindString: "string",
+	KindBlob:   "blob",
+	KindArray:  "array",
+	KindObject: "object",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Flag is an annotation on a Value, written as a prefix in text, as in
+// resource:"materials/dev/dev_measuregeneric01.vmat".
+type Flag uint8
+
+const (
+	FlagNone Flag = iota
+	FlagResource
+	FlagResourceName
+	FlagPanorama
+	FlagSoundEvent
+	FlagSubClass
+	FlagEntityName
+)
+
+var flagNames = [...]string{
+	FlagNone:         "",
+	FlagResource:     "resource",
+	FlagResourceName: "resource_name",
+	FlagPanorama:     "panorama",
+	FlagSoundEvent:   "soundevent",
+	FlagSubClass:     "subclass",
+	FlagEntityName:   "entity_name",
+}
+
+func (f Flag) String() string {
+	if int(f) < len(flagNames) {
+		return flagNames[f]
+	}
+	return fmt.Sprintf("Flag(%d)", int(f))
+}
+
+func parseFlag(s string) (Flag, bool) {
+	for i, name := range flagNames {
+		if i != int(FlagNone) && name == s {
+			return Flag(i), true
+		}
+	}
+	return FlagNone, false
+}
+
+// Value is a KeyValues3 value. Kind determines which of the other fields is
+// used. The zero Value is null.
+type Value struct {
+	Kind Kind
+	Flag Flag
+
+	Bool   bool
+	Int    int64
+	Uint   uint64
+	Double float64
+	String string
+	Blob   []byte
+	Array  []*Value
+	Object []Member
+}
+
+// Member is a named value in an object.
+type Member struct {
+	Name  string
+	Value *Value
+}
+
+// Get returns the value of the first member of the object v with the given
+// name, or nil if there is no such member or v is not an object. Unlike
+// KeyValues, names in KeyValues3 are case-sensitive.
+func (v *Value) Get(name string) *Value {
+	if v == nil || v.Kind != KindObject {
+		return nil
+	}
+	for _, m := range v.Object {
+		if m.Name == name {
+			return m.Value
+		}
+	}
+	return nil
+}
+
+// Node converts v to a vdf.Node with the given name.
+//
+// Objects become subtrees with one child per member. Arrays become subtrees
+// whose children are named by their index, starting at "0". Null becomes an
+// empty string. Booleans become the integers 0 and 1. Integers become int32
+// if they fit, and int64 or uint64 otherwise. Doubles become float32, which
+// may lose precision. Strings become strings regardless of their flag, and
+// blobs become strings of space-separated hexadecimal bytes, as they would
+// be written in KeyValues3 text.
+func (v *Value) Node(name string) *vdf.Node {
+	n := new(vdf.Node)
+	n.SetName(name)
+	if v == nil {
+		n.SetString("")
+		return n
+	}
+
+	switch v.Kind {
+	case KindNull:
+		n.SetString("")
+	case KindBool:
+		if v.Bool {
+			n.SetInt(1)
+		} else {
+			n.SetInt(0)
+		}
+	case KindInt:
+		if int64(int32(v.Int)) == v.Int {
+			n.SetInt(int32(v.Int))
+		} else {
+			n.SetInt64(v.Int)
+		}
+	case KindUint:
+		if v.Uint <= 1<<31-1 {
+			n.SetInt(int32(v.Uint))
+		} else {
+			n.SetUint64(v.Uint)
+		}
+	case KindDouble:
+		n.SetFloat(float32(v.Double))
+	case KindString:
+		n.SetString(v.String)
+	case KindBlob:
+		n.SetString(formatBlob(v.Blob))
+	case KindArray:
+		for i, e := range v.Array {
+			n.Append(e.Node(strconv.Itoa(i)))
+		}
+	case KindObject:
+		for _, m := range v.Object {
+			n.Append(m.Value.Node(m.Name))
+		}
+	default:
+		panic("invalid kv3.Value")
+	}
+
+	return n
+}
+
+// FromNode converts the value of n to a Value, ignoring its name and
+// condition. Subtrees, including empty ones, become objects. Signed
+// integers become KindInt, pointers and unsigned integers become KindUint,
+// and floats become KindDouble, so Value.Node converts them back to the
+// same type unless a 64-bit integer fits in an int32. All other values,
+// including numbers read from text, become strings.
+func FromNode(n *vdf.Node) *Value {
+	switch n.Kind() {
+	case vdf.KindSubtree:
+	case vdf.KindInt, vdf.KindInt64:
+		return &Value{Kind: KindInt, Int: n.Int64()}
+	case vdf.KindPtr, vdf.KindUint64:
+		return &Value{Kind: KindUint, Uint: n.Uint64()}
+	case vdf.KindFloat:
+		return &Value{Kind: KindDouble, Double: float64(n.Float())}
+	default:
+		return &Value{Kind: KindString, String: n.String()}
+	}
+
+	v := &Value{Kind: KindObject}
+	for c := n.FirstChild(); c != nil; c = c.NextChild() {
+		v.Object = append(v.Object, Member{Name: c.Name(), Value: FromNode(c)})
+	}
+	return v
+}
+
+func formatBlob(b []byte) string {
+	var buf strings.Builder
+	for i, c := range b {
+		if i != 0 {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%02X", c)
+	}
+	return buf.String()
+}
@@ -0,0 +1,921 @@
+package kv3
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+var errZstd = errors.New("kv3: corrupt Zstandard data")
+
+const (
+	zstdMagic         = 0xfd2fb528
+	zstdSkippableMask = 0xfffffff0
+	zstdSkippable     = 0x184d2a50
+	zstdMaxBlockSize  = 128 << 10
+)
+
+// decompressZstd decompresses Zstandard frames that are expected to
+// decompress to exactly size bytes. Dictionaries are not supported.
+//
+// https://www.rfc-editor.org/rfc/rfc8878
+func decompressZstd(src []byte, size int) ([]byte, error) {
+	// As with LZ4, the size is only trusted as far as the input could
+	// produce it.
+	z := &zstdDecoder{dst: make([]byte, 0, minInt(size, 8*len(src))), size: size}
+	for len(src) != 0 {
+		if len(src) < 4 {
+			return nil, errZstd
+		}
+		magic := binary.LittleEndian.Uint32(src)
+		if magic&zstdSkippableMask == zstdSkippable {
+			if len(src) < 8 || uint64(binary.LittleEndian.Uint32(src[4:])) > uint64(len(src)-8) {
+				return nil, errZstd
+			}
+			src = src[8+binary.LittleEndian.Uint32(src[4:]):]
+			continue
+		}
+		if magic != zstdMagic {
+			return nil, errZstd
+		}
+		n, err := z.frame(src[4:])
+		if err != nil {
+			return nil, err
+		}
+		src = src[4+n:]
+	}
+	if len(z.dst) != size {
+		return nil, errZstd
+	}
+	return z.dst, nil
+}
+
+type zstdDecoder struct {
+	dst  []byte
+	size int
+
+	// state that carries over between the blocks of a frame
+	rep      [3]int
+	huffman  *huffmanTable
+	literals []byte
+	llTable  *fseTable
+	ofTable  *fseTable
+	mlTable  *fseTable
+}
+
+// frame decodes a frame after its magic number and returns the number of
+// bytes it used.
+func (z *zstdDecoder) frame(src []byte) (int, error) {
+	if len(src) < 1 {
+		return 0, errZstd
+	}
+	desc := src[0]
+	fcsSize := [4]int{0, 2, 4, 8}[desc>>6]
+	singleSegment := desc&0x20 != 0
+	if desc&0x08 != 0 {
+		return 0, errZstd
+	}
+	checksum := desc&0x04 != 0
+	dictSize := [4]int{0, 1, 2, 4}[desc&3]
+	if singleSegment && fcsSize == 0 {
+		fcsSize = 1
+	}
+
+	off := 1
+	if !singleSegment {
+		off++ // window descriptor
+	}
+	if len(src) < off+dictSize+fcsSize {
+		return 0, errZstd
+	}
+	var dictID uint32
+	for i := 0; i < dictSize; i++ {
+		dictID |= uint32(src[off+i]) << (8 * uint(i))
+	}
+	if dictID != 0 {
+		return 0, fmt.Errorf("%w: Zstandard dictionary %d", ErrUnsupported, dictID)
+	}
+	off += dictSize
+	var contentSize uint64
+	for i := 0; i < fcsSize; i++ {
+		contentSize |= uint64(src[off+i]) << (8 * uint(i))
+	}
+	if fcsSize == 2 {
+		contentSize += 256
+	}
+	off += fcsSize
+
+	start := len(z.dst)
+	z.rep = [3]int{1, 4, 8}
+	z.huffman, z.llTable, z.ofTable, z.mlTable = nil, nil, nil, nil
+
+	for last := false; !last; {
+		if len(src)-off < 3 {
+			return 0, errZstd
+		}
+		header := int(src[off]) | int(src[off+1])<<8 | int(src[off+2])<<16
+		off += 3
+		last = header&1 != 0
+		blockSize := header >> 3
+
+		switch (header >> 1) & 3 {
+		case 0: // raw
+			if blockSize > zstdMaxBlockSize || blockSize > len(src)-off || blockSize > z.size-len(z.dst) {
+				return 0, errZstd
+			}
+			z.dst = append(z.dst, src[off:off+blockSize]...)
+			off += blockSize
+		case 1: // RLE
+			if blockSize > zstdMaxBlockSize || off >= len(src) || blockSize > z.size-len(z.dst) {
+				return 0, errZstd
+			}
+			for i := 0; i < blockSize; i++ {
+				z.dst = append(z.dst, src[off])
+			}
+			off++
+		case 2: // compressed
+			if blockSize > zstdMaxBlockSize || blockSize > len(src)-off {
+				return 0, errZstd
+			}
+			if err := z.block(src[off : off+blockSize]); err != nil {
+				return 0, err
+			}
+			off += blockSize
+		default:
+			return 0, errZstd
+		}
+	}
+
+	if fcsSize != 0 && uint64(len(z.dst)-start) != contentSize {
+		return 0, errZstd
+	}
+	if checksum {
+		if len(src)-off < 4 {
+			return 0, errZstd
+		}
+		if binary.LittleEndian.Uint32(src[off:]) != uint32(xxhash64(z.dst[start:])) {
+			return 0, fmt.Errorf("kv3: Zstandard checksum mismatch")
+		}
+		off += 4
+	}
+	return off, nil
+}
+
+func (z *zstdDecoder) block(src []byte) error {
+	n, err := z.literalsSection(src)
+	if err != nil {
+		return err
+	}
+	return z.sequencesSection(src[n:])
+}
+
+// literalsSection decodes the literals of a compressed block into
+// z.literals and returns the size of the section.
+func (z *zstdDecoder) literalsSection(src []byte) (int, error) {
+	if len(src) < 1 {
+		return 0, errZstd
+	}
+	blockType := src[0] & 3
+	sizeFormat := (src[0] >> 2) & 3
+
+	if blockType < 2 {
+		var regenerated, off int
+		switch sizeFormat {
+		case 0, 2:
+			regenerated, off = int(src[0]>>3), 1
+		case 1:
+			if len(src) < 2 {
+				return 0, errZstd
+			}
+			regenerated, off = int(src[0]>>4)|int(src[1])<<4, 2
+		case 3:
+			if len(src) < 3 {
+				return 0, errZstd
+			}
+			regenerated, off = int(src[0]>>4)|int(src[1])<<4|int(src[2])<<12, 3
+		}
+		if regenerated > zstdMaxBlockSize {
+			return 0, errZstd
+		}
+		if blockType == 0 {
+			if regenerated > len(src)-off {
+				return 0, errZstd
+			}
+			z.literals = src[off : off+regenerated]
+			return off + regenerated, nil
+		}
+		if off >= len(src) {
+			return 0, errZstd
+		}
+		lit := make([]byte, regenerated)
+		for i := range lit {
+			lit[i] = src[off]
+		}
+		z.literals = lit
+		return off + 1, nil
+	}
+
+	var headerSize, sizeBits int
+	streams := 4
+	switch sizeFormat {
+	case 0:
+		headerSize, sizeBits, streams = 3, 10, 1
+	case 1:
+		headerSize, sizeBits = 3, 10
+	case 2:
+		headerSize, sizeBits = 4, 14
+	case 3:
+		headerSize, sizeBits = 5, 18
+	}
+	if len(src) < headerSize {
+		return 0, errZstd
+	}
+	var header uint64
+	for i := 0; i < headerSize; i++ {
+		header |= uint64(src[i]) << (8 * uint(i))
+	}
+	header >>= 4
+	mask := uint64(1)<<uint(sizeBits) - 1
+	regenerated := int(header & mask)
+	compressed := int(header >> uint(sizeBits) & mask)
+	if regenerated > zstdMaxBlockSize || compressed > len(src)-headerSize {
+		return 0, errZstd
+	}
+	data := src[headerSize : headerSize+compressed]
+
+	if blockType == 2 {
+		t, n, err := readHuffmanTable(data)
+		if err != nil {
+			return 0, err
+		}
+		z.huffman = t
+		data = data[n:]
+	} else if z.huffman == nil {
+		return 0, errZstd
+	}
+
+	lit := make([]byte, regenerated)
+	if streams == 1 {
+		if err := z.huffman.decode(lit, data); err != nil {
+			return 0, err
+		}
+	} else {
+		if len(data) < 6 {
+			return 0, errZstd
+		}
+		var sizes [4]int
+		total := 6
+		for i := 0; i < 3; i++ {
+			sizes[i] = int(binary.LittleEndian.Uint16(data[2*i:]))
+			total += sizes[i]
+		}
+		if total > len(data) {
+			return 0, errZstd
+		}
+		sizes[3] = len(data) - total
+		data = data[6:]
+		segment := (regenerated + 3) / 4
+		if 3*segment > regenerated {
+			return 0, errZstd
+		}
+		out := lit
+		for i, n := range sizes {
+			m := segment
+			if i == 3 {
+				m = len(out)
+			}
+			if err := z.huffman.decode(out[:m], data[:n]); err != nil {
+				return 0, err
+			}
+			out, data = out[m:], data[n:]
+		}
+	}
+	z.literals = lit
+	return headerSize + compressed, nil
+}
+
+func (z *zstdDecoder) sequencesSection(src []byte) error {
+	if len(src) < 1 {
+		return errZstd
+	}
+	count, off := int(src[0]), 1
+	switch {
+	case count == 255:
+		if len(src) < 3 {
+			return errZstd
+		}
+		count, off = (int(src[1])|int(src[2])<<8)+0x7f00, 3
+	case count >= 128:
+		if len(src) < 2 {
+			return errZstd
+		}
+		count, off = (count-128)<<8|int(src[1]), 2
+	}
+
+	if count == 0 {
+		if off != len(src) || len(z.literals) > z.size-len(z.dst) {
+			return errZstd
+		}
+		z.dst = append(z.dst, z.literals...)
+		return nil
+	}
+
+	if off >= len(src) {
+		return errZstd
+	}
+	modes := src[off]
+	off++
+	if modes&3 != 0 {
+		return errZstd
+	}
+	var err error
+	var n int
+	if z.llTable, n, err = readSequenceTable(src[off:], modes>>6, z.llTable, &llDefault); err != nil {
+		return err
+	}
+	off += n
+	if z.ofTable, n, err = readSequenceTable(src[off:], modes>>4&3, z.ofTable, &ofDefault); err != nil {
+		return err
+	}
+	off += n
+	if z.mlTable, n, err = readSequenceTable(src[off:], modes>>2&3, z.mlTable, &mlDefault); err != nil {
+		return err
+	}
+	off += n
+
+	br, err := newReverseBitReader(src[off:])
+	if err != nil {
+		return err
+	}
+	ll := br.read(z.llTable.log)
+	of := br.read(z.ofTable.log)
+	ml := br.read(z.mlTable.log)
+
+	lit := z.literals
+	for i := 0; i < count; i++ {
+		ofCode := z.ofTable.entries[of].symbol
+		mlCode := z.mlTable.entries[ml].symbol
+		llCode := z.llTable.entries[ll].symbol
+
+		offset := int(uint64(1)<<ofCode + br.read(uint(ofCode)))
+		matchLength := int(mlBase[mlCode]) + int(br.read(uint(mlBits[mlCode])))
+		literalLength := int(llBase[llCode]) + int(br.read(uint(llBits[llCode])))
+
+		if offset > 3 {
+			offset -= 3
+			z.rep[2], z.rep[1], z.rep[0] = z.rep[1], z.rep[0], offset
+		} else {
+			if literalLength == 0 {
+				offset++
+			}
+			switch offset {
+			case 1:
+				offset = z.rep[0]
+			case 2:
+				offset = z.rep[1]
+				z.rep[1], z.rep[0] = z.rep[0], offset
+			case 3:
+				offset = z.rep[2]
+				z.rep[2], z.rep[1], z.rep[0] = z.rep[1], z.rep[0], offset
+			case 4:
+				offset = z.rep[0] - 1
+				z.rep[2], z.rep[1], z.rep[0] = z.rep[1], z.rep[0], offset
+			}
+		}
+
+		if literalLength > len(lit) || literalLength > z.size-len(z.dst) {
+			return errZstd
+		}
+		z.dst = append(z.dst, lit[:literalLength]...)
+		lit = lit[literalLength:]
+
+		if offset <= 0 || offset > len(z.dst) || matchLength > z.size-len(z.dst) {
+			return errZstd
+		}
+		// The match may overlap the bytes it is producing, so it has to be
+		// copied one byte at a time.
+		start := len(z.dst) - offset
+		for j := 0; j < matchLength; j++ {
+			z.dst = append(z.dst, z.dst[start+j])
+		}
+
+		if i != count-1 {
+			ll = z.llTable.next(ll, br)
+			ml = z.mlTable.next(ml, br)
+			of = z.ofTable.next(of, br)
+		}
+	}
+	if !br.finished() {
+		return errZstd
+	}
+
+	if len(lit) > z.size-len(z.dst) {
+		return errZstd
+	}
+	z.dst = append(z.dst, lit...)
+	return nil
+}
+
+// readSequenceTable reads the decoding table for one of the sequence codes
+// in the given mode and returns the number of bytes it used.
+func readSequenceTable(src []byte, mode byte, prev *fseTable, def *fseDefault) (*fseTable, int, error) {
+	switch mode {
+	case 0: // predefined
+		return def.table, 0, nil
+	case 1: // RLE
+		if len(src) < 1 || int(src[0]) > def.maxSymbol {
+			return nil, 0, errZstd
+		}
+		return &fseTable{entries: []fseEntry{{symbol: src[0]}}}, 1, nil
+	case 2: // FSE compressed
+		return readFSETable(src, def.maxSymbol, def.maxLog)
+	default: // repeat
+		if prev == nil {
+			return nil, 0, errZstd
+		}
+		return prev, 0, nil
+	}
+}
+
+// Baselines and extra bits of the literals length and match length codes.
+var (
+	llBase = [36]uint32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	llBits = [36]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+	mlBase = [53]uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	mlBits = [53]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+)
+
+// fseDefault is the predefined distribution and limits of a sequence code.
+type fseDefault struct {
+	counts    []int16
+	log       uint
+	maxSymbol int
+	maxLog    uint
+
+	table *fseTable
+}
+
+var (
+	llDefault = fseDefault{
+		counts: []int16{
+			4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+			2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+			-1, -1, -1, -1,
+		},
+		log: 6, maxSymbol: 35, maxLog: 9,
+	}
+	mlDefault = fseDefault{
+		counts: []int16{
+			1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+			-1, -1, -1, -1, -1,
+		},
+		log: 6, maxSymbol: 52, maxLog: 9,
+	}
+	ofDefault = fseDefault{
+		counts: []int16{
+			1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+		},
+		log: 5, maxSymbol: 31, maxLog: 8,
+	}
+)
+
+func init() {
+	for _, d := range []*fseDefault{&llDefault, &mlDefault, &ofDefault} {
+		t, err := buildFSETable(d.counts, d.log)
+		if err != nil {
+			panic(err)
+		}
+		d.table = t
+	}
+}
+
+// fseTable is a finite state entropy decoding table.
+type fseTable struct {
+	log     uint
+	entries []fseEntry
+}
+
+type fseEntry struct {
+	symbol   uint8
+	bits     uint8
+	newState uint16
+}
+
+func (t *fseTable) next(state uint64, br *reverseBitReader) uint64 {
+	e := t.entries[state]
+	return uint64(e.newState) + br.read(uint(e.bits))
+}
+
+// readFSETable reads a table description and returns the number of bytes it
+// used.
+func readFSETable(src []byte, maxSymbol int, maxLog uint) (*fseTable, int, error) {
+	br := &forwardBitReader{b: src}
+	log := uint(br.read(4)) + 5
+	if log > maxLog {
+		return nil, 0, errZstd
+	}
+
+	counts := make([]int16, 0, maxSymbol+1)
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	nbBits := log + 1
+	previous0 := false
+	for remaining > 1 && len(counts) <= maxSymbol {
+		if previous0 {
+			n := len(counts)
+			for {
+				r := int(br.read(2))
+				n += r
+				if r != 3 {
+					break
+				}
+			}
+			if n > maxSymbol {
+				return nil, 0, errZstd
+			}
+			for len(counts) < n {
+				counts = append(counts, 0)
+			}
+		}
+
+		max := 2*threshold - 1 - remaining
+		var count int
+		if v := int(br.peek(nbBits - 1)); v < max {
+			count = v
+			br.skip(nbBits - 1)
+		} else {
+			count = int(br.peek(nbBits))
+			if count >= threshold {
+				count -= max
+			}
+			br.skip(nbBits)
+		}
+		count--
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		counts = append(counts, int16(count))
+		previous0 = count == 0
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 || br.overflow() {
+		return nil, 0, errZstd
+	}
+
+	t, err := buildFSETable(counts, log)
+	if err != nil {
+		return nil, 0, err
+	}
+	return t, (br.pos + 7) / 8, nil
+}
+
+func buildFSETable(counts []int16, log uint) (*fseTable, error) {
+	size := 1 << log
+	t := &fseTable{log: log, entries: make([]fseEntry, size)}
+	next := make([]int, len(counts))
+	high := size - 1
+	for s, c := range counts {
+		if c == -1 {
+			if high < 0 {
+				return nil, errZstd
+			}
+			t.entries[high].symbol = uint8(s)
+			high--
+			next[s] = 1
+		} else {
+			next[s] = int(c)
+		}
+	}
+
+	step := size>>1 + size>>3 + 3
+	pos := 0
+	for s, c := range counts {
+		for i := 0; i < int(c); i++ {
+			t.entries[pos].symbol = uint8(s)
+			for {
+				pos = (pos + step) & (size - 1)
+				if pos <= high {
+					break
+				}
+			}
+		}
+	}
+	if pos != 0 {
+		return nil, errZstd
+	}
+
+	for i := range t.entries {
+		e := &t.entries[i]
+		state := next[e.symbol]
+		next[e.symbol]++
+		n := log - uint(bits.Len(uint(state))-1)
+		e.bits = uint8(n)
+		e.newState = uint16(state<<n - size)
+	}
+	return t, nil
+}
+
+// huffmanTable decodes Huffman coded literals by looking up the next
+// maxBits bits.
+type huffmanTable struct {
+	maxBits uint
+	symbols []uint8
+	bits    []uint8
+}
+
+const huffmanMaxBits = 11
+
+// readHuffmanTable reads a Huffman tree description and returns the number
+// of bytes it used.
+func readHuffmanTable(src []byte) (*huffmanTable, int, error) {
+	if len(src) < 1 {
+		return nil, 0, errZstd
+	}
+	var weights []uint8
+	n := int(src[0])
+	used := 1
+	if n >= 128 {
+		n -= 127
+		used += (n + 1) / 2
+		if used > len(src) {
+			return nil, 0, errZstd
+		}
+		weights = make([]uint8, n)
+		for i := range weights {
+			b := src[1+i/2]
+			if i%2 == 0 {
+				weights[i] = b >> 4
+			} else {
+				weights[i] = b & 15
+			}
+		}
+	} else {
+		used += n
+		if used > len(src) {
+			return nil, 0, errZstd
+		}
+		var err error
+		if weights, err = readHuffmanWeights(src[1:used]); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var total int
+	for _, w := range weights {
+		if w > huffmanMaxBits {
+			return nil, 0, errZstd
+		}
+		if w != 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 || len(weights) > 255 {
+		return nil, 0, errZstd
+	}
+	maxBits := uint(bits.Len(uint(total)))
+	rest := 1<<maxBits - total
+	if maxBits > huffmanMaxBits || rest&(rest-1) != 0 {
+		return nil, 0, errZstd
+	}
+	weights = append(weights, uint8(bits.Len(uint(rest))))
+
+	t := &huffmanTable{
+		maxBits: maxBits,
+		symbols: make([]uint8, 1<<maxBits),
+		bits:    make([]uint8, 1<<maxBits),
+	}
+	pos := 0
+	for w := uint8(1); w <= uint8(maxBits); w++ {
+		for s, sw := range weights {
+			if sw != w {
+				continue
+			}
+			for i := 0; i < 1<<(w-1); i++ {
+				t.symbols[pos] = uint8(s)
+				t.bits[pos] = uint8(maxBits) + 1 - w
+				pos++
+			}
+		}
+	}
+	return t, used, nil
+}
+
+// readHuffmanWeights decodes FSE compressed Huffman weights, which use two
+// interleaved states sharing one table.
+func readHuffmanWeights(src []byte) ([]uint8, error) {
+	t, n, err := readFSETable(src, huffmanMaxBits, 6)
+	if err != nil {
+		return nil, err
+	}
+	br, err := newReverseBitReader(src[n:])
+	if err != nil {
+		return nil, err
+	}
+
+	var weights []uint8
+	s1 := br.read(t.log)
+	s2 := br.read(t.log)
+	for {
+		if len(weights) > 255 {
+			return nil, errZstd
+		}
+		weights = append(weights, t.entries[s1].symbol)
+		s1 = t.next(s1, br)
+		if br.overflow() {
+			weights = append(weights, t.entries[s2].symbol)
+			break
+		}
+		weights = append(weights, t.entries[s2].symbol)
+		s2 = t.next(s2, br)
+		if br.overflow() {
+			weights = append(weights, t.entries[s1].symbol)
+			break
+		}
+	}
+	return weights, nil
+}
+
+func (t *huffmanTable) decode(dst, src []byte) error {
+	br, err := newReverseBitReader(src)
+	if err != nil {
+		return err
+	}
+	for i := range dst {
+		v := br.peek(t.maxBits)
+		dst[i] = t.symbols[v]
+		br.pos -= int(t.bits[v])
+	}
+	if !br.finished() {
+		return errZstd
+	}
+	return nil
+}
+
+// forwardBitReader reads bits starting from the least significant bit of
+// the first byte. Reading past the end gives zeros.
+type forwardBitReader struct {
+	b   []byte
+	pos int
+}
+
+func (br *forwardBitReader) peek(n uint) uint64 {
+	var v uint64
+	for i := uint(0); i < n; i++ {
+		p := br.pos + int(i)
+		if p/8 < len(br.b) {
+			v |= uint64(br.b[p/8]>>(uint(p)%8)&1) << i
+		}
+	}
+	return v
+}
+
+func (br *forwardBitReader) skip(n uint) {
+	br.pos += int(n)
+}
+
+func (br *forwardBitReader) read(n uint) uint64 {
+	v := br.peek(n)
+	br.skip(n)
+	return v
+}
+
+func (br *forwardBitReader) overflow() bool {
+	return br.pos > 8*len(br.b)
+}
+
+// reverseBitReader reads bits from the end of a bitstream that is
+// terminated by its highest set bit. Reading past the start gives zeros.
+type reverseBitReader struct {
+	b []byte
+	// pos is the number of bits that have not been read, which goes
+	// negative if the reader overflows.
+	pos int
+}
+
+func newReverseBitReader(b []byte) (*reverseBitReader, error) {
+	if len(b) == 0 || b[len(b)-1] == 0 {
+		return nil, errZstd
+	}
+	return &reverseBitReader{b: b, pos: 8*len(b) - 9 + bits.Len8(b[len(b)-1])}, nil
+}
+
+// peek returns the next n bits, where n is at most 56, without consuming
+// them.
+func (br *reverseBitReader) peek(n uint) uint64 {
+	if n == 0 || br.pos <= 0 {
+		return 0
+	}
+	p := br.pos - int(n)
+	shift := uint(0)
+	width := n
+	if p < 0 {
+		shift, width, p = uint(-p), uint(br.pos), 0
+	}
+	var x uint64
+	for i, j := 0, p/8; i < 8 && j < len(br.b); i, j = i+1, j+1 {
+		x |= uint64(br.b[j]) << (8 * uint(i))
+	}
+	x >>= uint(p % 8)
+	return (x & (1<<width - 1)) << shift
+}
+
+func (br *reverseBitReader) read(n uint) uint64 {
+	v := br.peek(n)
+	br.pos -= int(n)
+	return v
+}
+
+func (br *reverseBitReader) overflow() bool {
+	return br.pos < 0
+}
+
+func (br *reverseBitReader) finished() bool {
+	return br.pos == 0
+}
+
+// xxhash64 is XXH64 with a seed of 0, which Zstandard uses for content
+// checksums.
+//
+// https://github.com/Cyan4973/xxHash/blob/dev/doc/xxhash_spec.md
+func xxhash64(b []byte) uint64 {
+	const (
+		prime1 uint64 = 11400714785074694791
+		prime2 uint64 = 14029467366897019727
+		prime3 uint64 = 1609587929392839161
+		prime4 uint64 = 9650029242287828579
+		prime5 uint64 = 2870177450012600261
+	)
+	round := func(acc, in uint64) uint64 {
+		return bits.RotateLeft64(acc+in*prime2, 31) * prime1
+	}
+	merge := func(acc, v uint64) uint64 {
+		return (acc^round(0, v))*prime1 + prime4
+	}
+
+	n := uint64(len(b))
+	var h uint64
+	if len(b) >= 32 {
+		v1, v2, v3, v4 := prime1, prime2, uint64(0), uint64(0)
+		v1 += prime2
+		v4 -= prime1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = round(v1, binary.LittleEndian.Uint64(b))
+			v2 = round(v2, binary.LittleEndian.Uint64(b[8:]))
+			v3 = round(v3, binary.LittleEndian.Uint64(b[16:]))
+			v4 = round(v4, binary.LittleEndian.Uint64(b[24:]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = merge(merge(merge(merge(h, v1), v2), v3), v4)
+	} else {
+		h = prime5
+	}
+	h += n
+
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= round(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*prime1 + prime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * prime1
+		h = bits.RotateLeft64(h, 23)*prime2 + prime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * prime5
+		h = bits.RotateLeft64(h, 11) * prime1
+	}
+
+	h ^= h >> 33
+	h *= prime2
+	h ^= h >> 29
+	h *= prime3
+	h ^= h >> 32
+	return h
+}
@@ -0,0 +1,119 @@
+// Package lint checks text KeyValues for mistakes that the parser accepts.
+//
+// The linter parses its input with a tolerant parser that records the
+// position of every token, and then runs a set of rules over the result.
+// Each rule reports zero or more diagnostics. Rules are pluggable: anything
+// that implements Rule can be passed to Lint alongside or instead of
+// DefaultRules.
+package lint
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Pos is a position in the input. Line and Column start at 1, and Column is
+// counted in bytes.
+type Pos struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Severity is how serious a diagnostic is.
+type Severity int
+
+const (
+	// SeverityError is used for input that is rejected by the parser or
+	// that will not behave as it appears to.
+	SeverityError Severity = iota
+	// SeverityWarning is used for input that is probably a mistake.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic is a problem found by a rule. Pos is the start of the text it
+// applies to and End is just past the end.
+type Diagnostic struct {
+	Pos      Pos
+	End      Pos
+	Severity Severity
+	Rule     string
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v: %v: %s (%s)", d.Pos, d.Severity, d.Message, d.Rule)
+}
+
+// Rule is a check that can be run by Lint.
+type Rule interface {
+	// Name is a short identifier for the rule, such as "duplicate-key".
+	Name() string
+	// Check returns the problems the rule finds in f. The Rule field of
+	// each diagnostic may be left empty, in which case Lint fills it in.
+	Check(f *File) []Diagnostic
+}
+
+// NewRule returns a Rule with the given name that calls check.
+func NewRule(name string, check func(f *File) []Diagnostic) Rule {
+	return funcRule{name, check}
+}
+
+type funcRule struct {
+	name  string
+	check func(f *File) []Diagnostic
+}
+
+func (r funcRule) Name() string               { return r.name }
+func (r funcRule) Check(f *File) []Diagnostic { return r.check(f) }
+
+// SyntaxRule is the name used for diagnostics about input that could not be
+// parsed.
+const SyntaxRule = "syntax"
+
+// Lint parses src and runs each of rules on it. If src cannot be parsed
+// completely, a diagnostic from SyntaxRule is included and the rules are run
+// on the part that could be parsed. The diagnostics are sorted by position.
+func Lint(src []byte, rules []Rule) []Diagnostic {
+	f, err := Parse(src)
+
+	var diags []Diagnostic
+	if err, ok := err.(*SyntaxError); ok {
+		diags = append(diags, Diagnostic{
+			Pos:      err.Pos,
+			End:      err.Pos,
+			Severity: SeverityError,
+			Rule:     SyntaxRule,
+			Message:  err.Msg,
+		})
+	}
+
+	for _, r := range rules {
+		for _, d := range r.Check(f) {
+			if d.Rule == "" {
+				d.Rule = r.Name()
+			}
+			diags = append(diags, d)
+		}
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Pos.Offset < diags[j].Pos.Offset
+	})
+
+	return diags
+}
@@ -0,0 +1,148 @@
+package lint_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/BenLubar/vdf/lint"
+)
+
+func TestLint(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		in       string
+		expected []string
+	}{
+		{"clean", "#base \"other.res\"\n\"root\"\n{\n\t\"a\" \"1\" [$WIN32]\n\t\"a\" \"2\" [!$WIN32]\n\t\"sub\" { \"x\" \"1\" }\n\t\"sub\" { \"x\" \"2\" }\n\turl http://example.com\n}\n", nil},
+		{"duplicate", "\"root\"\n{\n\t\"a\" \"1\"\n\t\"a\" \"2\"\n}\n", []string{
+			`4:2: warning: duplicate key "a" is shadowed by the one at 3:2 (duplicate-key)`,
+		}},
+		{"case", "\"root\"\n{\n\t\"xpos\" \"1\"\n\t\"XPos\" \"2\"\n}\n", []string{
+			`4:2: warning: key "XPos" differs only in case from "xpos" at 3:2 (key-case)`,
+		}},
+		{"condition", "\"root\"\n{\n\t\"a\" \"1\" [$WIN32&&]\n\t\"b\" \"1\" [($X]\n\t\"c\" \"1\" [!$X360&&($WIN32||$OSX)]\n\t\"d\" \"1\" [$X]]\n}\n", []string{
+			`3:10: error: malformed expression in condition [$WIN32&&] (condition)`,
+			`4:10: error: unbalanced parentheses in condition [($X] (condition)`,
+			`6:10: error: unbalanced brackets in condition [$X]] (condition)`,
+		}},
+		{"unterminated condition", "\"root\" { \"a\" [$X }\n", []string{
+			`1:14: error: unterminated condition [$X (condition)`,
+		}},
+		{"suspicious", "\"root\"\n{\n\tvisible=1 \"x\"\n\t\"a\" value//comment\n\t\"b\" \"1\" x[$X]\n}\n", []string{
+			`3:2: warning: unquoted key "visible=1" contains = (keys and values are separated by spaces) (suspicious-unquoted)`,
+			`4:6: warning: unquoted value "value//comment" contains // (a comment needs a space before it) (suspicious-unquoted)`,
+			`5:10: error: text outside brackets in condition x[$X] (condition)`,
+		}},
+		{"dangling", "\"root\"\n{\n\t\"a\" \"1\"\n\t\"b\"\n}\n\"c\"\n", []string{
+			`4:2: error: key "b" has no value (trailing-text)`,
+			`6:1: error: key "c" has no value (trailing-text)`,
+		}},
+		{"after root", "\"root\" { \"a\" \"1\" }\n\"extra\" \"text\"\n\"more\" \"text\"\n", []string{
+			`2:1: warning: text after the root key "root" is usually ignored (trailing-text)`,
+		}},
+		{"syntax", "\"root\"\n{\n\t\"a\" \"1\"\n\t\"a\" \"2\"\n", []string{
+			`4:2: warning: duplicate key "a" is shadowed by the one at 3:2 (duplicate-key)`,
+			`5:1: error: missing } (syntax)`,
+		}},
+		{"unterminated string", "\"root\" { \"a\" \"1 }\n", []string{
+			`1:14: error: unterminated quoted string (syntax)`,
+		}},
+	} {
+		tt := tt // shadow
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var actual []string
+			for _, d := range lint.Lint([]byte(tt.in), lint.DefaultRules) {
+				actual = append(actual, d.String())
+			}
+			if !reflect.DeepEqual(tt.expected, actual) {
+				t.Errorf("expected %q", tt.expected)
+				t.Errorf("actual   %q", actual)
+			}
+		})
+	}
+}
+
+func TestCustomRule(t *testing.T) {
+	noColors := lint.NewRule("no-colors", func(f *lint.File) []lint.Diagnostic {
+		var diags []lint.Diagnostic
+		f.Walk(func(n *lint.Node) {
+			if n.Key.Text == "fgcolor" {
+				diags = append(diags, lint.Diagnostic{
+					Pos:      n.Key.Pos,
+					End:      n.Key.End,
+					Severity: lint.SeverityWarning,
+					Message:  "use a scheme color",
+				})
+			}
+		})
+		return diags
+	})
+
+	diags := lint.Lint([]byte("\"root\"\n{\n\t\"fgcolor\" \"255 0 0 255\"\n}\n"), []lint.Rule{noColors})
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diags)
+	}
+	d := diags[0]
+	if d.Rule != "no-colors" || d.Pos.Line != 3 || d.Pos.Column != 2 || d.End.Column != 11 {
+		t.Errorf("unexpected diagnostic: %+v", d)
+	}
+}
+
+func TestParse(t *testing.T) {
+	f, err := lint.Parse([]byte("// comment\n\"root\" [$X]\n{\n\tkey \"esc\\\"aped\"\n}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Nodes) != 1 {
+		t.Fatalf("expected 1 root, got %d", len(f.Nodes))
+	}
+	root := f.Nodes[0]
+	if root.Key.Text != "root" || root.Condition.Text != "[$X]" || root.Open.Pos.Line != 3 || root.Close.Pos.Line != 5 {
+		t.Errorf("unexpected root: %+v", root)
+	}
+	if len(root.Children) != 1 {
+		t.Fatalf("expected 1 child, got %d", len(root.Children))
+	}
+	c := root.Children[0]
+	if c.Key.Quoted || c.Value.Text != "esc\"aped" || c.Value.Raw != "\"esc\\\"aped\"" {
+		t.Errorf("unexpected child: %+v %+v", c.Key, c.Value)
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	f, err := lint.Parse([]byte("// header\r\n\"root\" { //after\n\t\"a\" \"//not\" // x\n}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, c := range f.Comments {
+		actual = append(actual, fmt.Sprintf("%s-%s %q %q", c.Pos, c.End, c.Text, c.Raw))
+	}
+	expected := []string{
+		`1:1-1:10 "header" "// header"`,
+		`2:10-2:17 "after" "//after"`,
+		`3:14-3:18 "x" "// x"`,
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %q", expected)
+		t.Errorf("actual   %q", actual)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	f, err := lint.Parse([]byte("\"root\" {\n\t\"a\" \"1\"\n"))
+	serr, ok := err.(*lint.SyntaxError)
+	if !ok {
+		t.Fatalf("expected *lint.SyntaxError, got %T: %v", err, err)
+	}
+	if serr.Pos.Line != 3 || serr.Msg != "missing }" {
+		t.Errorf("unexpected error: %v", serr)
+	}
+	if len(f.Nodes) != 1 || len(f.Nodes[0].Children) != 1 {
+		t.Errorf("expected the parsed part of the input, got %+v", f.Nodes)
+	}
+}
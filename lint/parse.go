@@ -0,0 +1,373 @@
+package lint
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token is a string, brace, or condition in the input.
+type Token struct {
+	// Text is the string the token represents. Escape sequences in quoted
+	// strings are decoded, and conditions include their brackets.
+	Text string
+	// Raw is the token as it appears in the input.
+	Raw    string
+	Quoted bool
+	Pos    Pos
+	End    Pos
+}
+
+// Node is a key and either its value or its children.
+type Node struct {
+	Key Token
+	// Value is nil for a subtree.
+	Value     *Token
+	Condition *Token
+	// Open and Close are the braces around the children of a subtree.
+	// Close is nil if the input ended first.
+	Open     *Token
+	Close    *Token
+	Children []*Node
+}
+
+// File is the parsed form of the input to Lint.
+type File struct {
+	Src []byte
+	// Nodes are the root-level keys.
+	Nodes []*Node
+	// Dangling holds keys that were not followed by a value, either at the
+	// end of the input or before the } closing a subtree.
+	Dangling []Token
+	// Comments holds the // comments in the input, in order. The Text of
+	// each is the comment without the slashes or the space after them, as
+	// returned by vdf.Node.Comments.
+	Comments []Token
+}
+
+// Walk calls fn for each node in f in depth-first order.
+func (f *File) Walk(fn func(n *Node)) {
+	walk(f.Nodes, fn)
+}
+
+func walk(nodes []*Node, fn func(n *Node)) {
+	for _, n := range nodes {
+		fn(n)
+		walk(n.Children, fn)
+	}
+}
+
+// Blocks calls fn with the root-level keys of f and with the children of
+// each subtree in f.
+func (f *File) Blocks(fn func(nodes []*Node)) {
+	fn(f.Nodes)
+	f.Walk(func(n *Node) {
+		if n.Value == nil {
+			fn(n.Children)
+		}
+	})
+}
+
+// SyntaxError is returned by Parse for input that cannot be parsed.
+type SyntaxError struct {
+	Pos Pos
+	Msg string
+}
+
+func (err *SyntaxError) Error() string {
+	return "lint: " + err.Pos.String() + ": " + err.Msg
+}
+
+type tokenKind int
+
+const (
+	tokString tokenKind = iota
+	tokOpen
+	tokClose
+	tokCondition
+)
+
+type token struct {
+	Token
+	kind tokenKind
+}
+
+// Parse parses text KeyValues. Unlike the vdf package, Parse records the
+// position of each token and keeps going after keys that have no value. If
+// there is a syntax error, it is returned as a *SyntaxError, and the returned
+// File holds everything before it.
+func Parse(src []byte) (*File, error) {
+	f := &File{Src: src}
+
+	toks, comments, end, lexErr := lex(src)
+	f.Comments = comments
+	p := &parser{f: f, toks: toks, end: end, lexErr: lexErr}
+	nodes, _, err := p.parseBlock(false)
+	f.Nodes = nodes
+	if err == nil {
+		err = lexErr
+	}
+	if err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+type parser struct {
+	f    *File
+	toks []token
+	i    int
+
+	// end is the position of the end of the input, and lexErr is the
+	// reason the tokens stopped before it, if any.
+	end    Pos
+	lexErr *SyntaxError
+}
+
+func (p *parser) next() *token {
+	if p.i == len(p.toks) {
+		return nil
+	}
+	p.i++
+	return &p.toks[p.i-1]
+}
+
+func (p *parser) peek() *token {
+	if p.i == len(p.toks) {
+		return nil
+	}
+	return &p.toks[p.i]
+}
+
+// errorAt returns a syntax error at t, or at the end of the input if t is
+// nil. If the input could not be split into tokens, running out of tokens
+// is reported as the reason for that instead.
+func (p *parser) errorAt(t *token, msg string) *SyntaxError {
+	if t == nil {
+		if p.lexErr != nil {
+			return p.lexErr
+		}
+		return &SyntaxError{Pos: p.end, Msg: msg}
+	}
+	return &SyntaxError{Pos: t.Pos, Msg: msg}
+}
+
+// parseBlock parses keys until the end of the input or, if nested is true,
+// the closing brace, which it returns.
+func (p *parser) parseBlock(nested bool) ([]*Node, *Token, *SyntaxError) {
+	var nodes []*Node
+	for {
+		t := p.next()
+		if t == nil {
+			if nested || p.lexErr != nil {
+				return nodes, nil, p.errorAt(nil, "missing }")
+			}
+			return nodes, nil, nil
+		}
+
+		switch t.kind {
+		case tokCondition:
+			return nodes, nil, p.errorAt(t, "unexpected condition "+t.Raw)
+		case tokOpen:
+			return nodes, nil, p.errorAt(t, "unexpected {")
+		case tokClose:
+			if !nested {
+				return nodes, nil, p.errorAt(t, "unexpected }")
+			}
+			return nodes, &t.Token, nil
+		}
+
+		n := &Node{Key: t.Token}
+
+		v := p.peek()
+		if v == nil && p.lexErr != nil {
+			return nodes, nil, p.lexErr
+		}
+		if v == nil || v.kind == tokClose {
+			p.f.Dangling = append(p.f.Dangling, n.Key)
+			continue
+		}
+		p.i++
+
+		if v.kind == tokCondition {
+			n.Condition = &v.Token
+			v = p.next()
+			if v == nil || v.kind != tokOpen {
+				nodes = append(nodes, n)
+				return nodes, nil, p.errorAt(v, "missing {")
+			}
+		}
+
+		switch v.kind {
+		case tokOpen:
+			n.Open = &v.Token
+			var err *SyntaxError
+			n.Children, n.Close, err = p.parseBlock(true)
+			nodes = append(nodes, n)
+			if err != nil {
+				return nodes, nil, err
+			}
+		case tokString:
+			n.Value = &v.Token
+			if c := p.peek(); c != nil && c.kind == tokCondition {
+				n.Condition = &c.Token
+				p.i++
+			}
+			nodes = append(nodes, n)
+		default:
+			return nodes, nil, p.errorAt(v, "unexpected "+v.Raw)
+		}
+	}
+}
+
+// lex splits src into tokens the same way as the vdf package. It also
+// returns the comments, which are not tokens to the parser, and the position
+// of the end of src.
+func lex(src []byte) ([]token, []Token, Pos, *SyntaxError) {
+	l := &lexer{src: src, line: 1, col: 1}
+	var toks []token
+	for {
+		l.skipSpace()
+		if l.off == len(src) {
+			return toks, l.comments, l.pos(), nil
+		}
+
+		start := l.pos()
+		c := src[l.off]
+		var t token
+		switch {
+		case c == '"':
+			l.advance()
+			text, ok := l.quoted()
+			if !ok {
+				return toks, l.comments, l.pos(), &SyntaxError{Pos: start, Msg: "unterminated quoted string"}
+			}
+			t.Text = text
+			t.Quoted = true
+		case c == '{':
+			l.advance()
+			t.kind = tokOpen
+		case c == '}':
+			l.advance()
+			t.kind = tokClose
+		default:
+			if l.unquoted() {
+				t.kind = tokCondition
+			}
+		}
+
+		t.Pos = start
+		t.End = l.pos()
+		t.Raw = string(src[start.Offset:l.off])
+		if !t.Quoted {
+			t.Text = t.Raw
+		}
+		toks = append(toks, t)
+	}
+}
+
+type lexer struct {
+	src      []byte
+	off      int
+	line     int
+	col      int
+	comments []Token
+}
+
+func (l *lexer) pos() Pos {
+	return Pos{Offset: l.off, Line: l.line, Column: l.col}
+}
+
+func (l *lexer) advance() {
+	if l.src[l.off] == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	l.off++
+}
+
+func (l *lexer) skipSpace() {
+	for l.off < len(l.src) {
+		if unicode.IsSpace(rune(l.src[l.off])) {
+			l.advance()
+			continue
+		}
+		if l.off+1 < len(l.src) && l.src[l.off] == '/' && l.src[l.off+1] == '/' {
+			l.comment()
+			continue
+		}
+		return
+	}
+}
+
+// comment reads a // comment up to the end of the line.
+func (l *lexer) comment() {
+	start := l.pos()
+	for l.off < len(l.src) && l.src[l.off] != '\n' {
+		l.advance()
+	}
+	raw := strings.TrimSuffix(string(l.src[start.Offset:l.off]), "\r")
+	l.comments = append(l.comments, Token{
+		Text: strings.TrimPrefix(raw[2:], " "),
+		Raw:  raw,
+		Pos:  start,
+		End:  Pos{Offset: start.Offset + len(raw), Line: start.Line, Column: start.Column + len(raw)},
+	})
+}
+
+// quoted reads the rest of a quoted string, whose opening quote has already
+// been read.
+func (l *lexer) quoted() (string, bool) {
+	var buf strings.Builder
+	for l.off < len(l.src) {
+		c := l.src[l.off]
+		l.advance()
+		switch {
+		case c == '"':
+			return buf.String(), true
+		case c == '\\' && l.off < len(l.src):
+			if e, ok := escapes[l.src[l.off]]; ok {
+				l.advance()
+				buf.WriteByte(e)
+				continue
+			}
+		}
+		buf.WriteByte(c)
+	}
+	return "", false
+}
+
+var escapes = map[byte]byte{
+	'\\': '\\',
+	'n':  '\n',
+	't':  '\t',
+	'v':  '\v',
+	'b':  '\b',
+	'r':  '\r',
+	'f':  '\f',
+	'a':  '\a',
+	'\'': '\'',
+	'"':  '"',
+}
+
+// unquoted reads an unquoted string and reports whether the vdf package
+// would treat it as a condition.
+func (l *lexer) unquoted() bool {
+	conditionalStart := false
+	wasConditional := false
+	for l.off < len(l.src) {
+		c := l.src[l.off]
+		if c == '"' || c == '{' || c == '}' || unicode.IsSpace(rune(c)) {
+			break
+		}
+		if c == '[' {
+			conditionalStart = true
+		}
+		if c == ']' && conditionalStart {
+			wasConditional = true
+		}
+		l.advance()
+	}
+	return wasConditional
+}
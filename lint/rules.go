@@ -0,0 +1,247 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The rules included in this package.
+var (
+	// DuplicateKeys reports values whose key and condition are the same
+	// as an earlier value in the same subtree. FirstByName and the Source
+	// engine only ever find the first one. Repeated subtrees are allowed,
+	// as some formats use them as lists.
+	DuplicateKeys = NewRule("duplicate-key", checkDuplicateKeys)
+
+	// KeyCase reports keys in the same subtree that differ only in case.
+	// FirstByName, like the Source engine, treats them as the same key.
+	KeyCase = NewRule("key-case", checkKeyCase)
+
+	// Conditions reports conditions with unbalanced brackets or
+	// parentheses, or that are otherwise malformed.
+	Conditions = NewRule("condition", checkConditions)
+
+	// SuspiciousUnquoted reports unquoted keys and values containing
+	// characters that suggest a mistake, such as a missing space before a
+	// comment or a condition, or a key and value joined by =.
+	SuspiciousUnquoted = NewRule("suspicious-unquoted", checkSuspiciousUnquoted)
+
+	// TrailingText reports keys with no value and anything after the first
+	// root subtree, which most programs ignore.
+	TrailingText = NewRule("trailing-text", checkTrailingText)
+)
+
+// DefaultRules are the rules used by the vdf lint command.
+var DefaultRules = []Rule{
+	DuplicateKeys,
+	KeyCase,
+	Conditions,
+	SuspiciousUnquoted,
+	TrailingText,
+}
+
+func tokenDiagnostic(t *Token, severity Severity, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{
+		Pos:      t.Pos,
+		End:      t.End,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+func condition(n *Node) string {
+	if n.Condition == nil {
+		return ""
+	}
+	return n.Condition.Text
+}
+
+func checkDuplicateKeys(f *File) []Diagnostic {
+	var diags []Diagnostic
+	f.Blocks(func(nodes []*Node) {
+		type key struct{ name, condition string }
+		seen := make(map[key]*Node)
+		for _, n := range nodes {
+			if n.Value == nil {
+				continue
+			}
+			k := key{n.Key.Text, condition(n)}
+			if first, ok := seen[k]; ok {
+				diags = append(diags, tokenDiagnostic(&n.Key, SeverityWarning, "duplicate key %q is shadowed by the one at %v", n.Key.Text, first.Key.Pos))
+				continue
+			}
+			seen[k] = n
+		}
+	})
+	return diags
+}
+
+func checkKeyCase(f *File) []Diagnostic {
+	var diags []Diagnostic
+	f.Blocks(func(nodes []*Node) {
+		seen := make(map[string]*Node)
+		for _, n := range nodes {
+			folded := strings.ToLower(n.Key.Text)
+			if first, ok := seen[folded]; ok {
+				if first.Key.Text != n.Key.Text {
+					diags = append(diags, tokenDiagnostic(&n.Key, SeverityWarning, "key %q differs only in case from %q at %v", n.Key.Text, first.Key.Text, first.Key.Pos))
+				}
+				continue
+			}
+			seen[folded] = n
+		}
+	})
+	return diags
+}
+
+func checkConditions(f *File) []Diagnostic {
+	var diags []Diagnostic
+	check := func(t *Token) {
+		if msg := checkCondition(t.Text); msg != "" {
+			diags = append(diags, tokenDiagnostic(t, SeverityError, "%s in condition %s", msg, t.Text))
+		}
+	}
+	f.Walk(func(n *Node) {
+		if n.Condition != nil {
+			check(n.Condition)
+		}
+		// An unquoted string that starts with [ but has no ] is a
+		// condition that was never closed, which the parser reads as
+		// a key or value instead.
+		for _, t := range []*Token{&n.Key, n.Value} {
+			if t != nil && !t.Quoted && strings.HasPrefix(t.Text, "[") {
+				diags = append(diags, tokenDiagnostic(t, SeverityError, "unterminated condition %s", t.Text))
+			}
+		}
+	})
+	return diags
+}
+
+// checkCondition returns a description of the problem with a condition, or
+// an empty string if there is none. Conditions are one or more terms joined
+// by && or ||, where a term is a variable such as $WIN32 or a parenthesized
+// condition, optionally preceded by !.
+func checkCondition(cond string) string {
+	if !strings.HasPrefix(cond, "[") || !strings.HasSuffix(cond, "]") {
+		return "text outside brackets"
+	}
+	s := cond[1 : len(cond)-1]
+	if strings.ContainsAny(s, "[]") {
+		return "unbalanced brackets"
+	}
+
+	depth := 0
+	for _, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return "unbalanced parentheses"
+			}
+		}
+	}
+	if depth != 0 {
+		return "unbalanced parentheses"
+	}
+
+	c := &condParser{s: s}
+	if !c.expr() || c.i != len(c.s) {
+		return "malformed expression"
+	}
+	return ""
+}
+
+type condParser struct {
+	s string
+	i int
+}
+
+func (c *condParser) expr() bool {
+	if !c.term() {
+		return false
+	}
+	for strings.HasPrefix(c.s[c.i:], "&&") || strings.HasPrefix(c.s[c.i:], "||") {
+		c.i += 2
+		if !c.term() {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *condParser) term() bool {
+	for c.i < len(c.s) && c.s[c.i] == '!' {
+		c.i++
+	}
+	if c.i < len(c.s) && c.s[c.i] == '(' {
+		c.i++
+		if !c.expr() || c.i == len(c.s) || c.s[c.i] != ')' {
+			return false
+		}
+		c.i++
+		return true
+	}
+	if c.i < len(c.s) && c.s[c.i] == '$' {
+		c.i++
+	}
+	start := c.i
+	for c.i < len(c.s) && isIdent(c.s[c.i]) {
+		c.i++
+	}
+	return c.i != start
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+func checkSuspiciousUnquoted(f *File) []Diagnostic {
+	var diags []Diagnostic
+	check := func(t *Token, what string) {
+		if t == nil || t.Quoted || strings.HasPrefix(t.Text, "[") {
+			return
+		}
+		var reason string
+		switch {
+		case strings.Contains(strings.Replace(t.Text, "://", "", -1), "//"):
+			reason = "contains // (a comment needs a space before it)"
+		case strings.ContainsAny(t.Text, "[]"):
+			reason = "contains a bracket (a condition needs a space before it)"
+		case strings.Contains(t.Text, "="):
+			reason = "contains = (keys and values are separated by spaces)"
+		default:
+			return
+		}
+		diags = append(diags, tokenDiagnostic(t, SeverityWarning, "unquoted %s %q %s", what, t.Text, reason))
+	}
+	f.Walk(func(n *Node) {
+		check(&n.Key, "key")
+		check(n.Value, "value")
+	})
+	return diags
+}
+
+func checkTrailingText(f *File) []Diagnostic {
+	var diags []Diagnostic
+	for i := range f.Dangling {
+		diags = append(diags, tokenDiagnostic(&f.Dangling[i], SeverityError, "key %q has no value", f.Dangling[i].Text))
+	}
+
+	// Keys like #base and #include come before the root subtree and are
+	// handled by the Source engine, so only report what comes after it.
+	for i, n := range f.Nodes {
+		if n.Value != nil {
+			continue
+		}
+		for _, t := range f.Nodes[i+1:] {
+			if !strings.HasPrefix(t.Key.Text, "#") {
+				diags = append(diags, tokenDiagnostic(&t.Key, SeverityWarning, "text after the root key %q is usually ignored", n.Key.Text))
+				break
+			}
+		}
+		break
+	}
+	return diags
+}
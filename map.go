@@ -0,0 +1,142 @@
+package vdf
+
+import (
+	"fmt"
+	"image/color"
+	"reflect"
+	"sort"
+)
+
+// Keys returns the names of the children of this Node, in order. A name that
+// appears more than once, ignoring case, is only returned the first time,
+// spelled as it was that time, so the result has the same names as the maps
+// returned by StringMap, IntMap, and AsMap.
+//
+// Keys is an accessor.
+func (n *Node) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for c := n.FirstChild(); c != nil; c = c.NextChild() {
+		key := foldKey(c.name)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, c.name)
+		}
+	}
+	return keys
+}
+
+// StringMap returns the children of this Node that are not subtrees, as a
+// map from their names to their values. If more than one child has the same
+// name, ignoring case, only the first is included, as FirstByName would
+// find, using the spelling of its name.
+//
+// StringMap is an accessor.
+func (n *Node) StringMap() map[string]string {
+	m := make(map[string]string)
+	seen := make(map[string]bool)
+	for c := n.FirstValue(); c != nil; c = c.NextValue() {
+		if key := foldKey(c.name); !seen[key] {
+			seen[key] = true
+			m[c.name] = c.String()
+		}
+	}
+	return m
+}
+
+// IntMap is like StringMap, but converts each value as Int does.
+//
+// IntMap is an accessor.
+func (n *Node) IntMap() map[string]int32 {
+	m := make(map[string]int32)
+	seen := make(map[string]bool)
+	for c := n.FirstValue(); c != nil; c = c.NextValue() {
+		if key := foldKey(c.name); !seen[key] {
+			seen[key] = true
+			m[c.name] = c.Int()
+		}
+	}
+	return m
+}
+
+// AsMap calls convert on the children of n, including subtrees, and returns
+// a map from their names to the results. Duplicate names are handled as they
+// are by StringMap, and convert is not called for the later children with a
+// name. If convert returns an error, AsMap stops and returns it.
+func AsMap[T any](n *Node, convert func(*Node) (T, error)) (map[string]T, error) {
+	m := make(map[string]T)
+	seen := make(map[string]bool)
+	for c := n.FirstChild(); c != nil; c = c.NextChild() {
+		key := foldKey(c.name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		v, err := convert(c)
+		if err != nil {
+			return nil, err
+		}
+		m[c.name] = v
+	}
+	return m, nil
+}
+
+// FromMap returns a new subtree named name with one child for each element
+// of m, in order of their names. The map must have string keys. Its values
+// can be any type with a Node setter, such as string, int32, or
+// color.NRGBA, or another map, which is stored as a subtree. Any other type
+// will panic.
+//
+// Keys that are different but equal ignoring case, such as "a" and "A", are
+// stored as separate children, so FirstByName will only find one of them.
+func FromMap(name string, m interface{}) *Node {
+	n := &Node{name: name}
+	n.setMap(m)
+	return n
+}
+
+func (n *Node) setMap(m interface{}) {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		panic(fmt.Sprintf("vdf: cannot use %T as a map", m))
+	}
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	n.value = nil
+	for _, k := range keys {
+		c := &Node{name: k.String()}
+		c.setMapValue(v.MapIndex(k).Interface())
+		n.Append(c)
+	}
+}
+
+func (n *Node) setMapValue(value interface{}) {
+	switch v := value.(type) {
+	case string:
+		n.SetString(v)
+	case int32:
+		n.SetInt(v)
+	case float32:
+		n.SetFloat(v)
+	case uint32:
+		n.SetPtr(v)
+	case []uint16:
+		n.SetWString(v)
+	case color.NRGBA:
+		n.SetColor(v)
+	case uint64:
+		n.SetUint64(v)
+	case int64:
+		n.SetInt64(v)
+	default:
+		if reflect.ValueOf(value).Kind() != reflect.Map {
+			panic(fmt.Sprintf("vdf: cannot use %T as a value", value))
+		}
+		n.setMap(value)
+	}
+}
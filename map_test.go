@@ -0,0 +1,100 @@
+package vdf_test
+
+import (
+	"errors"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+func TestMaps(t *testing.T) {
+	t.Parallel()
+
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte(`"apps" { "440" "1" "570" "x" "Sub" { "a" "b" } "SUB" "2" "sub" "3" "440" "4" }`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, expected := n.Keys(), []string{"440", "570", "Sub"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Keys: expected %q, got %q", expected, keys)
+	}
+	if m, expected := n.StringMap(), map[string]string{"440": "1", "570": "x", "SUB": "2"}; !reflect.DeepEqual(m, expected) {
+		t.Errorf("StringMap: expected %q, got %q", expected, m)
+	}
+	if m, expected := n.IntMap(), map[string]int32{"440": 1, "570": 0, "SUB": 2}; !reflect.DeepEqual(m, expected) {
+		t.Errorf("IntMap: expected %v, got %v", expected, m)
+	}
+
+	m, err := vdf.AsMap(&n, func(c *vdf.Node) (vdf.Kind, error) {
+		return c.Kind(), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]vdf.Kind{"440": vdf.KindString, "570": vdf.KindString, "Sub": vdf.KindSubtree}; !reflect.DeepEqual(m, expected) {
+		t.Errorf("AsMap: expected %v, got %v", expected, m)
+	}
+
+	errTest := errors.New("test")
+	if _, err = vdf.AsMap(&n, func(c *vdf.Node) (int32, error) {
+		return 0, errTest
+	}); err != errTest {
+		t.Errorf("AsMap: expected %v, got %v", errTest, err)
+	}
+
+	var nilNode *vdf.Node
+	if nilNode.Keys() != nil || len(nilNode.StringMap()) != 0 || len(nilNode.IntMap()) != 0 {
+		t.Error("unexpected result for nil Node")
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	t.Parallel()
+
+	n := vdf.FromMap("root", map[string]interface{}{
+		"str":   "x",
+		"int":   int32(5),
+		"color": color.NRGBA{1, 2, 3, 4},
+		"sub":   map[string]string{"b": "2", "a": "1"},
+		"empty": map[string]int32{},
+		"A":     "upper",
+	})
+	out, err := n.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "\"root\" {\n" +
+		"\t\"A\" \"upper\"\n" +
+		"\t\"color\" \"1 2 3 4\"\n" +
+		"\t\"empty\" {\n\t}\n" +
+		"\t\"int\" \"5\"\n" +
+		"\t\"str\" \"x\"\n" +
+		"\t\"sub\" {\n\t\t\"a\" \"1\"\n\t\t\"b\" \"2\"\n\t}\n" +
+		"}\n"
+	if string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	if n.FirstByName("int").Kind() != vdf.KindInt {
+		t.Errorf("expected int, got %v", n.FirstByName("int").Kind())
+	}
+	if m := n.FirstByName("sub").StringMap(); !reflect.DeepEqual(m, map[string]string{"a": "1", "b": "2"}) {
+		t.Errorf("round trip failed: %q", m)
+	}
+
+	for _, m := range []interface{}{
+		map[int]string{1: "x"},
+		map[string]int{"x": 1},
+		"not a map",
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%T: expected panic", m)
+				}
+			}()
+			vdf.FromMap("root", m)
+		}()
+	}
+}
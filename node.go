@@ -37,8 +37,12 @@ type Node struct {
 	// - []uint16
 	// - color.NRGBA
 	// - uint64
-	value interface{}
-	cf    *customFormat
+	// - int64
+	value   interface{}
+	cf      *customFormat
+	comment *comment
+	sym     *symbol
+	index   *childIndex
 }
 
 var blankNode Node
@@ -60,7 +64,13 @@ func (n *Node) Name() string { return n.notNil().name }
 //
 // SetName is a mutator.
 func (n *Node) SetName(name string) {
+	if n.parent != nil && n.parent.index != nil {
+		if seq, ok := n.parent.index.remove(n); ok {
+			defer n.parent.index.insert(n, seq)
+		}
+	}
 	n.name = name
+	n.sym = nil
 	if n.cf != nil && n.cf.unquotedKey && (strings.IndexFunc(name, unicode.IsSpace) != -1 || strings.ContainsAny(name, "\"{}")) {
 		n.cf.unquotedKey = false
 	}
@@ -90,7 +100,8 @@ func (n *Node) SetCondition(condition string) {
 }
 
 // ClearFormatting resets the Node and its children to use standard formatting
-// in MarshalText. The formatting is only set by UnmarshalText.
+// in MarshalText. The formatting is only set by UnmarshalText. Comments
+// returned by Comments are kept.
 //
 // ClearFormatting is a mutator.
 func (n *Node) ClearFormatting() {
@@ -112,4 +123,5 @@ type customFormat struct {
 	after         string
 	unquotedKey   bool
 	unquotedValue bool
+	colorStyle    colorStyle
 }
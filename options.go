@@ -0,0 +1,356 @@
+package vdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DecodeOptions controls the behavior of DecodeText and DecodeBinary.
+//
+// The zero value of DecodeOptions is the behavior of UnmarshalText and
+// UnmarshalBinary. A nil *DecodeOptions is equivalent to the zero value.
+type DecodeOptions struct {
+	// MaxDepth is the maximum number of nested subtrees. Zero means no
+	// limit.
+	MaxDepth int
+
+	// MaxNodes is the maximum number of nodes, including subtrees, in the
+	// decoded document. Zero means no limit.
+	MaxNodes int
+
+	// MaxStringLength is the maximum length in bytes of a single key or
+	// value, or in code units for a wide string. Zero means no limit.
+	MaxStringLength int
+
+	// MaxBytes is the maximum number of bytes that will be read from the
+	// input. Zero means no limit.
+	MaxBytes int64
+
+	// DisableEscapes turns off processing of backslash escape sequences in
+	// quoted strings, matching KeyValues with UsesEscapeSequences(false).
+	// Text only.
+	DisableEscapes bool
+
+	// EvaluateConditions, if non-nil, is called with the condition of each
+	// node that has one, without the surrounding brackets. Nodes for which
+	// it returns false are discarded, and nodes for which it returns true
+	// have their condition removed. If EvaluateConditions is nil,
+	// conditions are kept on the decoded nodes. Text only.
+	EvaluateConditions func(condition string) bool
+
+	// DuplicateKeys controls what happens when a subtree contains more than
+	// one key with the same name, compared case-insensitively.
+	DuplicateKeys DuplicateKeyPolicy
+
+	// DiscardComments removes comments from the formatting recorded by the
+	// text decoder. Text only.
+	DiscardComments bool
+
+	// BlockComments allows /* */ comments, which may span multiple lines,
+	// anywhere that a // comment or whitespace is allowed. An unquoted
+	// string ends at the start of a block comment. Text only.
+	BlockComments bool
+
+	// TypeAnnotations reads the types recorded by
+	// EncodeOptions.TypeAnnotations, so that values have the Kind they had
+	// when they were written. Text only.
+	TypeAnnotations bool
+
+	// InferTypes, if non-nil, is used to call InferTypes on the decoded
+	// nodes. Text only.
+	InferTypes *InferOptions
+
+	// HashComments allows comments starting with # and running to the end
+	// of the line. A # followed by a letter, as in #base and #include,
+	// still starts a key. Text only.
+	HashComments bool
+
+	// Symbols, if non-nil, is used to intern the names of the decoded
+	// nodes. See SymbolTable.
+	Symbols *SymbolTable
+
+	// Dialect selects the binary format. Binary only.
+	Dialect BinaryDialect
+
+	// ExtendedTypes enables the binary types used by Steam that are not
+	// part of Source SDK 2013: type 10 is a signed 64-bit integer, and
+	// type 11 is an alternate end of subtree marker. It has no effect on
+	// the BinaryKeyValues dialect. Binary only.
+	ExtendedTypes bool
+
+	// Strict makes DecodeBinary report an error if there is any data after
+	// the end of the binary KeyValues. Binary only.
+	Strict bool
+
+	// RecordHeader, if non-nil, is called by DecodeParallel before each
+	// record to read the data that precedes it, such as the header of
+	// each app in Steam's appinfo.vdf. The bytes it returns are reported
+	// in DecodeResult.Header. It returns io.EOF if there are no more
+	// records, even if there is more input. DecodeParallel only.
+	RecordHeader func(r io.Reader) ([]byte, error)
+}
+
+// DuplicateKeyPolicy is the action taken by a decoder when it encounters a
+// key with the same name as an earlier sibling.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeepAll keeps every key, which is how KeyValues behaves.
+	DuplicateKeepAll DuplicateKeyPolicy = iota
+	// DuplicateKeepFirst discards keys that have the same name as an
+	// earlier sibling.
+	DuplicateKeepFirst
+	// DuplicateKeepLast replaces the value or children of the earlier
+	// sibling with those of the later key, keeping the earlier position.
+	DuplicateKeepLast
+	// DuplicateError makes decoding fail with a *DuplicateKeyError.
+	DuplicateError
+)
+
+// DuplicateKeyError is returned by decoders using DuplicateError.
+type DuplicateKeyError struct {
+	Name string
+}
+
+func (err *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("vdf: duplicate key %q", err.Name)
+}
+
+// Limit identifies one of the resource limits in DecodeOptions.
+type Limit int
+
+const (
+	LimitDepth Limit = iota
+	LimitNodes
+	LimitStringLength
+	LimitBytes
+)
+
+func (l Limit) String() string {
+	switch l {
+	case LimitDepth:
+		return "depth"
+	case LimitNodes:
+		return "node count"
+	case LimitStringLength:
+		return "string length"
+	case LimitBytes:
+		return "input size"
+	}
+	return fmt.Sprintf("Limit(%d)", int(l))
+}
+
+// LimitError is returned by decoders when the input exceeds one of the
+// limits set in DecodeOptions.
+type LimitError struct {
+	Limit Limit
+	Max   int64
+}
+
+func (err *LimitError) Error() string {
+	return fmt.Sprintf("vdf: exceeded maximum %v of %d", err.Limit, err.Max)
+}
+
+// EncodeOptions controls the behavior of EncodeText and EncodeBinary.
+//
+// The zero value of EncodeOptions is the behavior of MarshalText and
+// MarshalBinary. A nil *EncodeOptions is equivalent to the zero value.
+type EncodeOptions struct {
+	// Indent is written once per level of nesting by the standard
+	// formatting. If Indent is empty, a tab is used.
+	Indent string
+
+	// AlignValues pads keys written using standard formatting so that the
+	// values of sibling keys start in the same column.
+	AlignValues bool
+
+	// ForceQuotes quotes keys and values that were unquoted in the
+	// original text.
+	ForceQuotes bool
+
+	// DisableEscapes writes quoted strings without backslash escape
+	// sequences. Text only.
+	DisableEscapes bool
+
+	// TypeAnnotations records the Kind of each value that is not a string
+	// in a trailing comment, such as //vdf:int, so that text decoded with
+	// DecodeOptions.TypeAnnotations has the same types, and encodes to the
+	// same binary. Other decoders see an ordinary comment. Text only.
+	TypeAnnotations bool
+
+	// Dialect selects the binary format. Binary only.
+	Dialect BinaryDialect
+
+	// CompactInts writes integers using the smallest of the compiled
+	// integer types of the BinaryKeyValues dialect. Binary only.
+	CompactInts bool
+
+	// ExtendedTypes allows signed 64-bit integers to be written as type
+	// 10, which can only be read back with DecodeOptions.ExtendedTypes.
+	// Without it, encoding a signed 64-bit integer is an error. Binary
+	// only.
+	ExtendedTypes bool
+}
+
+var defaultDecodeOptions DecodeOptions
+var defaultEncodeOptions EncodeOptions
+
+func (o *DecodeOptions) orDefault() *DecodeOptions {
+	if o != nil {
+		return o
+	}
+	return &defaultDecodeOptions
+}
+
+func (o *EncodeOptions) orDefault() *EncodeOptions {
+	if o != nil {
+		return o
+	}
+	return &defaultEncodeOptions
+}
+
+func (o *EncodeOptions) indent(depth int) string {
+	if o.Indent == "" {
+		return strings.Repeat("\t", depth)
+	}
+	return strings.Repeat(o.Indent, depth)
+}
+
+// decodeState tracks the limits and options of a single decode operation.
+type decodeState struct {
+	opts  *DecodeOptions
+	depth int
+	nodes int
+}
+
+func newDecodeState(opts *DecodeOptions) *decodeState {
+	return &decodeState{opts: opts.orDefault()}
+}
+
+func (d *decodeState) addNode() error {
+	d.nodes++
+	if d.opts.MaxNodes > 0 && d.nodes > d.opts.MaxNodes {
+		return &LimitError{Limit: LimitNodes, Max: int64(d.opts.MaxNodes)}
+	}
+	return nil
+}
+
+func (d *decodeState) push() error {
+	d.depth++
+	if d.opts.MaxDepth > 0 && d.depth > d.opts.MaxDepth {
+		return &LimitError{Limit: LimitDepth, Max: int64(d.opts.MaxDepth)}
+	}
+	return nil
+}
+
+func (d *decodeState) pop() {
+	d.depth--
+}
+
+// checkString returns a *LimitError if a string of length n is too long.
+func (d *decodeState) checkString(n int) error {
+	if d.opts.MaxStringLength > 0 && n > d.opts.MaxStringLength {
+		return &LimitError{Limit: LimitStringLength, Max: int64(d.opts.MaxStringLength)}
+	}
+	return nil
+}
+
+// limitReader wraps r so that reading more than MaxBytes bytes fails with a
+// *LimitError.
+func (d *decodeState) limitReader(r io.Reader) io.Reader {
+	if d.opts.MaxBytes <= 0 {
+		return r
+	}
+	return &limitedReader{r: r, n: d.opts.MaxBytes, max: d.opts.MaxBytes}
+}
+
+type limitedReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// Only report the limit if there is actually more input.
+		var b [1]byte
+		if n, err := l.r.Read(b[:]); n == 0 {
+			return 0, err
+		}
+		return 0, &LimitError{Limit: LimitBytes, Max: l.max}
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// keep decides whether the completed node c stays in the tree, applying
+// condition evaluation and the duplicate key policy. seen holds the earlier
+// siblings of c by lowercase name and is allocated on first use.
+func (d *decodeState) keep(c *Node, seen *map[string]*Node) (bool, error) {
+	if c.condition != "" && d.opts.EvaluateConditions != nil {
+		if !d.opts.EvaluateConditions(c.condition) {
+			return false, nil
+		}
+		c.condition = ""
+		if c.cf != nil {
+			c.cf.condition = ""
+		}
+	}
+
+	if d.opts.DuplicateKeys == DuplicateKeepAll {
+		return true, nil
+	}
+
+	if *seen == nil {
+		*seen = make(map[string]*Node)
+	}
+	key := strings.ToLower(c.name)
+	prev, ok := (*seen)[key]
+	if !ok {
+		(*seen)[key] = c
+		return true, nil
+	}
+
+	switch d.opts.DuplicateKeys {
+	case DuplicateKeepFirst:
+		return false, nil
+	case DuplicateKeepLast:
+		prev.name = c.name
+		prev.sym = c.sym
+		prev.condition = c.condition
+		prev.value = c.value
+		prev.child = c.child
+		prev.index = nil
+		leading, _ := prev.Comments()
+		_, trailing := c.Comments()
+		prev.comment = newComment(leading, trailing)
+		for cc := prev.child; cc != nil; cc = cc.next {
+			cc.parent = prev
+		}
+		if prev.cf != nil && c.cf != nil {
+			before := prev.cf.before
+			*prev.cf = *c.cf
+			prev.cf.before = before
+		}
+		return false, nil
+	default:
+		return false, &DuplicateKeyError{Name: c.name}
+	}
+}
+
+// discard unlinks c, the most recently decoded node, from its siblings. If
+// c is the first node in its list, it is reset so that it can be reused
+// for the next node, and discard returns c. Otherwise, discard returns nil.
+func discard(c *Node) *Node {
+	if c.prev != nil {
+		c.prev.next = nil
+		return nil
+	}
+	*c = Node{parent: c.parent}
+	return c
+}
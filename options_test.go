@@ -0,0 +1,187 @@
+package vdf_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+func TestDecodeOptions(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		opts vdf.DecodeOptions
+		out  string
+		err  bool
+	}{
+		{
+			name: "Default",
+			in:   `a { b 1 B 2 }`,
+			out:  "\"a\" {\n\t\"b\" \"1\"\n\t\"B\" \"2\"\n}\n",
+		},
+		{
+			name: "MaxDepth",
+			in:   `a { b { c 1 } }`,
+			opts: vdf.DecodeOptions{MaxDepth: 1},
+			err:  true,
+		},
+		{
+			name: "MaxNodes",
+			in:   `a { b 1 c 2 }`,
+			opts: vdf.DecodeOptions{MaxNodes: 2},
+			err:  true,
+		},
+		{
+			name: "DisableEscapes",
+			in:   `a "b\n"`,
+			opts: vdf.DecodeOptions{DisableEscapes: true},
+			out:  "\"a\" \"b\\\\n\"\n",
+		},
+		{
+			name: "EvaluateConditions",
+			in:   "a [$WIN32] { b 1 [$X360] c 2 [!$X360] }\nd 3 [$X360]\ne 4",
+			opts: vdf.DecodeOptions{EvaluateConditions: func(cond string) bool {
+				return cond == "$WIN32" || cond == "!$X360"
+			}},
+			out: "\"a\" {\n\t\"c\" \"2\"\n}\n\"e\" \"4\"\n",
+		},
+		{
+			name: "DuplicateKeepFirst",
+			in:   `a { b 1 B 2 c 3 }`,
+			opts: vdf.DecodeOptions{DuplicateKeys: vdf.DuplicateKeepFirst},
+			out:  "\"a\" {\n\t\"b\" \"1\"\n\t\"c\" \"3\"\n}\n",
+		},
+		{
+			name: "DuplicateKeepLast",
+			in:   `a { b 1 c 3 B 2 } a { d 4 }`,
+			opts: vdf.DecodeOptions{DuplicateKeys: vdf.DuplicateKeepLast},
+			out:  "\"a\" {\n\t\"d\" \"4\"\n}\n",
+		},
+		{
+			name: "DuplicateError",
+			in:   `a { b 1 B 2 }`,
+			opts: vdf.DecodeOptions{DuplicateKeys: vdf.DuplicateError},
+			err:  true,
+		},
+		{
+			name: "DiscardComments",
+			in:   "a 1 // one\n// two\nb 2",
+			opts: vdf.DecodeOptions{DiscardComments: true},
+			out:  "a 1 \n\nb 2",
+		},
+	} {
+		tt := tt // shadow
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			n, err := vdf.DecodeText([]byte(tt.in), &tt.opts)
+			if tt.err {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.opts.DiscardComments {
+				n.ClearFormatting()
+			}
+			out, err := n.MarshalText()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.out {
+				t.Errorf("expected %q, got %q", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestEncodeOptions(t *testing.T) {
+	n, err := vdf.DecodeText([]byte(`a { b 1 long_name 2 c { d "x\"" } }`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := vdf.EncodeText(n, &vdf.EncodeOptions{ForceQuotes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `"a" { "b" "1" "long_name" "2" "c" { "d" "x\"" } }`; string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	n.ClearFormatting()
+	out, err = vdf.EncodeText(n, &vdf.EncodeOptions{Indent: "  ", AlignValues: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "\"a\" {\n  \"b\"         \"1\"\n  \"long_name\" \"2\"\n  \"c\" {\n    \"d\" \"x\\\"\"\n  }\n}\n"; string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestDecodeBinaryDuplicates(t *testing.T) {
+	in := []byte("\x00a\x00\x01b\x00x\x00\x01B\x00y\x00\x08\x08")
+
+	n, err := vdf.DecodeBinary(in, &vdf.DecodeOptions{DuplicateKeys: vdf.DuplicateKeepLast})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := n.FirstChild(); b.Name() != "B" || b.String() != "y" || b.NextChild() != nil {
+		t.Errorf("unexpected result: %q %q", b.Name(), b.String())
+	}
+
+	if _, err = vdf.DecodeBinary(in, &vdf.DecodeOptions{DuplicateKeys: vdf.DuplicateError}); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	deep := bytes.Repeat([]byte("\x00a\x00"), 100000)
+
+	for _, tt := range []struct {
+		name   string
+		binary bool
+		in     []byte
+		opts   vdf.DecodeOptions
+		limit  vdf.Limit
+	}{
+		{"TextDepth", false, bytes.Repeat([]byte("a {"), 100), vdf.DecodeOptions{MaxDepth: 10}, vdf.LimitDepth},
+		{"BinaryDepth", true, deep, vdf.DecodeOptions{MaxDepth: 10}, vdf.LimitDepth},
+		{"TextNodes", false, bytes.Repeat([]byte("a b "), 100), vdf.DecodeOptions{MaxNodes: 10}, vdf.LimitNodes},
+		{"BinaryNodes", true, deep, vdf.DecodeOptions{MaxNodes: 10}, vdf.LimitNodes},
+		{"TextQuoted", false, []byte(`a "` + strings.Repeat("b", 100) + `"`), vdf.DecodeOptions{MaxStringLength: 10}, vdf.LimitStringLength},
+		{"TextUnquoted", false, []byte(strings.Repeat("b", 100)), vdf.DecodeOptions{MaxStringLength: 10}, vdf.LimitStringLength},
+		{"BinaryString", true, []byte("\x01" + strings.Repeat("b", 10000)), vdf.DecodeOptions{MaxStringLength: 10}, vdf.LimitStringLength},
+		{"BinaryWString", true, []byte("\x05a\x00\xff\xff"), vdf.DecodeOptions{MaxStringLength: 10}, vdf.LimitStringLength},
+		{"TextBytes", false, bytes.Repeat([]byte("a b "), 10000), vdf.DecodeOptions{MaxBytes: 100}, vdf.LimitBytes},
+		{"BinaryBytes", true, deep, vdf.DecodeOptions{MaxBytes: 100}, vdf.LimitBytes},
+	} {
+		tt := tt // shadow
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var err error
+			if tt.binary {
+				_, err = vdf.DecodeBinary(tt.in, &tt.opts)
+			} else {
+				_, err = vdf.DecodeText(tt.in, &tt.opts)
+			}
+
+			var le *vdf.LimitError
+			if !errors.As(err, &le) {
+				t.Errorf("expected *vdf.LimitError, got %T: %v", err, err)
+			} else if le.Limit != tt.limit {
+				t.Errorf("expected limit %v, got %v", tt.limit, le.Limit)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package vdf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// DecodeResult is a single record decoded by DecodeParallel.
+type DecodeResult struct {
+	// Node is the decoded record, or nil if Err is set.
+	Node *Node
+	// Offset is the position of the start of the record in the input.
+	Offset int64
+	// Header is the data read by DecodeOptions.RecordHeader before the
+	// record, if it is set.
+	Header []byte
+	// Err is the error from decoding the record.
+	Err error
+}
+
+// DecodeParallel reads a sequence of concatenated binary KeyValues from r,
+// like Decoder, but decodes them using a pool of workers goroutines. If
+// workers is zero or negative, runtime.GOMAXPROCS(0) is used.
+//
+// Records are found one at a time by reading only their structure, and are
+// then decoded by the workers. The results are sent on the returned channel
+// in the order that the records appear in the input. An error decoding a
+// record, such as exceeding MaxDepth, MaxNodes, or MaxStringLength in opts,
+// is reported in its result, and decoding continues with the next record.
+// Only MaxBytes and the structure of the input are checked while finding
+// the records, so a record that exceeds MaxBytes, ends early, or is
+// otherwise malformed ends the results with a *BinaryError. The channel is
+// closed after the last result.
+//
+// Each record may be preceded by a header, which is read by
+// opts.RecordHeader and reported in the record's result. A header that
+// cannot be read ends the results with its error, as a malformed record
+// does.
+//
+// If ctx is canceled, no more results are sent and the channel is closed.
+// The caller must either receive every result or cancel ctx, or the
+// goroutines started by DecodeParallel will never exit.
+func DecodeParallel(ctx context.Context, r io.Reader, opts *DecodeOptions, workers int) <-chan DecodeResult {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	out := make(chan DecodeResult)
+	jobs := make(chan *decodeJob)
+	// pending holds the jobs in input order, and limits how far ahead of
+	// the caller the workers can get.
+	pending := make(chan *decodeJob, 2*workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				j.done <- j.decode(opts)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+
+		f := newRecordFramer(r, opts)
+		for {
+			header, b, off, err := f.next()
+			if err == io.EOF {
+				return
+			}
+			j := &decodeJob{header: header, b: b, off: off, done: make(chan DecodeResult, 1)}
+			if err != nil {
+				j.done <- DecodeResult{Offset: off, Header: header, Err: err}
+			}
+
+			select {
+			case pending <- j:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(out)
+		defer wg.Wait()
+
+		for j := range pending {
+			var res DecodeResult
+			select {
+			case res = <-j.done:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// decodeJob is a record found by DecodeParallel.
+type decodeJob struct {
+	header []byte
+	b      []byte
+	off    int64
+	done   chan DecodeResult
+}
+
+func (j *decodeJob) decode(opts *DecodeOptions) DecodeResult {
+	d := newDecodeState(opts)
+	r := newBinaryReader(bytes.NewReader(j.b), d)
+	r.off = j.off
+	r.start = j.off
+
+	n := new(Node)
+	if err := r.decode(n); err != nil {
+		return DecodeResult{Offset: j.off, Header: j.header, Err: err}
+	}
+	return DecodeResult{Node: n, Offset: j.off, Header: j.header}
+}
+
+// recordFramer splits a stream of binary KeyValues into records without
+// decoding their values.
+type recordFramer struct {
+	r    *binaryReader
+	opts *DecodeOptions
+	buf  []byte
+	last int // length of the previous record
+}
+
+func newRecordFramer(r io.Reader, opts *DecodeOptions) *recordFramer {
+	// The limits on the contents of a record are left to the workers, so
+	// that a record that exceeds them is reported in its own result. Only
+	// MaxBytes is needed to find the end of a record safely.
+	o := *opts.orDefault()
+	o.MaxDepth, o.MaxNodes, o.MaxStringLength = 0, 0, 0
+	return &recordFramer{r: newBinaryReader(r, newDecodeState(&o)), opts: &o}
+}
+
+// next returns the header and the bytes of the next record and the offset
+// of the record in the input. At the end of the input, or if the header
+// reader says there are no more records, next returns io.EOF.
+func (f *recordFramer) next() ([]byte, []byte, int64, error) {
+	r := f.r
+	r.d = newDecodeState(f.opts)
+	r.start = r.off
+
+	var header []byte
+	if f.opts.RecordHeader != nil {
+		var err error
+		if header, err = f.opts.RecordHeader(r); err != nil {
+			return header, nil, r.off, err
+		}
+		r.start = r.off
+	} else if _, err := r.r.Peek(1); err != nil {
+		return nil, nil, r.off, err
+	}
+
+	// Records in the same file tend to be similar in size.
+	f.buf = make([]byte, 0, f.last)
+	if err := f.skip(); err != nil {
+		return header, nil, r.start, &BinaryError{Offset: r.nodeOffset, PackType: r.packType, Err: unexpectedEOF(err)}
+	}
+	f.last = len(f.buf)
+	return header, f.buf, r.start, nil
+}
+
+// skip reads the keys up to the end marker of the record and appends them
+// to f.buf. Subtrees are followed with a counter rather than recursion, as
+// their depth is not limited here.
+func (f *recordFramer) skip() error {
+	r, d := f.r, f.r.d
+	depth := 0
+	for {
+		pt, err := r.readPackType()
+		if err != nil {
+			return err
+		}
+		f.buf = append(f.buf, pt)
+		if d.isEnd(pt) {
+			if depth == 0 {
+				return nil
+			}
+			depth--
+			continue
+		}
+
+		if err = f.appendCString(); err != nil {
+			return err
+		}
+
+		size, err := d.valueSize(pt)
+		if err != nil {
+			return err
+		}
+		switch size {
+		case sizeSubtree:
+			depth++
+			continue
+		case sizeString:
+			if err = f.appendCString(); err != nil {
+				return err
+			}
+			continue
+		case sizeWString:
+			var length [2]byte
+			if _, err = io.ReadFull(r, length[:]); err != nil {
+				return err
+			}
+			f.buf = append(f.buf, length[:]...)
+			size = 2 * int(binary.LittleEndian.Uint16(length[:]))
+		}
+
+		start := len(f.buf)
+		f.buf = append(f.buf, make([]byte, size)...)
+		if _, err = io.ReadFull(r, f.buf[start:]); err != nil {
+			return err
+		}
+	}
+}
+
+// appendCString appends a null-terminated string, including the terminator,
+// to f.buf.
+func (f *recordFramer) appendCString() error {
+	for {
+		b, err := f.r.ReadSlice(0)
+		f.buf = append(f.buf, b...)
+		if err != bufio.ErrBufferFull {
+			return err
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package vdf_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+func TestDecodeParallel(t *testing.T) {
+	t.Parallel()
+
+	in, err := ioutil.ReadFile("testdata/UserGameStatsSchema_630.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	small := []byte("\x00a\x00\x01b\x00c\x00\x05w\x00\x01\x00x\x00\x08\x08")
+	record := append(append([]byte(nil), in...), small...)
+
+	for _, workers := range []int{0, 1, 3} {
+		count := 0
+		for res := range vdf.DecodeParallel(context.Background(), bytes.NewReader(bytes.Repeat(record, 20)), nil, workers) {
+			if res.Err != nil {
+				t.Fatal(res.Err)
+			}
+			expected, offset := in, int64((count/2)*len(record))
+			if count%2 == 1 {
+				expected, offset = small, offset+int64(len(in))
+			}
+			if res.Offset != offset {
+				t.Errorf("record %d: expected offset %d, got %d", count, offset, res.Offset)
+			}
+			out, err := res.Node.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(expected, out) {
+				t.Errorf("record %d differs", count)
+			}
+			count++
+		}
+		if count != 40 {
+			t.Errorf("%d workers: expected 40 records, got %d", workers, count)
+		}
+	}
+}
+
+func TestDecodeParallelErrors(t *testing.T) {
+	t.Parallel()
+
+	// The second record has a duplicate key, and the third is truncated.
+	in := []byte("\x01a\x00b\x00\x08\x01a\x00b\x00\x01a\x00c\x00\x08\x00x\x00\x01y\x00")
+	var results []vdf.DecodeResult
+	for res := range vdf.DecodeParallel(context.Background(), bytes.NewReader(in), &vdf.DecodeOptions{DuplicateKeys: vdf.DuplicateError}, 2) {
+		results = append(results, res)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	if results[0].Err != nil || results[0].Node.String() != "b" {
+		t.Errorf("first record: unexpected result %v %v", results[0].Node, results[0].Err)
+	}
+
+	var dup *vdf.DuplicateKeyError
+	if !errors.As(results[1].Err, &dup) || results[1].Offset != 6 {
+		t.Errorf("second record: expected duplicate key error at offset 6, got %v at %d", results[1].Err, results[1].Offset)
+	}
+
+	var be *vdf.BinaryError
+	if !errors.As(results[2].Err, &be) || be.Err != io.ErrUnexpectedEOF || be.Offset != 20 || results[2].Offset != 17 {
+		t.Errorf("third record: expected unexpected EOF at offset 20, got %v at %d", results[2].Err, results[2].Offset)
+	}
+}
+
+func TestDecodeParallelLimits(t *testing.T) {
+	t.Parallel()
+
+	// Each of the first three records exceeds one limit, and the last is
+	// valid.
+	in := []byte("\x00a\x00\x00b\x00\x01c\x00d\x00\x08\x08\x08" +
+		"\x01a\x00b\x00\x01c\x00d\x00\x01e\x00f\x00\x08" +
+		"\x01a\x00bbbbbbbb\x00\x08" +
+		"\x01a\x00b\x00\x08")
+	opts := &vdf.DecodeOptions{MaxDepth: 1, MaxNodes: 2, MaxStringLength: 4}
+	var results []vdf.DecodeResult
+	for res := range vdf.DecodeParallel(context.Background(), bytes.NewReader(in), opts, 2) {
+		results = append(results, res)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+
+	for i, limit := range []vdf.Limit{vdf.LimitDepth, vdf.LimitNodes, vdf.LimitStringLength} {
+		var le *vdf.LimitError
+		if !errors.As(results[i].Err, &le) || le.Limit != limit {
+			t.Errorf("record %d: expected %v limit error, got %v", i, limit, results[i].Err)
+		}
+	}
+	if results[3].Err != nil || results[3].Node.String() != "b" || results[3].Offset != int64(len(in)-6) {
+		t.Errorf("last record: unexpected result %v %v at %d", results[3].Node, results[3].Err, results[3].Offset)
+	}
+}
+
+// appInfoHeader reads the header before each app in version 28 of Steam's
+// appinfo.vdf: the app ID, the size of the rest of the entry, and 60 bytes
+// of metadata. An app ID of 0 ends the list.
+func appInfoHeader(r io.Reader) ([]byte, error) {
+	header := make([]byte, 68)
+	if _, err := io.ReadFull(r, header[:4]); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(header) == 0 {
+		return nil, io.EOF
+	}
+	if _, err := io.ReadFull(r, header[4:]); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return header, nil
+}
+
+func TestDecodeParallelRecordHeader(t *testing.T) {
+	t.Parallel()
+
+	in, err := ioutil.ReadFile("testdata/appinfo.vdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Skip the magic number and universe at the start of the file.
+	in = in[8:]
+
+	opts := &vdf.DecodeOptions{RecordHeader: appInfoHeader}
+	var results []vdf.DecodeResult
+	for res := range vdf.DecodeParallel(context.Background(), bytes.NewReader(in), opts, 2) {
+		results = append(results, res)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	off := int64(0)
+	for i, name := range []string{"Counter-Strike", "Team Fortress Classic", "Day of Defeat"} {
+		res := results[i]
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		appID := binary.LittleEndian.Uint32(res.Header)
+		if off += 68; res.Offset != off {
+			t.Errorf("app %d: expected offset %d, got %d", appID, off, res.Offset)
+		}
+		off += int64(binary.LittleEndian.Uint32(res.Header[4:])) - 60
+
+		if id := res.Node.FirstByName("appid").Int(); uint32(id) != appID {
+			t.Errorf("app %d: header and record disagree, record has %d", appID, id)
+		}
+		if s := res.Node.FirstByName("common").FirstByName("name").String(); s != name {
+			t.Errorf("app %d: expected name %q, got %q", appID, name, s)
+		}
+	}
+	if off+4 != int64(len(in)) {
+		t.Errorf("expected the list to end at %d, got %d", len(in)-4, off)
+	}
+
+	// Input that ends in a header ends the results with an error.
+	results = results[:0]
+	for res := range vdf.DecodeParallel(context.Background(), bytes.NewReader(in[:thirdApp(in)+10]), opts, 2) {
+		results = append(results, res)
+	}
+	if len(results) != 3 || results[2].Err != io.ErrUnexpectedEOF || results[2].Node != nil {
+		t.Errorf("expected a truncated third header, got %+v", results)
+	}
+}
+
+// thirdApp returns the offset of the header of the third app in the list
+// of apps from appinfo.vdf.
+func thirdApp(in []byte) int {
+	off := 0
+	for i := 0; i < 2; i++ {
+		off += 8 + int(binary.LittleEndian.Uint32(in[off+4:]))
+	}
+	return off
+}
+
+func TestDecodeParallelCancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := vdf.DecodeParallel(ctx, bytes.NewReader(bytes.Repeat([]byte("\x01a\x00b\x00\x08"), 1000)), nil, 2)
+	if res := <-results; res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	cancel()
+
+	count := 0
+	for range results {
+		count++
+	}
+	if count >= 999 {
+		t.Errorf("expected decoding to stop early, got %d more results", count)
+	}
+}
+
+func benchmarkRecords(b *testing.B) []byte {
+	in, err := ioutil.ReadFile("testdata/UserGameStatsSchema_630.bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return bytes.Repeat(in, 100)
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	in := benchmarkRecords(b)
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		dec := vdf.NewDecoder(bytes.NewReader(in), nil)
+		for dec.More() {
+			var n vdf.Node
+			if err := dec.Decode(&n); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeParallel(b *testing.B) {
+	in := benchmarkRecords(b)
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for res := range vdf.DecodeParallel(context.Background(), bytes.NewReader(in), nil, 0) {
+			if res.Err != nil {
+				b.Fatal(res.Err)
+			}
+		}
+	}
+}
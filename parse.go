@@ -0,0 +1,452 @@
+package vdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// slabSize is the number of Nodes allocated at a time by ParseText.
+const slabSize = 128
+
+// ParseText is like DecodeText, but is optimized for large documents.
+//
+// ParseText makes a single copy of b, and the names, values, and recorded
+// formatting of the decoded Nodes are substrings of that copy rather than
+// separate allocations. Nodes are allocated in blocks rather than one at a
+// time. As a result, the memory used by the document is only released once
+// none of its Nodes are reachable, even if most of them have been removed
+// from the tree.
+//
+// The decoded Nodes are identical to those returned by DecodeText. If
+// DecodeOptions.MaxBytes is set, b is rejected before it is parsed if it is
+// too long.
+func ParseText(b []byte, opts *DecodeOptions) (*Node, error) {
+	d := newDecodeState(opts)
+	if d.opts.MaxBytes > 0 && int64(len(b)) > d.opts.MaxBytes {
+		return nil, &LimitError{Limit: LimitBytes, Max: d.opts.MaxBytes}
+	}
+
+	p := &textParser{d: d, src: string(b)}
+	n := p.newNode()
+	if err := p.parse(n); err != nil {
+		return nil, err
+	}
+	if d.opts.InferTypes != nil {
+		if err := n.InferTypes(d.opts.InferTypes); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// textParser holds the state of ParseText. It follows the same steps as
+// readAsText, but reads from a string rather than a bufio.Reader so that
+// tokens can be returned without copying them.
+type textParser struct {
+	d       *decodeState
+	src     string
+	pos     int
+	start   int // position of the prefix of the last token
+	nodes   []Node
+	formats []customFormat
+}
+
+func (p *textParser) newNode() *Node {
+	if len(p.nodes) == 0 {
+		p.nodes = make([]Node, slabSize)
+	}
+	n := &p.nodes[0]
+	p.nodes = p.nodes[1:]
+	return n
+}
+
+func (p *textParser) newFormat() *customFormat {
+	if len(p.formats) == 0 {
+		p.formats = make([]customFormat, slabSize)
+	}
+	cf := &p.formats[0]
+	p.formats = p.formats[1:]
+	return cf
+}
+
+func (p *textParser) parse(n *Node) error {
+	d := p.d
+	var last *Node
+	var seen map[string]*Node
+	current := n
+	prefix, s, wasQuoted, wasConditional, err := p.readToken()
+	if err != nil {
+		return err
+	}
+	for {
+		if wasConditional {
+			return fmt.Errorf("vdf: unexpected conditional %q", s)
+		}
+		if !wasQuoted && s == "}" {
+			return errClose(prefix)
+		}
+		if !wasQuoted && s == "{" {
+			return fmt.Errorf("vdf: unexpected {")
+		}
+		if current == nil {
+			current = p.newNode()
+			current.parent = last.parent
+			current.prev = last
+			last.next = current
+		}
+		if err = d.addNode(); err != nil {
+			return err
+		}
+		current.cf = p.newFormat()
+		current.cf.before = prefix
+		current.cf.unquotedKey = !wasQuoted
+		leading := leadingComment(prefix)
+		current.name, current.sym = d.internName(s)
+		prefix, s, wasQuoted, wasConditional, err = p.readToken()
+		if err != nil {
+			return err
+		}
+		if wasConditional {
+			current.cf.condition = prefix
+			current.condition = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+			prefix, s, wasQuoted, wasConditional, err = p.readToken()
+			if err != nil {
+				return err
+			}
+			if s != "{" || wasQuoted || wasConditional {
+				return fmt.Errorf("vdf: missing {")
+			}
+		}
+
+		atEOF := false
+		if !wasQuoted && s == "{" {
+			start := p.start
+			var suffix string
+			suffix, err = p.readLineEnding()
+			if err != nil {
+				return err
+			}
+			current.cf.between = p.join(start, p.pos, prefix, s, suffix)
+
+			if err = d.push(); err != nil {
+				return err
+			}
+			c := p.newNode()
+			c.parent = current
+			err = p.parse(c)
+			if cl, ok := err.(errClose); ok {
+				prefix = string(cl)
+			} else if err != nil {
+				return err
+			} else {
+				return fmt.Errorf("vdf: missing }")
+			}
+			d.pop()
+			if c.cf != nil {
+				current.child = c
+			}
+
+			start = p.start
+			suffix, err = p.readLineEnding()
+			if err != nil {
+				return err
+			}
+			current.cf.after = p.join(start, p.pos, prefix, "}", suffix)
+			current.comment = newComment(leading, trailingComment(suffix))
+
+			prefix, s, wasQuoted, wasConditional, err = p.readToken()
+		} else {
+			start := p.pos
+			var suffix string
+			suffix, err = p.readLineEnding()
+			if err != nil {
+				return err
+			}
+			current.cf.between = prefix
+			current.cf.unquotedValue = !wasQuoted
+			current.value = s
+			current.cf.after = suffix
+
+			prefix, s, wasQuoted, wasConditional, err = p.readToken()
+			if err == io.EOF {
+				atEOF = true
+			} else if err != nil {
+				return err
+			} else if wasConditional {
+				current.cf.condition = p.join(start, p.pos-len(s), suffix, prefix)
+				current.condition = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+				suffix, err = p.readLineEnding()
+				if err != nil {
+					return err
+				}
+				current.cf.after = suffix
+				prefix, s, wasQuoted, wasConditional, err = p.readToken()
+			}
+			trailing := trailingComment(current.cf.after)
+			if d.opts.TypeAnnotations {
+				if kind, rest, ok := splitAnnotation(trailing); ok {
+					v, perr := parseAnnotated(current.value.(string), kind)
+					if perr != nil {
+						return perr
+					}
+					current.value = v
+					trailing = rest
+				}
+			}
+			current.comment = newComment(leading, trailing)
+		}
+
+		keep, kerr := d.keep(current, &seen)
+		if kerr != nil {
+			return kerr
+		}
+		if keep {
+			last = current
+			current = nil
+		} else {
+			current = discard(current)
+		}
+		if atEOF {
+			return nil
+		}
+		if err != nil {
+			if last != nil {
+				last.cf.after += prefix
+			}
+			return eofOK(err)
+		}
+	}
+}
+
+// join returns the input between start and end, which was returned in
+// parts. If comments are being discarded, the parts may differ from the
+// input, so they are concatenated instead.
+func (p *textParser) join(start, end int, parts ...string) string {
+	if !p.d.opts.DiscardComments {
+		return p.src[start:end]
+	}
+	return strings.Join(parts, "")
+}
+
+func (p *textParser) readToken() (prefix, s string, wasQuoted, wasConditional bool, err error) {
+	p.start = p.pos
+	prefix, err = p.readPrefix()
+	if err != nil {
+		return
+	}
+
+	if p.pos == len(p.src) {
+		err = io.EOF
+		return
+	}
+	c := p.src[p.pos]
+	p.pos++
+
+	if c == '"' {
+		wasQuoted = true
+		s, err = p.readQuoted()
+		return
+	}
+
+	if c == '{' || c == '}' {
+		s = p.src[p.pos-1 : p.pos]
+		return
+	}
+
+	start := p.pos - 1
+	conditionalStart := c == '['
+	for p.pos < len(p.src) {
+		if p.d.opts.BlockComments && strings.HasPrefix(p.src[p.pos:], "/*") {
+			break
+		}
+
+		c = p.src[p.pos]
+		if c == '"' || c == '{' || c == '}' {
+			break
+		}
+
+		if c == '[' {
+			conditionalStart = true
+		}
+
+		if c == ']' && conditionalStart {
+			wasConditional = true
+		}
+
+		if unicode.IsSpace(rune(c)) {
+			break
+		}
+
+		p.pos++
+		if err = p.d.checkString(p.pos - start); err != nil {
+			return
+		}
+	}
+
+	s = p.src[start:p.pos]
+	return
+}
+
+func (p *textParser) readPrefix() (string, error) {
+	start := p.pos
+	// If comments are being discarded, buf holds the prefix up to copied.
+	var buf []byte
+	discarded := false
+	copied := start
+	for {
+		for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+			p.pos++
+		}
+		if p.pos == len(p.src) {
+			return p.prefix(start, copied, buf, discarded), io.EOF
+		}
+		if p.pos+2 > len(p.src) {
+			break
+		}
+
+		commentStart := p.pos
+		var ending string
+		if p.d.opts.BlockComments && strings.HasPrefix(p.src[p.pos:], "/*") {
+			i := strings.Index(p.src[p.pos+2:], "*/")
+			if i == -1 {
+				return "", fmt.Errorf("vdf: unterminated block comment")
+			}
+			p.pos += 2 + i + 2
+			if lines := strings.Count(p.src[commentStart:p.pos], "\n"); lines != 0 {
+				ending = strings.Repeat("\n", lines)
+			} else {
+				ending = " "
+			}
+		} else {
+			marker := p.d.lineComment(p.src[p.pos], p.src[p.pos+1])
+			if marker == "" {
+				break
+			}
+			ending = p.skipLine()
+		}
+
+		if p.d.opts.DiscardComments {
+			buf = append(buf, p.src[copied:commentStart]...)
+			buf = append(buf, ending...)
+			copied = p.pos
+			discarded = true
+		}
+	}
+	return p.prefix(start, copied, buf, discarded), nil
+}
+
+// prefix returns the prefix that started at start, which has been copied
+// to buf up to copied if any comments were discarded.
+func (p *textParser) prefix(start, copied int, buf []byte, discarded bool) string {
+	if !discarded {
+		return p.src[start:p.pos]
+	}
+	return string(append(buf, p.src[copied:p.pos]...))
+}
+
+// skipLine skips the rest of a line comment, including the newline, and
+// returns the line ending that is kept if comments are being discarded.
+func (p *textParser) skipLine() string {
+	i := strings.IndexByte(p.src[p.pos:], '\n')
+	if i == -1 {
+		p.pos = len(p.src)
+		return ""
+	}
+	p.pos += i + 1
+	return "\n"
+}
+
+func (p *textParser) readQuoted() (string, error) {
+	start := p.pos
+	i := strings.IndexByte(p.src[start:], '"')
+	if i != -1 && (p.d.opts.DisableEscapes || strings.IndexByte(p.src[start:start+i], '\\') == -1) {
+		if err := p.d.checkString(i); err != nil {
+			return "", err
+		}
+		p.pos += i + 1
+		return p.src[start : start+i], nil
+	}
+
+	// The string is unterminated or has escape sequences.
+	var buf []byte
+	for {
+		if p.pos == len(p.src) {
+			return "", io.ErrUnexpectedEOF
+		}
+		c := p.src[p.pos]
+		p.pos++
+
+		if c == '"' {
+			return string(buf), nil
+		}
+
+		if c == '\\' && !p.d.opts.DisableEscapes {
+			if p.pos == len(p.src) {
+				return "", io.ErrUnexpectedEOF
+			}
+			if e, ok := unescapeByte(p.src[p.pos]); ok {
+				p.pos++
+				c = e
+			}
+		}
+
+		buf = append(buf, c)
+		if err := p.d.checkString(len(buf)); err != nil {
+			return "", err
+		}
+	}
+}
+
+// unescapeByte returns the byte represented by a backslash followed by c.
+func unescapeByte(c byte) (byte, bool) {
+	switch c {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case 'v':
+		return '\v', true
+	case 'b':
+		return '\b', true
+	case 'r':
+		return '\r', true
+	case 'f':
+		return '\f', true
+	case 'a':
+		return '\a', true
+	case '\\', '\'', '"':
+		return c, true
+	}
+	return 0, false
+}
+
+func (p *textParser) readLineEnding() (string, error) {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !unicode.IsSpace(rune(c)) {
+			break
+		}
+		p.pos++
+		if c == '\n' {
+			return p.src[start:p.pos], nil
+		}
+	}
+
+	if p.pos+2 > len(p.src) {
+		return p.src[start:p.pos], nil
+	}
+	marker := p.d.lineComment(p.src[p.pos], p.src[p.pos+1])
+	if marker == "" {
+		return p.src[start:p.pos], nil
+	}
+
+	commentStart := p.pos
+	ending := p.skipLine()
+	if p.d.opts.DiscardComments {
+		return p.src[start:commentStart] + ending, nil
+	}
+	return p.src[start:p.pos], nil
+}
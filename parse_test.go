@@ -0,0 +1,183 @@
+package vdf_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+// checkParseText reports any difference between the results of DecodeText
+// and ParseText for in.
+func checkParseText(t testing.TB, in []byte, opts *vdf.DecodeOptions) {
+	expected, err1 := vdf.DecodeText(in, opts)
+	actual, err2 := vdf.ParseText(in, opts)
+	if err1 != nil || err2 != nil {
+		if err1 == nil || err2 == nil || err1.Error() != err2.Error() {
+			t.Errorf("%q: DecodeText error: %v, ParseText error: %v", in, err1, err2)
+		}
+		return
+	}
+
+	encode := func(n *vdf.Node) []byte {
+		b, err := vdf.EncodeText(n, &vdf.EncodeOptions{TypeAnnotations: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	if want, got := encode(expected), encode(actual); !bytes.Equal(want, got) {
+		t.Errorf("%q: formatted output differs:\nDecodeText: %q\nParseText:  %q", in, want, got)
+	}
+	expected.ClearFormatting()
+	actual.ClearFormatting()
+	if want, got := encode(expected), encode(actual); !bytes.Equal(want, got) {
+		t.Errorf("%q: standard output differs:\nDecodeText: %q\nParseText:  %q", in, want, got)
+	}
+}
+
+func TestParseText(t *testing.T) {
+	inputs := []string{
+		"",
+		"   \n",
+		`a b`,
+		`"a" "b"`,
+		"a b // comment",
+		"a b\n\n// trailing\n",
+		"// leading\n\"a\" { // after brace\n\t\"b\" \"c\" // after value\n} // after close\n",
+		"a { b { c { d 1 } } }\ne f\n",
+		"a {}",
+		`a { }`,
+		"a [$X] { b 1 }",
+		"a 1 [$X]\nb 2 [!$X]",
+		"a \"1\" [$X] // comment\nb 2",
+		`a "b\"c\\d\n\x"`,
+		`a "unterminated`,
+		`a "escape at end\`,
+		`a { b 1`,
+		`a { b 1 } }`,
+		`a {{`,
+		`a [$X] b`,
+		`[$X] a`,
+		"a b /* block */ c d",
+		"a/*x*/b",
+		"/* a\nb */ a /* c */ b\n",
+		"a b /* unterminated",
+		"#base \"x.res\"\n# comment\na b # trailing\n",
+		"a b\n#",
+		"a \"1\" //vdf:int note\nb \"x\" //vdf:float",
+		"a { b 1 B 2 c 3 } a { d 4 }",
+		"a 1 [$X] b 2 [$Y]",
+		"a\x85b\xa0c",
+		"key \"" + string(bytes.Repeat([]byte{'x'}, 100)) + "\"",
+	}
+	opts := []vdf.DecodeOptions{
+		{},
+		{DisableEscapes: true},
+		{DiscardComments: true},
+		{BlockComments: true},
+		{BlockComments: true, DiscardComments: true},
+		{HashComments: true},
+		{HashComments: true, DiscardComments: true},
+		{TypeAnnotations: true},
+		{DuplicateKeys: vdf.DuplicateKeepFirst},
+		{DuplicateKeys: vdf.DuplicateKeepLast},
+		{DuplicateKeys: vdf.DuplicateError},
+		{EvaluateConditions: func(cond string) bool { return cond == "$X" }},
+		{MaxDepth: 2, MaxNodes: 4, MaxStringLength: 8},
+		{InferTypes: &vdf.InferOptions{Strict: true}},
+	}
+
+	files, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, string(b))
+	}
+
+	for i := range opts {
+		opt := &opts[i]
+
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+
+			for _, in := range inputs {
+				checkParseText(t, []byte(in), opt)
+			}
+		})
+	}
+}
+
+func TestParseTextMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	_, err := vdf.ParseText([]byte(`a { b 1 }`), &vdf.DecodeOptions{MaxBytes: 4})
+	if le, ok := err.(*vdf.LimitError); !ok || le.Limit != vdf.LimitBytes {
+		t.Errorf("expected byte limit error, got %v", err)
+	}
+	if _, err = vdf.ParseText([]byte(`a { b 1 }`), &vdf.DecodeOptions{MaxBytes: 9}); err != nil {
+		t.Error(err)
+	}
+}
+
+// largeDocument returns text similar to items_game.txt, with formatting,
+// comments, and conditions.
+func largeDocument() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\"items_game\"\n{\n\t\"items\"\n\t{\n")
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&buf, "\t\t// item %d\n", i)
+		fmt.Fprintf(&buf, "\t\t\"%d\"\n\t\t{\n", i)
+		fmt.Fprintf(&buf, "\t\t\t\"name\"\t\t\"Item %d\"\n", i)
+		fmt.Fprintf(&buf, "\t\t\t\"prefab\"\t\t\"weapon_%d\"\n", i%17)
+		fmt.Fprintf(&buf, "\t\t\t\"item_description\"\t\t\"#Item_Desc_%d\"\n", i)
+		buf.WriteString("\t\t\t\"used_by_classes\"\n\t\t\t{\n\t\t\t\t\"scout\"\t\t\"1\"\n\t\t\t\t\"soldier\"\t\t\"1\"\n\t\t\t}\n")
+		buf.WriteString("\t\t\t\"attributes\"\n\t\t\t{\n")
+		fmt.Fprintf(&buf, "\t\t\t\t\"damage bonus\"\n\t\t\t\t{\n\t\t\t\t\t\"attribute_class\"\t\"mult_dmg\"\n\t\t\t\t\t\"value\"\t\"%d.5\"\n\t\t\t\t}\n", i%3)
+		buf.WriteString("\t\t\t}\n")
+		buf.WriteString("\t\t\t\"model_player\"\t\"models/weapons/w_models/w_item.mdl\" [$WIN32]\n")
+		buf.WriteString("\t\t}\n")
+	}
+	buf.WriteString("\t}\n}\n")
+	return buf.Bytes()
+}
+
+func TestParseTextLarge(t *testing.T) {
+	t.Parallel()
+
+	checkParseText(t, largeDocument(), nil)
+}
+
+func BenchmarkDecodeText(b *testing.B) {
+	in := largeDocument()
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := vdf.DecodeText(in, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseText(b *testing.B) {
+	in := largeDocument()
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := vdf.ParseText(in, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
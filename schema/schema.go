@@ -0,0 +1,204 @@
+// Package schema describes the expected structure of VDF documents and
+// checks documents against it.
+//
+// Schemas are written in VDF. The root key of a schema describes the root
+// key of a document, and each key description is a subtree that may contain:
+//
+//	"type"        the type of the value: "string", "int", "float",
+//	              "color", "uint64", "enum", "subtree", or "any"; the
+//	              default is "subtree" if "keys" or "open" is present
+//	              and "string" otherwise
+//	"required"    "1" if the key must be present
+//	"repeated"    "1" if the key may appear more than once
+//	"values"      for "enum", a subtree whose values are the allowed strings
+//	"pattern"     a regular expression; the description applies to every
+//	              key whose whole name matches, rather than to the key with
+//	              the same name as the description
+//	"keys"        for "subtree", descriptions of the children
+//	"open"        "1" if a subtree may have children that are not described
+//	"description" documentation, which is ignored
+//
+// Inside "keys", a key whose value is a type name rather than a subtree is
+// short for a description containing only that type.
+//
+// For example:
+//
+//	"Scheme"
+//	{
+//		"keys"
+//		{
+//			"Colors"
+//			{
+//				"required" "1"
+//				"open"     "1"
+//			}
+//			"BaseSettings"
+//			{
+//				"keys"
+//				{
+//					"Border.Width" "int"
+//					"color"
+//					{
+//						"pattern"  "[A-Za-z_.]+"
+//						"type"     "color"
+//					}
+//				}
+//			}
+//		}
+//	}
+//
+// Key names are matched without regard to case, as they are by FirstByName.
+// Patterns are case-sensitive unless they start with (?i). Conditions are
+// ignored.
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/BenLubar/vdf"
+)
+
+// Type is the type of value a key must have.
+type Type int
+
+const (
+	TypeString Type = iota
+	TypeInt
+	TypeFloat
+	TypeColor
+	TypeUint64
+	TypeEnum
+	TypeSubtree
+	TypeAny
+)
+
+var typeNames = [...]string{
+	TypeString:  "string",
+	TypeInt:     "int",
+	TypeFloat:   "float",
+	TypeColor:   "color",
+	TypeUint64:  "uint64",
+	TypeEnum:    "enum",
+	TypeSubtree: "subtree",
+	TypeAny:     "any",
+}
+
+func (t Type) String() string {
+	if int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// Key describes a key in a document.
+type Key struct {
+	// Name is the name of the key. It is ignored if Pattern is set.
+	Name string
+	// Pattern, if not nil, matches the names of the keys this description
+	// applies to. Parse anchors patterns so that they must match the whole
+	// name.
+	Pattern *regexp.Regexp
+
+	Type     Type
+	Required bool
+	Repeated bool
+	// Values are the allowed values of an enum.
+	Values []string
+	// Keys describe the children of a subtree, and Open allows children
+	// that are not described.
+	Keys []*Key
+	Open bool
+}
+
+// Schema describes a document.
+type Schema struct {
+	Root *Key
+}
+
+// Parse reads a schema from its VDF form.
+func Parse(n *vdf.Node) (*Schema, error) {
+	root, err := parseKey(n, n.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{Root: root}, nil
+}
+
+func parseKey(n *vdf.Node, path string) (*Key, error) {
+	k := &Key{Name: n.Name(), Type: TypeString}
+	typeSet, keysSet := false, false
+
+	if n.FirstChild() == nil && n.String() != "" {
+		t, ok := parseType(n.String())
+		if !ok {
+			return nil, fmt.Errorf("schema: %s: unknown type %q", path, n.String())
+		}
+		k.Type = t
+		return k, nil
+	}
+
+	for c := n.FirstChild(); c != nil; c = c.NextChild() {
+		cpath := path + "/" + c.Name()
+		switch strings.ToLower(c.Name()) {
+		case "type":
+			t, ok := parseType(c.String())
+			if !ok {
+				return nil, fmt.Errorf("schema: %s: unknown type %q", cpath, c.String())
+			}
+			k.Type = t
+			typeSet = true
+		case "required":
+			k.Required = c.Int() != 0
+		case "repeated":
+			k.Repeated = c.Int() != 0
+		case "open":
+			k.Open = c.Int() != 0
+			keysSet = true
+		case "values":
+			for v := c.FirstValue(); v != nil; v = v.NextValue() {
+				k.Values = append(k.Values, v.String())
+			}
+		case "pattern":
+			re, err := regexp.Compile("^(?:" + c.String() + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("schema: %s: %v", cpath, err)
+			}
+			k.Pattern = re
+		case "keys":
+			for ck := c.FirstChild(); ck != nil; ck = ck.NextChild() {
+				child, err := parseKey(ck, cpath+"/"+ck.Name())
+				if err != nil {
+					return nil, err
+				}
+				k.Keys = append(k.Keys, child)
+			}
+			keysSet = true
+		case "description":
+		default:
+			return nil, fmt.Errorf("schema: %s: unknown property %q", cpath, c.Name())
+		}
+	}
+
+	if keysSet && !typeSet {
+		k.Type = TypeSubtree
+	}
+	if k.Type == TypeEnum && len(k.Values) == 0 {
+		return nil, fmt.Errorf("schema: %s: enum has no values", path)
+	}
+	if k.Type != TypeSubtree && (len(k.Keys) != 0 || k.Open) {
+		return nil, fmt.Errorf("schema: %s: only subtrees can have keys", path)
+	}
+
+	return k, nil
+}
+
+func parseType(s string) (Type, bool) {
+	for i, name := range typeNames {
+		if strings.EqualFold(name, s) {
+			return Type(i), true
+		}
+	}
+	return 0, false
+}
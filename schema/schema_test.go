@@ -0,0 +1,182 @@
+package schema_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+	"github.com/BenLubar/vdf/schema"
+)
+
+func loadSchema(t *testing.T) *schema.Schema {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "stats.vdf"))
+	if err != nil {
+		t.Fatal("couldn't read schema: ", err)
+	}
+	var n vdf.Node
+	if err = n.UnmarshalText(b); err != nil {
+		t.Fatal("couldn't parse schema: ", err)
+	}
+	s, err := schema.Parse(&n)
+	if err != nil {
+		t.Fatal("invalid schema: ", err)
+	}
+	return s
+}
+
+func TestValidate(t *testing.T) {
+	s := loadSchema(t)
+
+	b, err := ioutil.ReadFile(filepath.Join("..", "testdata", "UserGameStatsSchema_630.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n vdf.Node
+	if err = n.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if errs := s.Validate(&n); errs != nil {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	s := loadSchema(t)
+
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "in_invalid.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n vdf.Node
+	if err = n.UnmarshalText(b); err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, e := range s.Validate(&n) {
+		actual = append(actual, e.Error())
+	}
+	expected := []string{
+		`630/stats/1/type: expected one of "1", "2", "3", "4", found "5"`,
+		`630/stats/1/id: expected int, found "one"`,
+		`630/stats/1/NAME: duplicate key`,
+		`630/stats/1/display: expected subtree, found "x"`,
+		`630/stats/1/extra: unexpected key`,
+		`630/stats/2: missing required key "id"`,
+		`630/stats/x: unexpected key`,
+		`630/version: expected int, found subtree`,
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %q", expected)
+		t.Errorf("actual   %q", actual)
+	}
+}
+
+func TestValidateRoots(t *testing.T) {
+	s := loadSchema(t)
+
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte("\"630\" { \"version\" \"1\" \"stats\" {} }\n\"631\" { \"version\" \"x\" \"stats\" {} }\n\"other\" { \"version\" \"2\" \"stats\" {} }\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, e := range s.Validate(&n) {
+		actual = append(actual, e.Error())
+	}
+	expected := []string{
+		`631/version: expected int, found "x"`,
+		`other: root key should be "UserGameStatsSchema"`,
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %q", expected)
+		t.Errorf("actual   %q", actual)
+	}
+}
+
+func TestValidateEmptyString(t *testing.T) {
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte(`"k" { "type" "string" }`)); err != nil {
+		t.Fatal(err)
+	}
+	s, err := schema.Parse(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		in       string
+		expected int
+	}{
+		{`"k" ""`, 0},
+		{`"k" {}`, 1},
+	} {
+		var d vdf.Node
+		if err = d.UnmarshalText([]byte(tt.in)); err != nil {
+			t.Fatal(err)
+		}
+		if errs := s.Validate(&d); len(errs) != tt.expected {
+			t.Errorf("%s: expected %d errors, got %v", tt.in, tt.expected, errs)
+		}
+	}
+}
+
+func TestTypes(t *testing.T) {
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte(`"root" {
+		"keys" {
+			"i" "int"
+			"f" "float"
+			"c" "color"
+			"u" "uint64"
+			"a" "any"
+			"r" { "type" "string" "repeated" "1" "required" "1" }
+			"p" { "pattern" "(?i)x[0-9]" "required" "1" }
+		}
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+	s, err := schema.Parse(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		in       string
+		expected int
+	}{
+		{`"root" { "i" "-3" "f" "1.5" "c" "255 0 0" "u" "18446744073709551615" "a" {} "r" "1" "r" "2" "X1" "" }`, 0},
+		{`"root" { "i" "1.5" "f" "x" "c" "256 0 0" "u" "-1" "r" "" "x1" "" }`, 4},
+		{`"root" { "c" "1 2" }`, 3},
+		{`"other" { "r" "" "x2" "" "x3" "" }`, 1},
+	} {
+		var d vdf.Node
+		if err = d.UnmarshalText([]byte(tt.in)); err != nil {
+			t.Fatal(err)
+		}
+		if errs := s.Validate(&d); len(errs) != tt.expected {
+			t.Errorf("%s: expected %d errors, got %v", tt.in, tt.expected, errs)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		`"root" { "type" "number" }`,
+		`"root" { "type" "enum" }`,
+		`"root" { "type" "int" "keys" { "a" "string" } }`,
+		`"root" { "pattern" "(" }`,
+		`"root" { "keys" { "a" "bogus" } }`,
+		`"root" { "unknown" "1" }`,
+	} {
+		var n vdf.Node
+		if err := n.UnmarshalText([]byte(in)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := schema.Parse(&n); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
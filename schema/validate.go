@@ -0,0 +1,162 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BenLubar/vdf"
+)
+
+// Error is a way in which a document does not match a schema.
+type Error struct {
+	// Path is the names of the keys from the root of the document to the
+	// key with the problem, separated by slashes.
+	Path string
+	Msg  string
+}
+
+func (err Error) Error() string {
+	return err.Path + ": " + err.Msg
+}
+
+// Validate checks the document starting at n against s and returns every
+// problem it finds, in document order. Each root key, n and the roots after
+// it, is checked against the root of s. It returns nil if every root matches
+// s.
+func (s *Schema) Validate(n *vdf.Node) []Error {
+	var errs []Error
+	for ; n != nil; n = n.NextChild() {
+		if !s.Root.matches(n.Name()) {
+			errs = append(errs, Error{Path: n.Name(), Msg: fmt.Sprintf("root key should be %q", s.Root.Name)})
+		}
+
+		errs = s.Root.check(errs, n, n.Name())
+	}
+	return errs
+}
+
+// matches reports whether k describes keys with the given name.
+func (k *Key) matches(name string) bool {
+	if k.Pattern != nil {
+		return k.Pattern.MatchString(name)
+	}
+	return strings.EqualFold(k.Name, name)
+}
+
+// find returns the description of the child with the given name. Names
+// take precedence over patterns.
+func (k *Key) find(name string) *Key {
+	for _, c := range k.Keys {
+		if c.Pattern == nil && c.matches(name) {
+			return c
+		}
+	}
+	for _, c := range k.Keys {
+		if c.Pattern != nil && c.matches(name) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (k *Key) check(errs []Error, n *vdf.Node, path string) []Error {
+	if k.Type == TypeAny {
+		return errs
+	}
+
+	isSubtree := n.Kind() == vdf.KindSubtree
+	if k.Type == TypeSubtree {
+		if !isSubtree {
+			return append(errs, Error{Path: path, Msg: fmt.Sprintf("expected subtree, found %q", n.String())})
+		}
+		return k.checkChildren(errs, n, path)
+	}
+
+	if isSubtree {
+		return append(errs, Error{Path: path, Msg: fmt.Sprintf("expected %v, found subtree", k.Type)})
+	}
+	if !k.valid(n.String()) {
+		expected := k.Type.String()
+		if k.Type == TypeEnum {
+			quoted := make([]string, len(k.Values))
+			for i, v := range k.Values {
+				quoted[i] = strconv.Quote(v)
+			}
+			expected = "one of " + strings.Join(quoted, ", ")
+		}
+		return append(errs, Error{Path: path, Msg: fmt.Sprintf("expected %s, found %q", expected, n.String())})
+	}
+	return errs
+}
+
+func (k *Key) checkChildren(errs []Error, n *vdf.Node, path string) []Error {
+	counts := make(map[*Key]int)
+	seen := make(map[string]bool)
+
+	for c := n.FirstChild(); c != nil; c = c.NextChild() {
+		cpath := path + "/" + c.Name()
+		ck := k.find(c.Name())
+		if ck == nil {
+			if !k.Open {
+				errs = append(errs, Error{Path: cpath, Msg: "unexpected key"})
+			}
+			continue
+		}
+
+		counts[ck]++
+		name := strings.ToLower(c.Name())
+		if seen[name] && !ck.Repeated {
+			errs = append(errs, Error{Path: cpath, Msg: "duplicate key"})
+		}
+		seen[name] = true
+
+		errs = ck.check(errs, c, cpath)
+	}
+
+	for _, ck := range k.Keys {
+		if !ck.Required || counts[ck] != 0 {
+			continue
+		}
+		if ck.Pattern != nil {
+			errs = append(errs, Error{Path: path, Msg: fmt.Sprintf("missing key matching %s", ck.Pattern)})
+		} else {
+			errs = append(errs, Error{Path: path, Msg: fmt.Sprintf("missing required key %q", ck.Name)})
+		}
+	}
+
+	return errs
+}
+
+// valid reports whether s is a valid value for k, which is not a subtree.
+func (k *Key) valid(s string) bool {
+	var err error
+	switch k.Type {
+	case TypeString:
+	case TypeInt:
+		_, err = strconv.ParseInt(s, 10, 32)
+	case TypeFloat:
+		_, err = strconv.ParseFloat(s, 32)
+	case TypeUint64:
+		_, err = strconv.ParseUint(s, 10, 64)
+	case TypeColor:
+		return validColor(s)
+	case TypeEnum:
+		for _, v := range k.Values {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+	return err == nil
+}
+
+// validColor reports whether s is a color in one of the forms accepted by
+// Node.Color.
+func validColor(s string) bool {
+	var n vdf.Node
+	n.SetString(s)
+	_, err := n.ColorE()
+	return err == nil
+}
@@ -0,0 +1,185 @@
+package vdf
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Symbol identifies a key name in a SymbolTable. Names that are equal
+// ignoring case, as compared by strings.EqualFold, have the same Symbol.
+// The zero Symbol is not used by any name.
+type Symbol uint32
+
+// SymbolTable interns key names, like KeyValuesSystem in the Source engine.
+// If DecodeOptions.Symbols is set, each decoded Node shares its name with
+// every other Node decoded using the same SymbolTable that has the same
+// name, and FirstByName and NextByName compare names by their Symbol.
+//
+// The zero value of SymbolTable is an empty table ready to use. A
+// SymbolTable is safe to use from multiple goroutines at the same time, so
+// it can be shared by any number of decoders. Names are never removed from
+// a SymbolTable.
+type SymbolTable struct {
+	mu      sync.RWMutex
+	exact   map[string]interned
+	folded  map[string]*symbol
+	symbols []*symbol
+}
+
+// interned is a name as it was spelled in the input.
+type interned struct {
+	name string
+	sym  *symbol
+}
+
+// symbol is shared by the Nodes with names that have the same Symbol.
+type symbol struct {
+	id    Symbol
+	name  string // the first spelling of the name
+	table *SymbolTable
+}
+
+// Symbol returns the Symbol for name, adding it to the table if needed.
+func (t *SymbolTable) Symbol(name string) Symbol {
+	_, sym := t.intern(name)
+	return sym.id
+}
+
+// Name returns the first spelling of the name that was added to the table
+// with Symbol s, or an empty string if there is no such Symbol.
+func (t *SymbolTable) Name(s Symbol) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if s == 0 || int(s) > len(t.symbols) {
+		return ""
+	}
+	return t.symbols[s-1].name
+}
+
+// intern returns the shared copy of name and its symbol.
+func (t *SymbolTable) intern(name string) (string, *symbol) {
+	t.mu.RLock()
+	e, ok := t.exact[name]
+	t.mu.RUnlock()
+	if ok {
+		return e.name, e.sym
+	}
+
+	key := foldKey(name)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e, ok = t.exact[name]; ok {
+		return e.name, e.sym
+	}
+	if t.exact == nil {
+		t.exact = make(map[string]interned)
+		t.folded = make(map[string]*symbol)
+	}
+	sym := t.folded[key]
+	if sym == nil {
+		sym = &symbol{id: Symbol(len(t.symbols) + 1), name: name, table: t}
+		t.symbols = append(t.symbols, sym)
+		t.folded[key] = sym
+	}
+	t.exact[name] = interned{name: name, sym: sym}
+	return name, sym
+}
+
+// internBytes is like intern, but does not allocate if the name is
+// already in the table.
+func (t *SymbolTable) internBytes(b []byte) (string, *symbol) {
+	t.mu.RLock()
+	e, ok := t.exact[string(b)]
+	t.mu.RUnlock()
+	if ok {
+		return e.name, e.sym
+	}
+	return t.intern(string(b))
+}
+
+// lookup returns the symbol for name, or nil if it is not in the table.
+func (t *SymbolTable) lookup(name string) *symbol {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if e, ok := t.exact[name]; ok {
+		return e.sym
+	}
+	return t.folded[foldKey(name)]
+}
+
+// foldKey returns a string that is the same for two names if and only if
+// strings.EqualFold reports that they are equal, by replacing each rune
+// with the smallest rune that it is equal to ignoring case.
+func foldKey(s string) string {
+	return string(appendFoldKey(nil, s))
+}
+
+// appendFoldKey appends foldKey(s) to b.
+func appendFoldKey(b []byte, s string) []byte {
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			// The smallest rune equal to an ASCII letter is its upper case.
+			if 'a' <= r && r <= 'z' {
+				r -= 'a' - 'A'
+			}
+			b = append(b, byte(r))
+			continue
+		}
+
+		min := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < min {
+				min = f
+			}
+		}
+		var buf [utf8.UTFMax]byte
+		b = append(b, buf[:utf8.EncodeRune(buf[:], min)]...)
+	}
+	return b
+}
+
+// Symbol returns the Symbol of the name of this Node in the SymbolTable
+// used to decode it, or 0 if the Node was not decoded with a SymbolTable or
+// has been renamed since.
+//
+// Symbol is an accessor.
+func (n *Node) Symbol() Symbol {
+	if sym := n.notNil().sym; sym != nil {
+		return sym.id
+	}
+	return 0
+}
+
+// nameMatcher compares the names of Nodes with a name, using their symbols
+// if they have them.
+type nameMatcher struct {
+	name  string
+	table *SymbolTable
+	sym   *symbol
+}
+
+func (m *nameMatcher) match(n *Node) bool {
+	if n.sym == nil {
+		return strings.EqualFold(n.name, m.name)
+	}
+	if n.sym.table != m.table {
+		m.table = n.sym.table
+		m.sym = m.table.lookup(m.name)
+	}
+	return n.sym == m.sym
+}
+
+// internName returns the name to use for a decoded Node, and its symbol if
+// the decoder has a SymbolTable.
+func (d *decodeState) internName(name string) (string, *symbol) {
+	if d.opts.Symbols == nil {
+		return name, nil
+	}
+	return d.opts.Symbols.intern(name)
+}
@@ -0,0 +1,148 @@
+package vdf_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+func TestSymbolTable(t *testing.T) {
+	t.Parallel()
+
+	var table vdf.SymbolTable
+	opts := &vdf.DecodeOptions{Symbols: &table}
+
+	text, err := vdf.DecodeText([]byte(`"root" { "Name" "a" "sub" { "NAME" "b" } "other" "c" }`), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := vdf.ParseText([]byte(`"ROOT" { "name" "a" }`), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary, err := vdf.DecodeBinary([]byte("\x00root\x00\x01naME\x00a\x00\x08\x08"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := text.FirstByName("name").Symbol()
+	if name == 0 {
+		t.Fatal("decoded node has no symbol")
+	}
+	for _, n := range []*vdf.Node{
+		text.FirstByName("sub").FirstByName("name"),
+		parsed.FirstByName("name"),
+		binary.FirstByName("name"),
+	} {
+		if n.Symbol() != name {
+			t.Errorf("%q: expected symbol %d, got %d", n.Name(), name, n.Symbol())
+		}
+	}
+	if text.Symbol() != parsed.Symbol() || text.Symbol() == name || text.FirstByName("other").Symbol() == name {
+		t.Error("unexpected symbols for different names")
+	}
+	if table.Symbol("nAmE") != name || table.Name(name) != "Name" || table.Name(0) != "" || table.Name(1000) != "" {
+		t.Error("unexpected result from symbol table")
+	}
+
+	// Names keep their spelling.
+	if binary.FirstChild().Name() != "naME" {
+		t.Errorf("expected %q, got %q", "naME", binary.FirstChild().Name())
+	}
+
+	// Renaming a node removes its symbol.
+	n := text.FirstByName("other")
+	n.SetName("name")
+	if n.Symbol() != 0 {
+		t.Error("renamed node kept its symbol")
+	}
+	if text.FirstByName("name").NextByName("NAME") != n {
+		t.Error("NextByName did not find node without a symbol")
+	}
+	if text.FirstByName("missing") != nil {
+		t.Error("FirstByName found a missing name")
+	}
+
+	var plain vdf.Node
+	if err = plain.UnmarshalText([]byte(`"name" "a"`)); err != nil {
+		t.Fatal(err)
+	}
+	if plain.Symbol() != 0 {
+		t.Error("node decoded without a symbol table has a symbol")
+	}
+}
+
+func TestSymbolTableFold(t *testing.T) {
+	t.Parallel()
+
+	names := []string{"key", "KEY", "Key", "sſs", "SSS", "été", "ÉTÉ", "ete", "k"}
+
+	var table vdf.SymbolTable
+	for _, a := range names {
+		for _, b := range names {
+			equal := table.Symbol(a) == table.Symbol(b)
+			if equal != strings.EqualFold(a, b) {
+				t.Errorf("%q and %q: symbols equal is %v", a, b, equal)
+			}
+		}
+	}
+}
+
+func TestSymbolTableConcurrent(t *testing.T) {
+	t.Parallel()
+
+	var table vdf.SymbolTable
+	opts := &vdf.DecodeOptions{Symbols: &table}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := vdf.DecodeText([]byte(`"a" { "b" "1" "B" "2" "c" { "d" "3" } }`), opts); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if table.Name(table.Symbol("D")) != "d" || table.Symbol("b") != table.Symbol("B") {
+		t.Error("unexpected result from symbol table")
+	}
+}
+
+func benchmarkFirstByName(b *testing.B, opts *vdf.DecodeOptions) {
+	var doc strings.Builder
+	doc.WriteString("\"root\" {\n")
+	for i := 0; i < 100; i++ {
+		doc.WriteString("\t\"SomeLongerKeyName\" \"x\"\n")
+	}
+	doc.WriteString("\t\"Target\" \"y\"\n}\n")
+
+	n, err := vdf.DecodeText([]byte(doc.String()), opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if n.FirstByName("target") == nil {
+			b.Fatal("not found")
+		}
+	}
+}
+
+func BenchmarkFirstByName(b *testing.B) {
+	b.Run("Plain", func(b *testing.B) {
+		benchmarkFirstByName(b, nil)
+	})
+	b.Run("Symbols", func(b *testing.B) {
+		benchmarkFirstByName(b, &vdf.DecodeOptions{Symbols: new(vdf.SymbolTable)})
+	})
+}
@@ -4,33 +4,72 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"image/color"
 	"io"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 var escapeString = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\t", "\\t", "\v", "\\v", "\b", "\\b", "\r", "\\r", "\f", "\\f", "\a", "\\a", "'", "\\'", "\"", "\\\"")
 var unescapeString = strings.NewReplacer("\\\\", "\\", "\\n", "\n", "\\t", "\t", "\\v", "\v", "\\b", "\b", "\\r", "\r", "\\f", "\f", "\\a", "\a", "\\'", "'", "\\\"", "\"")
 
 func (n *Node) MarshalText() ([]byte, error) {
+	return EncodeText(n, nil)
+}
+
+// EncodeText is like MarshalText, but with configurable behavior.
+func EncodeText(n *Node, opts *EncodeOptions) ([]byte, error) {
 	if n == nil {
 		return nil, nil
 	}
 
+	o := opts.orDefault()
+	width := 0
+	if o.AlignValues {
+		if n.parent == nil {
+			width = o.keyWidth(n)
+		} else {
+			width = o.keyWidth(&Node{name: n.name, value: n.value})
+		}
+	}
+
 	var buf bytes.Buffer
-	if err := n.writeIndent(&buf, 0); err != nil {
+	if err := n.writeIndent(&buf, 0, o, width); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
 
-func (n *Node) writeIndent(w io.Writer, indent int) error {
+// keyWidth returns the width of the widest quoted key among first and its
+// following siblings that have a value and use standard formatting.
+func (o *EncodeOptions) keyWidth(first *Node) int {
+	width := 0
+	for c := first; c != nil; c = c.next {
+		if c.value == nil || c.cf != nil {
+			continue
+		}
+		if w := utf8.RuneCountInString(o.escape(c.name)) + 2; w > width {
+			width = w
+		}
+	}
+	return width
+}
+
+func (o *EncodeOptions) escape(s string) string {
+	if o.DisableEscapes {
+		return s
+	}
+	return escapeString.Replace(s)
+}
+
+func (n *Node) writeIndent(w io.Writer, indent int, o *EncodeOptions, width int) error {
 	for c := n; c != nil; c = c.NextChild() {
 		var err error
 		if c.cf != nil {
-			err = c.writeCustom(w, indent)
+			err = c.writeCustom(w, indent, o)
 		} else {
-			err = c.writeDefault(w, indent)
+			err = c.writeDefault(w, indent, o, width)
 		}
 		if err != nil {
 			return err
@@ -42,34 +81,50 @@ func (n *Node) writeIndent(w io.Writer, indent int) error {
 	return nil
 }
 
-func (n *Node) writeDefault(w io.Writer, indent int) error {
-	if _, err := io.WriteString(w, strings.Repeat("\t", indent)); err != nil {
+func (n *Node) writeDefault(w io.Writer, indent int, o *EncodeOptions, width int) error {
+	if leading, _ := n.Comments(); leading != "" {
+		var b strings.Builder
+		writeComment(&b, o.indent(indent), leading)
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, o.indent(indent)); err != nil {
 		return err
 	}
-	if err := writeString(w, n.name); err != nil {
+	if err := writeString(w, n.name, o); err != nil {
 		return err
 	}
 	if _, err := io.WriteString(w, " "); err != nil {
 		return err
 	}
 	if n.value != nil {
-		return n.writeValue(w)
+		if pad := width - utf8.RuneCountInString(o.escape(n.name)) - 2; pad > 0 {
+			if _, err := io.WriteString(w, strings.Repeat(" ", pad)); err != nil {
+				return err
+			}
+		}
+		return n.writeValue(w, o)
 	}
-	return n.writeIndentChildren(w, indent)
+	return n.writeIndentChildren(w, indent, o)
 }
 
-func (n *Node) writeCustom(w io.Writer, indent int) error {
+func (n *Node) writeCustom(w io.Writer, indent int, o *EncodeOptions) error {
 	if _, err := io.WriteString(w, n.cf.before); err != nil {
 		return err
 	}
-	if err := writePossiblyQuoted(w, n.name, n.cf.unquotedKey); err != nil {
+	if err := writePossiblyQuoted(w, n.name, n.cf.unquotedKey, o); err != nil {
 		return err
 	}
 	if n.value != nil {
 		if _, err := io.WriteString(w, n.cf.between); err != nil {
 			return err
 		}
-		if err := writePossiblyQuoted(w, n.String(), n.cf.unquotedValue); err != nil {
+		value := n.String()
+		if c, ok := n.value.(color.NRGBA); ok && !o.TypeAnnotations {
+			value = formatColor(c, n.cf.colorStyle)
+		}
+		if err := writePossiblyQuoted(w, value, n.cf.unquotedValue, o); err != nil {
 			return err
 		}
 		if _, err := io.WriteString(w, n.cf.condition); err != nil {
@@ -80,7 +135,7 @@ func (n *Node) writeCustom(w io.Writer, indent int) error {
 				return err
 			}
 		}
-		_, err := io.WriteString(w, n.cf.after)
+		_, err := io.WriteString(w, n.lineEnding(o))
 		return err
 	}
 	if _, err := io.WriteString(w, n.cf.condition); err != nil {
@@ -94,17 +149,28 @@ func (n *Node) writeCustom(w io.Writer, indent int) error {
 	if _, err := io.WriteString(w, n.cf.between); err != nil {
 		return err
 	}
+	if err := n.writeChildren(w, indent, o); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, n.cf.after)
+	return err
+}
+
+func (n *Node) writeChildren(w io.Writer, indent int, o *EncodeOptions) error {
+	width := 0
+	if o.AlignValues {
+		width = o.keyWidth(n.FirstChild())
+	}
 	for c := n.FirstChild(); c != nil; c = c.NextChild() {
-		if err := c.writeIndent(w, indent+1); err != nil {
+		if err := c.writeIndent(w, indent+1, o, width); err != nil {
 			return err
 		}
 	}
-	_, err := io.WriteString(w, n.cf.after)
-	return err
+	return nil
 }
 
-func (n *Node) writeValue(w io.Writer) error {
-	if err := writeString(w, n.String()); err != nil {
+func (n *Node) writeValue(w io.Writer, o *EncodeOptions) error {
+	if err := writeString(w, n.String(), o); err != nil {
 		return err
 	}
 	if n.condition != "" {
@@ -112,13 +178,69 @@ func (n *Node) writeValue(w io.Writer) error {
 			return err
 		}
 	}
-	if _, err := io.WriteString(w, "\n"); err != nil {
-		return err
+	return n.writeTrailingComment(w, o)
+}
+
+// writeTrailingComment ends the last line of a Node written using standard
+// formatting.
+func (n *Node) writeTrailingComment(w io.Writer, o *EncodeOptions) error {
+	_, trailing := n.Comments()
+	if o.TypeAnnotations {
+		if a := n.annotation(); a != "" {
+			trailing = strings.TrimSuffix(a+" "+trailing, " ")
+			if _, err := io.WriteString(w, " //"+trailing+"\n"); err != nil {
+				return err
+			}
+			return nil
+		}
 	}
-	return nil
+	if trailing != "" {
+		if _, err := io.WriteString(w, " // "+trailing); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
 }
 
-func (n *Node) writeIndentChildren(w io.Writer, indent int) error {
+// lineEnding returns the recorded formatting after a value, with its type
+// annotation updated if they are enabled.
+func (n *Node) lineEnding(o *EncodeOptions) string {
+	if !o.TypeAnnotations {
+		return n.cf.after
+	}
+
+	space, old, rest := splitLineEnding(n.cf.after)
+	var text string
+	if strings.HasPrefix(old, "//") {
+		text = commentText(old)
+	} else if old != "" {
+		text = old
+	}
+	_, text, annotated := splitAnnotation(text)
+	a := n.annotation()
+	if a == "" && !annotated {
+		return n.cf.after
+	}
+
+	switch {
+	case a != "":
+		old = strings.TrimSuffix("//"+a+" "+text, " ")
+	case text != "":
+		old = "// " + text
+	default:
+		return rest
+	}
+	if space == "" {
+		space = " "
+	}
+	if rest == "" {
+		rest = "\n"
+	}
+	return space + old + rest
+}
+
+func (n *Node) writeIndentChildren(w io.Writer, indent int, o *EncodeOptions) error {
 	if n.condition != "" {
 		if _, err := fmt.Fprintf(w, "[%s] ", n.condition); err != nil {
 			return err
@@ -127,33 +249,30 @@ func (n *Node) writeIndentChildren(w io.Writer, indent int) error {
 	if _, err := io.WriteString(w, "{\n"); err != nil {
 		return err
 	}
-	for c := n.FirstChild(); c != nil; c = c.NextChild() {
-		if err := c.writeIndent(w, indent+1); err != nil {
-			return err
-		}
+	if err := n.writeChildren(w, indent, o); err != nil {
+		return err
 	}
-	if _, err := io.WriteString(w, strings.Repeat("\t", indent)); err != nil {
+	if _, err := io.WriteString(w, o.indent(indent)+"}"); err != nil {
 		return err
 	}
-	_, err := io.WriteString(w, "}\n")
-	return err
+	return n.writeTrailingComment(w, o)
 }
 
-func writePossiblyQuoted(w io.Writer, s string, unquoted bool) error {
+func writePossiblyQuoted(w io.Writer, s string, unquoted bool, o *EncodeOptions) error {
 	var err error
-	if unquoted {
+	if unquoted && !o.ForceQuotes {
 		_, err = io.WriteString(w, s)
 	} else {
-		err = writeString(w, s)
+		err = writeString(w, s, o)
 	}
 	return err
 }
 
-func writeString(w io.Writer, s string) error {
+func writeString(w io.Writer, s string, o *EncodeOptions) error {
 	if _, err := io.WriteString(w, "\""); err != nil {
 		return err
 	}
-	if _, err := escapeString.WriteString(w, s); err != nil {
+	if _, err := io.WriteString(w, o.escape(s)); err != nil {
 		return err
 	}
 	if _, err := io.WriteString(w, "\""); err != nil {
@@ -170,13 +289,29 @@ func (errClose) Error() string {
 
 func (n *Node) UnmarshalText(b []byte) error {
 	*n = Node{}
-	return n.readAsText(bufio.NewReader(bytes.NewReader(b)))
+	return n.readAsText(bufio.NewReader(bytes.NewReader(b)), newDecodeState(nil))
+}
+
+// DecodeText is like UnmarshalText, but with configurable behavior.
+func DecodeText(b []byte, opts *DecodeOptions) (*Node, error) {
+	d := newDecodeState(opts)
+	n := new(Node)
+	if err := n.readAsText(bufio.NewReader(d.limitReader(bytes.NewReader(b))), d); err != nil {
+		return nil, err
+	}
+	if d.opts.InferTypes != nil {
+		if err := n.InferTypes(d.opts.InferTypes); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
 }
 
-func (n *Node) readAsText(r *bufio.Reader) error {
+func (n *Node) readAsText(r *bufio.Reader, d *decodeState) error {
 	var last *Node
+	var seen map[string]*Node
 	current := n
-	prefix, s, wasQuoted, wasConditional, err := readToken(r)
+	prefix, s, wasQuoted, wasConditional, err := d.readToken(r)
 	if err != nil {
 		return err
 	}
@@ -196,18 +331,22 @@ func (n *Node) readAsText(r *bufio.Reader) error {
 			current.prev = last
 			last.next = current
 		}
+		if err = d.addNode(); err != nil {
+			return err
+		}
 		current.cf = new(customFormat)
 		current.cf.before = prefix
 		current.cf.unquotedKey = !wasQuoted
-		current.name = s
-		prefix, s, wasQuoted, wasConditional, err = readToken(r)
+		leading := leadingComment(prefix)
+		current.name, current.sym = d.internName(s)
+		prefix, s, wasQuoted, wasConditional, err = d.readToken(r)
 		if err != nil {
 			return err
 		}
 		if wasConditional {
 			current.cf.condition = prefix
 			current.condition = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
-			prefix, s, wasQuoted, wasConditional, err = readToken(r)
+			prefix, s, wasQuoted, wasConditional, err = d.readToken(r)
 			if err != nil {
 				return err
 			}
@@ -216,17 +355,21 @@ func (n *Node) readAsText(r *bufio.Reader) error {
 			}
 		}
 
+		atEOF := false
 		if !wasQuoted && s == "{" {
 			var suffix string
-			suffix, err = readLineEnding(r)
+			suffix, err = d.readLineEnding(r)
 			if err != nil {
 				return err
 			}
 			current.cf.between = prefix + s + suffix
 
+			if err = d.push(); err != nil {
+				return err
+			}
 			var c Node
 			c.parent = current
-			err = c.readAsText(r)
+			err = c.readAsText(r, d)
 			if p, ok := err.(errClose); ok {
 				prefix = string(p)
 			} else if err != nil {
@@ -234,20 +377,22 @@ func (n *Node) readAsText(r *bufio.Reader) error {
 			} else {
 				return fmt.Errorf("vdf: missing }")
 			}
+			d.pop()
 			if c.cf != nil {
 				current.child = &c
 			}
 
-			suffix, err = readLineEnding(r)
+			suffix, err = d.readLineEnding(r)
 			if err != nil {
 				return err
 			}
 			current.cf.after = prefix + "}" + suffix
+			current.comment = newComment(leading, trailingComment(suffix))
 
-			prefix, s, wasQuoted, wasConditional, err = readToken(r)
+			prefix, s, wasQuoted, wasConditional, err = d.readToken(r)
 		} else {
 			var suffix string
-			suffix, err = readLineEnding(r)
+			suffix, err = d.readLineEnding(r)
 			if err != nil {
 				return err
 			}
@@ -256,29 +401,52 @@ func (n *Node) readAsText(r *bufio.Reader) error {
 			current.value = s
 			current.cf.after = suffix
 
-			prefix, s, wasQuoted, wasConditional, err = readToken(r)
+			prefix, s, wasQuoted, wasConditional, err = d.readToken(r)
 			if err == io.EOF {
-				return nil
-			}
-			if err != nil {
+				atEOF = true
+			} else if err != nil {
 				return err
-			}
-			if wasConditional {
+			} else if wasConditional {
 				current.cf.condition = suffix + prefix
 				current.condition = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
-				suffix, err = readLineEnding(r)
+				suffix, err = d.readLineEnding(r)
 				if err != nil {
 					return err
 				}
 				current.cf.after = suffix
-				prefix, s, wasQuoted, wasConditional, err = readToken(r)
+				prefix, s, wasQuoted, wasConditional, err = d.readToken(r)
 			}
+			trailing := trailingComment(current.cf.after)
+			if d.opts.TypeAnnotations {
+				if kind, rest, ok := splitAnnotation(trailing); ok {
+					v, perr := parseAnnotated(current.value.(string), kind)
+					if perr != nil {
+						return perr
+					}
+					current.value = v
+					trailing = rest
+				}
+			}
+			current.comment = newComment(leading, trailing)
 		}
 
-		last = current
-		current = nil
+		keep, kerr := d.keep(current, &seen)
+		if kerr != nil {
+			return kerr
+		}
+		if keep {
+			last = current
+			current = nil
+		} else {
+			current = discard(current)
+		}
+		if atEOF {
+			return nil
+		}
 		if err != nil {
-			last.cf.after += prefix
+			if last != nil {
+				last.cf.after += prefix
+			}
 			return eofOK(err)
 		}
 	}
@@ -291,8 +459,15 @@ func eofOK(err error) error {
 	return err
 }
 
-func readToken(r *bufio.Reader) (prefix, s string, wasQuoted, wasConditional bool, err error) {
-	prefix, err = readPrefix(r)
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (d *decodeState) readToken(r *bufio.Reader) (prefix, s string, wasQuoted, wasConditional bool, err error) {
+	prefix, err = d.readPrefix(r)
 	if err != nil {
 		return
 	}
@@ -304,7 +479,7 @@ func readToken(r *bufio.Reader) (prefix, s string, wasQuoted, wasConditional boo
 
 	if c == '"' {
 		wasQuoted = true
-		s, err = readQuoted(r)
+		s, err = d.readQuoted(r)
 		return
 	}
 
@@ -316,6 +491,12 @@ func readToken(r *bufio.Reader) (prefix, s string, wasQuoted, wasConditional boo
 	buf := []byte{c}
 	conditionalStart := c == '['
 	for {
+		if d.opts.BlockComments {
+			if peek, _ := r.Peek(2); len(peek) == 2 && peek[0] == '/' && peek[1] == '*' {
+				break
+			}
+		}
+
 		c, err = r.ReadByte()
 		if err != nil {
 			err = eofOK(err)
@@ -341,13 +522,16 @@ func readToken(r *bufio.Reader) (prefix, s string, wasQuoted, wasConditional boo
 		}
 
 		buf = append(buf, c)
+		if err = d.checkString(len(buf)); err != nil {
+			return
+		}
 	}
 
 	s = string(buf)
 	return
 }
 
-func readPrefix(r *bufio.Reader) (string, error) {
+func (d *decodeState) readPrefix(r *bufio.Reader) (string, error) {
 	var buf []byte
 	var err error
 	for {
@@ -355,7 +539,7 @@ func readPrefix(r *bufio.Reader) (string, error) {
 			break
 		}
 		var foundComment bool
-		if buf, foundComment, err = readComment(r, buf); err != nil || !foundComment {
+		if buf, foundComment, err = d.readComment(r, buf); err != nil || !foundComment {
 			break
 		}
 	}
@@ -378,26 +562,109 @@ func readSpace(r io.ByteScanner, buf []byte) ([]byte, error) {
 	}
 }
 
-func readComment(r *bufio.Reader, buf []byte) ([]byte, bool, error) {
+func (d *decodeState) readComment(r *bufio.Reader, buf []byte) ([]byte, bool, error) {
 	peek, err := r.Peek(2)
 	if err != nil {
 		return buf, false, eofOK(err)
 	}
-	if peek[0] != '/' || peek[1] != '/' {
+	if d.opts.BlockComments && peek[0] == '/' && peek[1] == '*' {
+		buf, err = d.readBlockComment(r, buf)
+		return buf, true, err
+	}
+	marker := d.lineComment(peek[0], peek[1])
+	if marker == "" {
 		return buf, false, nil
 	}
 
-	if _, err = r.Discard(2); err != nil {
+	if _, err = r.Discard(len(marker)); err != nil {
 		return buf, false, err
 	}
-	buf = append(buf, '/', '/')
 
-	line, err := r.ReadSlice('\n')
-	buf = append(buf, line...)
+	line, err := readLine(r)
+	buf = d.appendComment(buf, marker, line)
 	return buf, true, err
 }
 
-func readLineEnding(r *bufio.Reader) (string, error) {
+// lineComment returns the marker at the start of the next two bytes of input
+// if they begin a comment that runs to the end of the line, or an empty
+// string if they do not.
+// A # comment is only recognized with HashComments, and only if the # is not
+// followed by a letter, so #base and #include are still read as keys.
+func (d *decodeState) lineComment(c0, c1 byte) string {
+	if c0 == '/' && c1 == '/' {
+		return "//"
+	}
+	if d.opts.HashComments && c0 == '#' && !('a' <= c1|0x20 && c1|0x20 <= 'z') {
+		return "#"
+	}
+	return ""
+}
+
+// readBlockComment reads a /* */ comment, which may span multiple lines, and
+// appends it to buf. If comments are being discarded, the comment is
+// replaced by the line endings in it, or by a space if there are none, so
+// that the tokens on either side stay separate.
+func (d *decodeState) readBlockComment(r *bufio.Reader, buf []byte) ([]byte, error) {
+	if _, err := r.Discard(2); err != nil {
+		return buf, err
+	}
+
+	comment := []byte("/*")
+	var prev byte
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			return buf, fmt.Errorf("vdf: unterminated block comment")
+		}
+		if err != nil {
+			return buf, err
+		}
+		comment = append(comment, c)
+		if prev == '*' && c == '/' {
+			break
+		}
+		prev = c
+	}
+
+	if !d.opts.DiscardComments {
+		return append(buf, comment...), nil
+	}
+	if lines := bytes.Count(comment, []byte{'\n'}); lines != 0 {
+		return append(buf, bytes.Repeat([]byte{'\n'}, lines)...), nil
+	}
+	return append(buf, ' '), nil
+}
+
+// readLine reads up to and including the next newline.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != bufio.ErrBufferFull {
+		return line, err
+	}
+	line = append([]byte(nil), line...)
+	for err == bufio.ErrBufferFull {
+		var more []byte
+		more, err = r.ReadSlice('\n')
+		line = append(line, more...)
+	}
+	return line, err
+}
+
+// appendComment appends a line comment, given its marker and the remainder
+// of the line after the marker, to buf. If comments are being discarded,
+// only the line ending is kept.
+func (d *decodeState) appendComment(buf []byte, marker string, line []byte) []byte {
+	if d.opts.DiscardComments {
+		if len(line) != 0 && line[len(line)-1] == '\n' {
+			buf = append(buf, '\n')
+		}
+		return buf
+	}
+	buf = append(buf, marker...)
+	return append(buf, line...)
+}
+
+func (d *decodeState) readLineEnding(r *bufio.Reader) (string, error) {
 	var buf []byte
 	for {
 		b, err := r.ReadByte()
@@ -423,41 +690,44 @@ func readLineEnding(r *bufio.Reader) (string, error) {
 	if err != nil {
 		return string(buf), eofOK(err)
 	}
-	if peek[0] != '/' || peek[1] != '/' {
+	marker := d.lineComment(peek[0], peek[1])
+	if marker == "" {
 		return string(buf), nil
 	}
 
-	if _, err = r.Discard(2); err != nil {
+	if _, err = r.Discard(len(marker)); err != nil {
 		return string(buf), err
 	}
-	buf = append(buf, '/', '/')
 
-	line, err := r.ReadSlice('\n')
-	buf = append(buf, line...)
+	line, err := readLine(r)
+	buf = d.appendComment(buf, marker, line)
 	return string(buf), eofOK(err)
 }
 
-func readQuoted(r io.ByteScanner) (string, error) {
+func (d *decodeState) readQuoted(r io.ByteScanner) (string, error) {
 	var buf []byte
 	for {
 		c, err := r.ReadByte()
 		if err != nil {
-			return "", err
+			return "", unexpectedEOF(err)
 		}
 
 		if c == '"' {
 			return string(buf), nil
 		}
 
-		if c == '\\' {
+		if c == '\\' && !d.opts.DisableEscapes {
 			c, err = r.ReadByte()
 			if err != nil {
-				return "", err
+				return "", unexpectedEOF(err)
 			}
 
 			src := "\\" + string(c)
 			if dst := unescapeString.Replace(src); src != dst {
 				buf = append(buf, dst...)
+				if err = d.checkString(len(buf)); err != nil {
+					return "", err
+				}
 				continue
 			}
 
@@ -469,5 +739,8 @@ func readQuoted(r io.ByteScanner) (string, error) {
 		}
 
 		buf = append(buf, c)
+		if err = d.checkString(len(buf)); err != nil {
+			return "", err
+		}
 	}
 }
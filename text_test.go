@@ -58,3 +58,51 @@ func TestText(t *testing.T) {
 		})
 	}
 }
+
+func TestSetStringQuotes(t *testing.T) {
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte("key value\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	n.SetString("two words")
+
+	out, err := n.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "key \"two words\"\n"; string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestSetValueOnSubtree(t *testing.T) {
+	t.Parallel()
+
+	in := "\"root\"\n{\n\t\"sub\"   {   \"x\" \"1\" }\n\t\"empty\" {}\n\t\"key\"   \"value\"\n}\n\"other\"   {   \"y\"    \"2\" }\n"
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte(in)); err != nil {
+		t.Fatal(err)
+	}
+
+	n.FirstByName("sub").SetString("new")
+	n.FirstByName("empty").SetInt(1)
+	n.FirstByName("key").SetInt(2)
+
+	out, err := n.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "\"root\"\n{\n\t\"sub\" \"new\"\n\t\"empty\" \"1\"\n\t\"key\"   \"2\"\n}\n\"other\"   {   \"y\"    \"2\" }\n"; string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	// Only the root that is replaced loses its formatting.
+	n.SetString("root")
+	if out, err = n.MarshalText(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "\"root\" \"root\"\n\"other\"   {   \"y\"    \"2\" }\n"; string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
@@ -1,7 +1,5 @@
 package vdf
 
-import "strings"
-
 func (n *Node) advanceSimple(simple bool) *Node {
 	for next := n; next != nil; next = next.next {
 		if simple && next.child == nil {
@@ -21,16 +19,26 @@ func (n *Node) NextSubTree() *Node  { return n.notNil().next.advanceSimple(false
 func (n *Node) NextValue() *Node    { return n.notNil().next.advanceSimple(true) }
 
 func (n *Node) FirstByName(name string) *Node {
+	if n != nil && n.index != nil {
+		return n.index.first(name)
+	}
+	m := nameMatcher{name: name}
 	for c := n.FirstChild(); c != nil; c = c.NextChild() {
-		if strings.EqualFold(c.Name(), name) {
+		if m.match(c) {
 			return c
 		}
 	}
 	return nil
 }
 func (n *Node) NextByName(name string) *Node {
+	if n != nil && n.parent != nil && n.parent.index != nil {
+		if c, ok := n.parent.index.next(n, name); ok {
+			return c
+		}
+	}
+	m := nameMatcher{name: name}
 	for c := n.NextChild(); c != nil; c = c.NextChild() {
-		if strings.EqualFold(c.Name(), name) {
+		if m.match(c) {
 			return c
 		}
 	}
@@ -51,6 +59,9 @@ func (n *Node) Append(c *Node) {
 	c.parent = n
 	*f = c
 	c.prev = l
+	if n.index != nil {
+		n.index.add(c)
+	}
 
 	if n.value != nil && n.cf != nil {
 		n.cf.between += "\n{\n"
@@ -59,6 +70,18 @@ func (n *Node) Append(c *Node) {
 	n.value = nil
 }
 
+// removeChildren makes n ready to hold a value. The custom formatting of a
+// subtree describes its braces, so it is dropped along with the children, as
+// Append adds braces to the formatting of a value that becomes a subtree.
+func (n *Node) removeChildren() {
+	if n.value == nil {
+		n.cf = nil
+	}
+	for n.child != nil {
+		n.child.Remove()
+	}
+}
+
 func (n *Node) Remove() {
 	if n == nil || n.parent == nil {
 		return
@@ -66,6 +89,10 @@ func (n *Node) Remove() {
 
 	next := n.next
 
+	if n.parent.index != nil {
+		n.parent.index.remove(n)
+	}
+
 	if n.next != nil {
 		n.next.prev = n.prev
 		n.next = nil
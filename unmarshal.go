@@ -0,0 +1,209 @@
+package vdf
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var nodeType = reflect.TypeOf((*Node)(nil))
+
+// UnmarshalTypeError is returned by Unmarshal for a key that cannot be stored
+// in the corresponding Go value.
+type UnmarshalTypeError struct {
+	// Path is the names of the keys from the Node passed to Unmarshal to
+	// the key with the problem, separated by slashes.
+	Path  string
+	Value string
+	Type  reflect.Type
+}
+
+func (err *UnmarshalTypeError) Error() string {
+	if err.Value == "" {
+		return fmt.Sprintf("vdf: cannot unmarshal %s into Go value of type %v", err.Path, err.Type)
+	}
+	return fmt.Sprintf("vdf: cannot unmarshal %q at %s into Go value of type %v", err.Value, err.Path, err.Type)
+}
+
+// Unmarshal stores the contents of n in the value pointed to by v.
+//
+// The children of a subtree are stored in a struct or a map. Each struct
+// field is matched to the children with the same name, ignoring case, as in
+// FirstByName. The name can be changed with a struct tag such as
+// `vdf:"name"`, and fields tagged `vdf:"-"` are ignored, as are children that
+// match no field. Map keys can be strings or integers. String map keys are
+// also compared ignoring case, and use the name of the first child with the
+// key.
+//
+// A key that appears more than once is stored in a slice, with one element
+// per occurrence. Otherwise, only the first occurrence is stored, as with
+// FirstByName, and the others are ignored.
+//
+// Values are stored in strings, integers, floating-point numbers, and bools
+// by parsing their text. An empty value is stored as the zero value. A field
+// of type *Node stores the Node itself.
+func Unmarshal(n *Node, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("vdf: Unmarshal requires a non-nil pointer, not %T", v)
+	}
+	return unmarshalValue(n, rv.Elem(), n.Name())
+}
+
+func unmarshalValue(n *Node, v reflect.Value, path string) error {
+	if v.Type() == nodeType {
+		v.Set(reflect.ValueOf(n))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshalValue(n, v.Elem(), path)
+	case reflect.Struct, reflect.Map:
+		if n.FirstChild() == nil && n.String() != "" {
+			return &UnmarshalTypeError{Path: path, Value: n.String(), Type: v.Type()}
+		}
+		if v.Kind() == reflect.Map {
+			return unmarshalMap(n, v, path)
+		}
+		return unmarshalStruct(n, v, path)
+	case reflect.Slice:
+		e := reflect.New(v.Type().Elem()).Elem()
+		if err := unmarshalValue(n, e, path); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, e))
+		return nil
+	}
+
+	if n.FirstChild() != nil {
+		return &UnmarshalTypeError{Path: path, Type: v.Type()}
+	}
+
+	s := n.String()
+	if s == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(s, 10, v.Type().Bits())
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var i uint64
+		i, err = strconv.ParseUint(s, 10, v.Type().Bits())
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, v.Type().Bits())
+		v.SetFloat(f)
+	default:
+		err = fmt.Errorf("unsupported type")
+	}
+	if err != nil {
+		return &UnmarshalTypeError{Path: path, Value: s, Type: v.Type()}
+	}
+	return nil
+}
+
+func unmarshalStruct(n *Node, v reflect.Value, path string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("vdf"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() != reflect.Slice {
+			if c := n.FirstByName(name); c != nil {
+				if err := unmarshalValue(c, fv, path+"/"+c.Name()); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		fv.Set(reflect.Zero(fv.Type()))
+		for c := n.FirstByName(name); c != nil; c = c.NextByName(name) {
+			if err := unmarshalValue(c, fv, path+"/"+c.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func unmarshalMap(n *Node, v reflect.Value, path string) error {
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+
+	// The keys of string maps are folded so that duplicates are found the
+	// same way as for struct fields.
+	folded := make(map[string]string)
+
+	for c := n.FirstChild(); c != nil; c = c.NextChild() {
+		cpath := path + "/" + c.Name()
+
+		k := reflect.New(t.Key()).Elem()
+		var err error
+		switch k.Kind() {
+		case reflect.String:
+			name, ok := folded[foldKey(c.Name())]
+			if !ok {
+				name = c.Name()
+				folded[foldKey(name)] = name
+			}
+			k.SetString(name)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var i int64
+			i, err = strconv.ParseInt(c.Name(), 10, t.Key().Bits())
+			k.SetInt(i)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			var i uint64
+			i, err = strconv.ParseUint(c.Name(), 10, t.Key().Bits())
+			k.SetUint(i)
+		default:
+			err = fmt.Errorf("unsupported type")
+		}
+		if err != nil {
+			return &UnmarshalTypeError{Path: cpath, Value: c.Name(), Type: t.Key()}
+		}
+
+		e := reflect.New(t.Elem()).Elem()
+		if old := v.MapIndex(k); old.IsValid() {
+			if t.Elem().Kind() != reflect.Slice {
+				continue
+			}
+			e.Set(old)
+		}
+		if err = unmarshalValue(c, e, cpath); err != nil {
+			return err
+		}
+		v.SetMapIndex(k, e)
+	}
+	return nil
+}
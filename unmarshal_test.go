@@ -0,0 +1,164 @@
+package vdf_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+func TestUnmarshal(t *testing.T) {
+	type display struct {
+		Name string `vdf:"name"`
+	}
+	type item struct {
+		Name    string
+		Price   float32   `vdf:"price"`
+		Count   int       `vdf:"count"`
+		Enabled bool      `vdf:"enabled"`
+		Tags    []string  `vdf:"tag"`
+		Display *display  `vdf:"display"`
+		Ignored string    `vdf:"-"`
+		Raw     *vdf.Node `vdf:"raw"`
+	}
+	type root struct {
+		Version uint64       `vdf:"version"`
+		Items   map[int]item `vdf:"items"`
+		Extra   map[string]string
+	}
+
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte(`"root" {
+		"version" "18446744073709551615"
+		"items" {
+			"1" {
+				"name" "first"
+				"price" "1.5"
+				"count" ""
+				"enabled" "1"
+				"tag" "a"
+				"TAG" "b"
+				"display" { "name" "First" }
+				"ignored" "x"
+				"raw" { "anything" "goes" }
+			}
+			"20" { "NAME" "second" "unknown" "key" }
+		}
+		"extra" { "a" "1" "b" "2" }
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var r root
+	if err := vdf.Unmarshal(&n, &r); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := r.Items[1].Raw
+	if raw.FirstByName("anything").String() != "goes" {
+		t.Errorf("expected raw node, got %v", raw)
+	}
+	expected := root{
+		Version: 18446744073709551615,
+		Items: map[int]item{
+			1:  {Name: "first", Price: 1.5, Enabled: true, Tags: []string{"a", "b"}, Display: &display{Name: "First"}, Raw: raw},
+			20: {Name: "second"},
+		},
+		Extra: map[string]string{"a": "1", "b": "2"},
+	}
+	if !reflect.DeepEqual(expected, r) {
+		t.Errorf("expected %+v", expected)
+		t.Errorf("actual   %+v", r)
+	}
+}
+
+func TestUnmarshalDuplicates(t *testing.T) {
+	var n vdf.Node
+	if err := n.UnmarshalText([]byte(`"root" {
+		"a" "1"
+		"A" "2"
+		"sub" { "x" "1" }
+		"sub" { "x" "2" "y" "2" }
+		"list" "1"
+		"list" "2"
+		"map" { "k" "1" "K" "2" "l" "3" }
+		"lists" { "k" "1" "K" "2" }
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var r struct {
+		A     int
+		Sub   struct{ X, Y int }
+		List  []int
+		Map   map[string]int
+		Lists map[string][]int
+	}
+	if err := vdf.Unmarshal(&n, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.A != 1 || r.Sub.X != 1 || r.Sub.Y != 0 || !reflect.DeepEqual(r.List, []int{1, 2}) || !reflect.DeepEqual(r.Map, map[string]int{"k": 1, "l": 3}) || !reflect.DeepEqual(r.Lists, map[string][]int{"k": {1, 2}}) {
+		t.Errorf("expected the first of each duplicate, got %+v", r)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		v    interface{}
+		path string
+	}{
+		{`"root" { "a" "x" }`, &struct{ A int }{}, "root/a"},
+		{`"root" { "a" "300" }`, &struct{ A int8 }{}, "root/a"},
+		{`"root" { "a" { "b" "1" } }`, &struct{ A string }{}, "root/a"},
+		{`"root" { "a" "1" }`, &struct{ A struct{ B int } }{}, "root/a"},
+		{`"root" { "a" { "x" "1" } }`, &struct{ A map[int]int }{}, "root/a/x"},
+		{`"root" { "a" "maybe" }`, &struct{ A bool }{}, "root/a"},
+	} {
+		var n vdf.Node
+		if err := n.UnmarshalText([]byte(tt.in)); err != nil {
+			t.Fatal(err)
+		}
+		err := vdf.Unmarshal(&n, tt.v)
+		var typeErr *vdf.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("%s: expected *vdf.UnmarshalTypeError, got %v", tt.in, err)
+		} else if typeErr.Path != tt.path {
+			t.Errorf("%s: expected error at %s, got %v", tt.in, tt.path, err)
+		}
+	}
+
+	var n vdf.Node
+	if err := vdf.Unmarshal(&n, struct{}{}); err == nil {
+		t.Error("expected an error for a non-pointer")
+	}
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "UserGameStatsSchema_630.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n vdf.Node
+	if err = n.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		GameName string `vdf:"gamename"`
+		Version  int    `vdf:"version"`
+		Stats    map[int]struct {
+			Name string  `vdf:"name"`
+			Min  float64 `vdf:"min"`
+		} `vdf:"stats"`
+	}
+	if err = vdf.Unmarshal(&n, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Version != 58 || schema.Stats[803].Name != "iTotalKills" {
+		t.Errorf("unexpected result: version %d, stat 803 %+v", schema.Version, schema.Stats[803])
+	}
+}
@@ -31,19 +31,19 @@ func (n *Node) String() string {
 		return fmt.Sprintf("%d %d %d %d", v.R, v.G, v.B, v.A)
 	case uint64:
 		return strconv.FormatUint(v, 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
 	}
 	panic("invalid vdf.Node")
 }
 
 func (n *Node) SetString(s string) {
-	for n.child != nil {
-		n.child.Remove()
-	}
+	n.removeChildren()
 
 	n.value = s
 
 	if n.cf != nil && n.cf.unquotedValue && (strings.IndexFunc(s, unicode.IsSpace) != -1 || strings.ContainsAny(s, "\"{}")) {
-		n.cf.unquotedKey = false
+		n.cf.unquotedValue = false
 	}
 }
 
@@ -77,14 +77,14 @@ func (n *Node) Int() int32 {
 		return 0
 	case uint64:
 		return int32(v)
+	case int64:
+		return int32(v)
 	}
 	panic("invalid vdf.Node")
 }
 
 func (n *Node) SetInt(i int32) {
-	for n.child != nil {
-		n.child.Remove()
-	}
+	n.removeChildren()
 
 	n.value = i
 }
@@ -119,14 +119,14 @@ func (n *Node) Float() float32 {
 		return 0
 	case uint64:
 		return float32(v)
+	case int64:
+		return float32(v)
 	}
 	panic("invalid vdf.Node")
 }
 
 func (n *Node) SetFloat(f float32) {
-	for n.child != nil {
-		n.child.Remove()
-	}
+	n.removeChildren()
 
 	n.value = f
 }
@@ -161,14 +161,14 @@ func (n *Node) Ptr() uint32 {
 		return 0
 	case uint64:
 		return uint32(v)
+	case int64:
+		return uint32(v)
 	}
 	panic("invalid vdf.Node")
 }
 
 func (n *Node) SetPtr(i uint32) {
-	for n.child != nil {
-		n.child.Remove()
-	}
+	n.removeChildren()
 
 	n.value = i
 }
@@ -189,9 +189,7 @@ func (n *Node) WString() []uint16 {
 }
 
 func (n *Node) SetWString(s []uint16) {
-	for n.child != nil {
-		n.child.Remove()
-	}
+	n.removeChildren()
 
 	c := make([]uint16, len(s))
 	copy(c, s)
@@ -211,8 +209,7 @@ func (n *Node) Color() color.NRGBA {
 	case nil:
 		return color.NRGBA{}
 	case string:
-		var c color.NRGBA
-		_, err := fmt.Sscanf(v, "%d %d %d %d", &c.R, &c.G, &c.B, &c.A)
+		c, _, err := parseColor(v)
 		if err != nil {
 			return color.NRGBA{}
 		}
@@ -224,8 +221,7 @@ func (n *Node) Color() color.NRGBA {
 	case uint32:
 		return color.NRGBA{}
 	case []uint16:
-		var c color.NRGBA
-		_, err := fmt.Sscanf(string(utf16.Decode(v)), "%d %d %d %d", &c.R, &c.G, &c.B, &c.A)
+		c, _, err := parseColor(string(utf16.Decode(v)))
 		if err != nil {
 			return color.NRGBA{}
 		}
@@ -234,20 +230,29 @@ func (n *Node) Color() color.NRGBA {
 		return v
 	case uint64:
 		return color.NRGBA{}
+	case int64:
+		return color.NRGBA{}
 	}
 	panic("invalid vdf.Node")
 }
 
+// SetColor keeps the form of a color read from text, such as "255 128 0"
+// or "#ff8000", so MarshalText writes the new color the same way.
 func (n *Node) SetColor(c color.NRGBA) {
-	for n.child != nil {
-		n.child.Remove()
-	}
-
-	n.value = c
+	n.removeChildren()
 
 	if n.cf != nil {
-		n.cf.unquotedValue = false
+		if s, ok := n.value.(string); ok {
+			_, n.cf.colorStyle, _ = parseColor(s)
+		} else if _, ok := n.value.(color.NRGBA); !ok {
+			n.cf.colorStyle = colorRGBA
+		}
+		if n.cf.colorStyle != colorHex && n.cf.colorStyle != colorHexUpper {
+			n.cf.unquotedValue = false
+		}
 	}
+
+	n.value = c
 }
 
 func (n *Node) Uint64() uint64 {
@@ -280,14 +285,56 @@ func (n *Node) Uint64() uint64 {
 		return 0
 	case uint64:
 		return v
+	case int64:
+		return uint64(v)
 	}
 	panic("invalid vdf.Node")
 }
 
 func (n *Node) SetUint64(i uint64) {
-	for n.child != nil {
-		n.child.Remove()
+	n.removeChildren()
+
+	n.value = i
+}
+
+func (n *Node) Int64() int64 {
+	if n == nil || n.child != nil {
+		return 0
 	}
 
+	switch v := n.value.(type) {
+	case nil:
+		return 0
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0
+		}
+		return i
+	case int32:
+		return int64(v)
+	case float32:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case []uint16:
+		i, err := strconv.ParseInt(string(utf16.Decode(v)), 10, 64)
+		if err != nil {
+			return 0
+		}
+		return i
+	case color.NRGBA:
+		return 0
+	case uint64:
+		return int64(v)
+	case int64:
+		return v
+	}
+	panic("invalid vdf.Node")
+}
+
+func (n *Node) SetInt64(i int64) {
+	n.removeChildren()
+
 	n.value = i
 }
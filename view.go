@@ -0,0 +1,311 @@
+package vdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// BinaryView provides access to binary KeyValues without decoding all of
+// it. NewBinaryView checks the structure of the input once and records
+// where each key is, and Nodes are only created for the keys that are
+// requested.
+//
+// A BinaryView refers to the slice it was created from, which must not be
+// modified while the BinaryView is in use. A BinaryView and its entries are
+// safe to use from multiple goroutines at the same time.
+type BinaryView struct {
+	b       []byte
+	opts    *DecodeOptions
+	entries []BinaryEntry
+
+	// truncated is set if b was shortened to MaxBytes.
+	truncated bool
+}
+
+// BinaryEntry is a single key in a BinaryView. Like Node accessors, the
+// methods of BinaryEntry are safe to call on a nil receiver.
+type BinaryEntry struct {
+	v       *BinaryView
+	off     int // offset of the pack type
+	nameEnd int // offset of the null terminator of the name
+	end     int // offset of the byte after the value
+	pt      byte
+
+	// Indexes into v.entries, or 0 if there is no such entry. The first
+	// entry is never a child or a next sibling.
+	child int
+	next  int
+}
+
+// NewBinaryView indexes the binary KeyValues in b. The structure of the
+// input is checked in the same way as DecodeBinary, and errors are reported
+// in the same way, but values are not decoded until they are requested.
+// MaxBytes, MaxDepth, MaxNodes, MaxStringLength, Dialect, ExtendedTypes,
+// and Strict are applied by NewBinaryView, and the other options in opts
+// are applied by BinaryEntry.Node.
+func NewBinaryView(b []byte, opts *DecodeOptions) (*BinaryView, error) {
+	d := newDecodeState(opts)
+	v := &BinaryView{b: b, opts: d.opts}
+	if max := d.opts.MaxBytes; max > 0 && int64(len(b)) > max {
+		v.b, v.truncated = b[:max], true
+	}
+
+	end, err := v.index(d, 0)
+	if err != nil {
+		return nil, err
+	}
+	if d.opts.Strict && end != len(b) {
+		return nil, &BinaryError{Offset: int64(end), PackType: d.endMarker(), Err: ErrTrailingData}
+	}
+	return v, nil
+}
+
+// index records the keys from off up to the next end marker, and returns
+// the offset after the end marker.
+func (v *BinaryView) index(d *decodeState, off int) (int, error) {
+	prev := -1
+	for {
+		if off >= len(v.b) {
+			return off, &BinaryError{Offset: int64(off), Err: v.eof()}
+		}
+		pt := v.b[off]
+		if d.isEnd(pt) {
+			return off + 1, nil
+		}
+
+		i := len(v.entries)
+		v.entries = append(v.entries, BinaryEntry{v: v, off: off, pt: pt})
+		if prev != -1 {
+			v.entries[prev].next = i
+		}
+		prev = i
+
+		end, err := v.indexEntry(d, i)
+		if be, ok := err.(*BinaryError); ok {
+			return off, be
+		} else if err != nil {
+			return off, &BinaryError{Offset: int64(off), PackType: pt, Err: err}
+		}
+		off = end
+	}
+}
+
+// indexEntry finds the end of the name and value of entry i.
+func (v *BinaryView) indexEntry(d *decodeState, i int) (int, error) {
+	if err := d.addNode(); err != nil {
+		return 0, err
+	}
+
+	e := &v.entries[i]
+	off, pt := e.off, e.pt
+	name := bytes.IndexByte(v.b[off+1:], 0)
+	if name == -1 {
+		return 0, v.eof()
+	}
+	if err := d.checkString(name); err != nil {
+		return 0, err
+	}
+	e.nameEnd = off + 1 + name
+	off = e.nameEnd + 1
+
+	size, err := d.valueSize(pt)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case sizeSubtree:
+		if err := d.push(); err != nil {
+			return 0, err
+		}
+		end, err := v.index(d, off)
+		if err != nil {
+			return 0, err
+		}
+		d.pop()
+		// e may have moved when the children were appended.
+		v.entries[i].end = end
+		if len(v.entries) > i+1 {
+			v.entries[i].child = i + 1
+		}
+		return end, nil
+	case sizeString:
+		n := bytes.IndexByte(v.b[off:], 0)
+		if n == -1 {
+			return 0, v.eof()
+		}
+		if err := d.checkString(n); err != nil {
+			return 0, err
+		}
+		size = n + 1
+	case sizeWString:
+		if off+2 > len(v.b) {
+			return 0, v.eof()
+		}
+		n := int(binary.LittleEndian.Uint16(v.b[off:]))
+		if err := d.checkString(n); err != nil {
+			return 0, err
+		}
+		size = 2 + 2*n
+	}
+
+	if off+size > len(v.b) {
+		return 0, v.eof()
+	}
+	e.end = off + size
+	return e.end, nil
+}
+
+// eof returns the error for reaching the end of the input in the middle
+// of a key.
+func (v *BinaryView) eof() error {
+	if v.truncated {
+		return &LimitError{Limit: LimitBytes, Max: v.opts.MaxBytes}
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// First returns the first top-level key, or nil if there are none.
+func (v *BinaryView) First() *BinaryEntry {
+	if v == nil || len(v.entries) == 0 {
+		return nil
+	}
+	return &v.entries[0]
+}
+
+// Find returns the first top-level key named path[0], then the first child
+// of that key named path[1], and so on. Names are compared ignoring case.
+// Find returns nil if there is no such key.
+func (v *BinaryView) Find(path ...string) *BinaryEntry {
+	if len(path) == 0 {
+		return nil
+	}
+	for e := v.First(); e != nil; e = e.NextChild() {
+		if e.is(path[0]) {
+			return e.Find(path[1:]...)
+		}
+	}
+	return nil
+}
+
+// Name returns the name of this key.
+func (e *BinaryEntry) Name() string {
+	if e == nil {
+		return ""
+	}
+	return string(e.v.b[e.off+1 : e.nameEnd])
+}
+
+// is reports whether the name of e is name, ignoring case, without
+// allocating.
+func (e *BinaryEntry) is(name string) bool {
+	b := e.v.b[e.off+1 : e.nameEnd]
+	if len(b) != len(name) {
+		// Case folding can change the length of non-ASCII names.
+		return strings.EqualFold(string(b), name)
+	}
+	for i := range b {
+		x, y := b[i], name[i]
+		if x == y {
+			continue
+		}
+		if x >= 0x80 || y >= 0x80 {
+			return strings.EqualFold(string(b), name)
+		}
+		if x|0x20 != y|0x20 || x|0x20 < 'a' || x|0x20 > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Kind returns the type of the value of this key, as Node.Kind would for
+// the decoded Node.
+func (e *BinaryEntry) Kind() Kind {
+	if e == nil {
+		return KindSubtree
+	}
+	if e.v.opts.Dialect == BinaryKeyValues && e.pt >= kvCompiledIntByte {
+		return KindInt
+	}
+	switch e.pt {
+	case ptString:
+		return KindString
+	case ptInt:
+		return KindInt
+	case ptFloat:
+		return KindFloat
+	case ptPtr:
+		return KindPtr
+	case ptWString:
+		return KindWString
+	case ptColor:
+		return KindColor
+	case ptUint64:
+		return KindUint64
+	case ptInt64:
+		return KindInt64
+	}
+	return KindSubtree
+}
+
+// FirstChild returns the first child of this key, or nil if it is not a
+// subtree or has no children.
+func (e *BinaryEntry) FirstChild() *BinaryEntry {
+	if e == nil || e.child == 0 {
+		return nil
+	}
+	return &e.v.entries[e.child]
+}
+
+// NextChild returns the next sibling of this key, or nil if it is the last
+// key in its subtree.
+func (e *BinaryEntry) NextChild() *BinaryEntry {
+	if e == nil || e.next == 0 {
+		return nil
+	}
+	return &e.v.entries[e.next]
+}
+
+// FirstByName returns the first child of this key with the given name,
+// compared ignoring case, or nil if there is none.
+func (e *BinaryEntry) FirstByName(name string) *BinaryEntry {
+	for c := e.FirstChild(); c != nil; c = c.NextChild() {
+		if c.is(name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Find follows FirstByName for each name in path. With an empty path, Find
+// returns e.
+func (e *BinaryEntry) Find(path ...string) *BinaryEntry {
+	for _, name := range path {
+		e = e.FirstByName(name)
+	}
+	return e
+}
+
+// Node decodes this key, including all of its children, into a new Node
+// with no parent or siblings. Only the bytes of this key are read. Errors
+// are reported with offsets from the start of the BinaryView.
+func (e *BinaryEntry) Node() (*Node, error) {
+	if e == nil {
+		return nil, nil
+	}
+	d := newDecodeState(e.v.opts)
+	r := newBinaryReader(io.MultiReader(
+		bytes.NewReader(e.v.b[e.off:e.end]),
+		bytes.NewReader([]byte{d.endMarker()}),
+	), d)
+	r.off = int64(e.off)
+	r.start = r.off
+
+	n := new(Node)
+	if err := r.decode(n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
@@ -0,0 +1,201 @@
+package vdf_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/BenLubar/vdf"
+)
+
+// compareView checks that e and its siblings match n and its siblings.
+func compareView(t *testing.T, e *vdf.BinaryEntry, n *vdf.Node) {
+	for ; e != nil || n != nil; e, n = e.NextChild(), n.NextChild() {
+		if e == nil || n == nil {
+			t.Errorf("expected %q, got %q", n.Name(), e.Name())
+			return
+		}
+		if e.Name() != n.Name() || e.Kind() != n.Kind() {
+			t.Errorf("expected %q (%v), got %q (%v)", n.Name(), n.Kind(), e.Name(), e.Kind())
+			return
+		}
+		compareView(t, e.FirstChild(), n.FirstChild())
+	}
+}
+
+func TestBinaryView(t *testing.T) {
+	t.Parallel()
+
+	in, err := ioutil.ReadFile("testdata/UserGameStatsSchema_630.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := vdf.NewBinaryView(in, &vdf.DecodeOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := vdf.DecodeBinary(in, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareView(t, v.First(), n)
+
+	root, err := v.First().Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := root.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(in, out) {
+		t.Error("materialized root differs from input")
+	}
+
+	e := v.Find("630", "STATS", "302", "type")
+	if e == nil {
+		t.Fatal("could not find 630/stats/302/type")
+	}
+	expected := n.FirstByName("stats").FirstByName("302").FirstByName("type")
+	actual, err := e.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.Name() != expected.Name() || actual.String() != expected.String() || actual.NextChild() != nil {
+		t.Errorf("expected %q %q, got %q %q", expected.Name(), expected.String(), actual.Name(), actual.String())
+	}
+
+	if e = v.Find("630", "missing"); e != nil {
+		t.Errorf("expected nil, got %q", e.Name())
+	}
+	if e = v.Find("missing"); e != nil {
+		t.Errorf("expected nil, got %q", e.Name())
+	}
+	if v.Find() != nil || e.FirstChild() != nil || e.Name() != "" {
+		t.Error("unexpected result for nil entry")
+	}
+	if n, err := e.Node(); n != nil || err != nil {
+		t.Errorf("expected nil Node for nil entry, got %v, %v", n, err)
+	}
+}
+
+func TestBinaryViewTypes(t *testing.T) {
+	t.Parallel()
+
+	in := []byte("\x00a\x00\x0ab\x00\xff\xff\xff\xff\xff\xff\xff\xff\x05w\x00\x01\x00x\x00\x00e\x00\x08\x06c\x00\x01\x02\x03\x04\x0b\x08")
+	if _, err := vdf.NewBinaryView(in, nil); err == nil {
+		t.Error("expected error without ExtendedTypes")
+	}
+	opts := &vdf.DecodeOptions{ExtendedTypes: true, Strict: true}
+	v, err := vdf.NewBinaryView(in, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := vdf.DecodeBinary(in, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareView(t, v.First(), n)
+
+	compiled := []byte("\x00root\x00\x09a\x00\x0ab\x00\x08c\x00\xfb\x02d\x00\xe8\x03\x00\x00\x0b\x0b")
+	opts = &vdf.DecodeOptions{Dialect: vdf.BinaryKeyValues, Strict: true}
+	if v, err = vdf.NewBinaryView(compiled, opts); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = vdf.DecodeBinary(compiled, opts); err != nil {
+		t.Fatal(err)
+	}
+	compareView(t, v.First(), n)
+	c, err := v.Find("root", "c").Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Int() != -5 {
+		t.Errorf("expected -5, got %d", c.Int())
+	}
+}
+
+func TestBinaryViewErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		in     string
+		opts   vdf.DecodeOptions
+		offset int64
+		pt     byte
+		err    error
+	}{
+		{"truncated", "\x00a\x00\x01b\x00xyz", vdf.DecodeOptions{}, 3, 1, io.ErrUnexpectedEOF},
+		{"no-end", "\x00a\x00\x01b\x00c\x00", vdf.DecodeOptions{}, 8, 0, io.ErrUnexpectedEOF},
+		{"short-int", "\x02a\x00\x01\x02", vdf.DecodeOptions{}, 0, 2, io.ErrUnexpectedEOF},
+		{"short-wstring", "\x05a\x00\x02\x00x\x00", vdf.DecodeOptions{}, 0, 5, io.ErrUnexpectedEOF},
+		{"trailing", "\x01a\x00b\x00\x08garbage", vdf.DecodeOptions{Strict: true}, 6, 8, vdf.ErrTrailingData},
+		{"depth", "\x00a\x00\x00b\x00\x08\x08\x08", vdf.DecodeOptions{MaxDepth: 1}, 3, 0, &vdf.LimitError{Limit: vdf.LimitDepth, Max: 1}},
+		{"nodes", "\x01a\x00b\x00\x01c\x00d\x00\x08", vdf.DecodeOptions{MaxNodes: 1}, 5, 1, &vdf.LimitError{Limit: vdf.LimitNodes, Max: 1}},
+		{"string", "\x01a\x00bcd\x00\x08", vdf.DecodeOptions{MaxStringLength: 2}, 0, 1, &vdf.LimitError{Limit: vdf.LimitStringLength, Max: 2}},
+		{"bytes", "\x01a\x00bcd\x00\x08", vdf.DecodeOptions{MaxBytes: 5}, 0, 1, &vdf.LimitError{Limit: vdf.LimitBytes, Max: 5}},
+	} {
+		tt := tt // shadow
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := vdf.NewBinaryView([]byte(tt.in), &tt.opts)
+			var be *vdf.BinaryError
+			if !errors.As(err, &be) {
+				t.Fatalf("expected *vdf.BinaryError, got %T: %v", err, err)
+			}
+			if be.Offset != tt.offset || be.PackType != tt.pt {
+				t.Errorf("expected offset %d and pack type %d, got offset %d and pack type %d", tt.offset, tt.pt, be.Offset, be.PackType)
+			}
+			if be.Err.Error() != tt.err.Error() {
+				t.Errorf("expected %v, got %v", tt.err, be.Err)
+			}
+
+			// The errors match those from DecodeBinary.
+			_, err = vdf.DecodeBinary([]byte(tt.in), &tt.opts)
+			if err == nil || err.Error() != be.Error() {
+				t.Errorf("DecodeBinary returned %v", err)
+			}
+		})
+	}
+}
+
+func BenchmarkBinaryView(b *testing.B) {
+	in, err := ioutil.ReadFile("testdata/UserGameStatsSchema_630.bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		v, err := vdf.NewBinaryView(in, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err = v.Find("630", "stats", "302", "type").Node(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBinaryDecode(b *testing.B) {
+	in, err := ioutil.ReadFile("testdata/UserGameStatsSchema_630.bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		n, err := vdf.DecodeBinary(in, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = n.FirstByName("stats").FirstByName("302").FirstByName("type").String()
+	}
+}